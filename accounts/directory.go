@@ -0,0 +1,57 @@
+package accounts
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tonkat-su/bot/emoji"
+)
+
+// Directory looks up which discord member owns a minecraft account
+type Directory struct {
+	byUuid map[string]string
+	byName map[string]string
+}
+
+// LoadDirectory snapshots every link in the store
+func LoadDirectory(ctx context.Context, store Store) (*Directory, error) {
+	links, err := store.Links(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dir := &Directory{
+		byUuid: make(map[string]string, len(links)),
+		byName: make(map[string]string, len(links)),
+	}
+	for _, link := range links {
+		dir.byUuid[normalizeUuid(link.MinecraftUuid)] = link.DiscordUserId
+		dir.byName[strings.ToLower(link.MinecraftName)] = link.DiscordUserId
+	}
+	return dir, nil
+}
+
+// DiscordUserId prefers the uuid since names can change, returning "" if the
+// account isn't linked
+func (dir *Directory) DiscordUserId(uuid, name string) string {
+	if dir == nil {
+		return ""
+	}
+	if uuid != "" {
+		if id, ok := dir.byUuid[normalizeUuid(uuid)]; ok {
+			return id
+		}
+	}
+	return dir.byName[strings.ToLower(name)]
+}
+
+// uuids show up both with and without dashes depending on where they came from
+func normalizeUuid(uuid string) string {
+	return strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+}
+
+// Annotate sets the discord user id of every linked player
+func (dir *Directory) Annotate(players []*emoji.Player) {
+	for _, player := range players {
+		player.DiscordUserId = dir.DiscordUserId(player.Uuid, player.Name)
+	}
+}
@@ -0,0 +1,154 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tonkat-su/bot/filestore"
+)
+
+var ErrNotFound = errors.New("accounts: not found")
+
+// Link is a discord member's proven ownership of a minecraft account
+type Link struct {
+	DiscordUserId string
+	MinecraftUuid string
+	MinecraftName string
+	LinkedAt      time.Time
+}
+
+// Challenge is a pending link waiting for the player to enter Code in game
+type Challenge struct {
+	DiscordUserId string
+	MinecraftUuid string
+	MinecraftName string
+	Code          string
+	ExpiresAt     time.Time
+}
+
+func (c *Challenge) Expired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// Store persists links and pending challenges, keyed by discord user id
+type Store interface {
+	Link(ctx context.Context, discordUserId string) (*Link, error)
+	Links(ctx context.Context) ([]*Link, error)
+	PutLink(ctx context.Context, link *Link) error
+	DeleteLink(ctx context.Context, discordUserId string) error
+
+	Challenge(ctx context.Context, discordUserId string) (*Challenge, error)
+	PutChallenge(ctx context.Context, challenge *Challenge) error
+	DeleteChallenge(ctx context.Context, discordUserId string) error
+}
+
+type fileStoreData struct {
+	Links      map[string]*Link      `json:"links"`
+	Challenges map[string]*Challenge `json:"challenges"`
+}
+
+// FileStore keeps links in a json file
+type FileStore struct {
+	file *filestore.File
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{file: filestore.New(path)}
+}
+
+func (store *FileStore) load() (*fileStoreData, error) {
+	var data fileStoreData
+	err := store.file.Load(&data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (store *FileStore) update(fn func(*fileStoreData)) error {
+	var data fileStoreData
+	return store.file.Update(&data, func() error {
+		if data.Links == nil {
+			data.Links = make(map[string]*Link)
+		}
+		if data.Challenges == nil {
+			data.Challenges = make(map[string]*Challenge)
+		}
+		fn(&data)
+		return nil
+	})
+}
+
+func (store *FileStore) Link(ctx context.Context, discordUserId string) (*Link, error) {
+	data, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	link, ok := data.Links[discordUserId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return link, nil
+}
+
+func (store *FileStore) Links(ctx context.Context) ([]*Link, error) {
+	data, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	links := make([]*Link, 0, len(data.Links))
+	for _, link := range data.Links {
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// PutLink replaces any existing link for the discord user or the minecraft
+// account, since each side can only be linked once
+func (store *FileStore) PutLink(ctx context.Context, link *Link) error {
+	return store.update(func(data *fileStoreData) {
+		for id, existing := range data.Links {
+			if existing.MinecraftUuid == link.MinecraftUuid {
+				delete(data.Links, id)
+			}
+		}
+		data.Links[link.DiscordUserId] = link
+	})
+}
+
+func (store *FileStore) DeleteLink(ctx context.Context, discordUserId string) error {
+	return store.update(func(data *fileStoreData) {
+		delete(data.Links, discordUserId)
+	})
+}
+
+func (store *FileStore) Challenge(ctx context.Context, discordUserId string) (*Challenge, error) {
+	data, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	challenge, ok := data.Challenges[discordUserId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return challenge, nil
+}
+
+func (store *FileStore) PutChallenge(ctx context.Context, challenge *Challenge) error {
+	return store.update(func(data *fileStoreData) {
+		// drop anything stale while we're here
+		for id, existing := range data.Challenges {
+			if existing.Expired() {
+				delete(data.Challenges, id)
+			}
+		}
+		data.Challenges[challenge.DiscordUserId] = challenge
+	})
+}
+
+func (store *FileStore) DeleteChallenge(ctx context.Context, discordUserId string) error {
+	return store.update(func(data *fileStoreData) {
+		delete(data.Challenges, discordUserId)
+	})
+}
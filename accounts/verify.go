@@ -0,0 +1,173 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/rcon"
+)
+
+// how long a player has to enter their code in game
+const challengeTTL = 10 * time.Minute
+
+var (
+	ErrNoChallenge      = errors.New("no pending link, start one with /link start")
+	ErrChallengeExpired = errors.New("link code expired, start again with /link start")
+	ErrCodeMismatch     = errors.New("the code entered in game doesn't match")
+)
+
+// Verifier gets a player to enter a code in game and reads it back
+type Verifier interface {
+	// Prepare sets the player up to enter a code and returns instructions for them
+	Prepare(ctx context.Context, playerName, code string) (instructions string, err error)
+	// Verify reports whether the player has entered the code
+	Verify(ctx context.Context, playerName, code string) (bool, error)
+}
+
+// the trigger objective players set their code with, triggers are the only
+// scoreboard objectives that non-op players can change
+const triggerObjective = "discordlink"
+
+// TriggerVerifier verifies codes over rcon with a trigger scoreboard
+// objective, so it works on vanilla servers without any plugins
+type TriggerVerifier struct {
+	Rcon rcon.Commander
+}
+
+func (v *TriggerVerifier) Prepare(ctx context.Context, playerName, code string) (string, error) {
+	// errors if the objective already exists, which is fine
+	_, err := v.Rcon.Send(ctx, fmt.Sprintf("scoreboard objectives add %s trigger", triggerObjective))
+	if err != nil {
+		return "", err
+	}
+
+	for _, command := range []string{
+		fmt.Sprintf("scoreboard players set %s %s 0", playerName, triggerObjective),
+		fmt.Sprintf("scoreboard players enable %s %s", playerName, triggerObjective),
+	} {
+		_, err = v.Rcon.Send(ctx, command)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("type `/trigger %s set %s` in game", triggerObjective, code), nil
+}
+
+// matches "bsdlp has 123456 [discordlink]"
+var scoreboardScorePattern = regexp.MustCompile(`has (-?\d+) \[`)
+
+func (v *TriggerVerifier) Verify(ctx context.Context, playerName, code string) (bool, error) {
+	output, err := v.Rcon.Send(ctx, fmt.Sprintf("scoreboard players get %s %s", playerName, triggerObjective))
+	if err != nil {
+		return false, err
+	}
+	match := scoreboardScorePattern.FindStringSubmatch(output)
+	if match == nil {
+		return false, nil
+	}
+	return match[1] == code, nil
+}
+
+// Linker walks a discord member through linking their minecraft account
+type Linker struct {
+	Store    Store
+	Verifier Verifier
+	// Names is where players are looked up, mcuser.Names if it's nil
+	Names *mcuser.Cache
+}
+
+func (l *Linker) names() *mcuser.Cache {
+	if l.Names == nil {
+		return mcuser.Names
+	}
+	return l.Names
+}
+
+// Start resolves the minecraft account and issues a code for the member to
+// enter in game, returning the account's name as mojang has it and
+// instructions for the member
+func (l *Linker) Start(ctx context.Context, discordUserId, playerName string) (name, instructions string, err error) {
+	uuid, err := l.names().Uuid(playerName)
+	if err != nil {
+		return "", "", err
+	}
+	// the name as typed may differ in case, which rcon and the link shouldn't
+	// depend on
+	name, err = l.names().Username(uuid)
+	if err != nil {
+		return "", "", err
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return "", "", err
+	}
+
+	instructions, err = l.Verifier.Prepare(ctx, name, code)
+	if err != nil {
+		return "", "", fmt.Errorf("error preparing verification for %s: %w", name, err)
+	}
+
+	err = l.Store.PutChallenge(ctx, &Challenge{
+		DiscordUserId: discordUserId,
+		MinecraftUuid: uuid,
+		MinecraftName: name,
+		Code:          code,
+		ExpiresAt:     time.Now().Add(challengeTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return name, instructions, nil
+}
+
+// Verify links the account once the member has entered their code in game
+func (l *Linker) Verify(ctx context.Context, discordUserId string) (*Link, error) {
+	challenge, err := l.Store.Challenge(ctx, discordUserId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNoChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Expired() {
+		return nil, ErrChallengeExpired
+	}
+
+	ok, err := l.Verifier.Verify(ctx, challenge.MinecraftName, challenge.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCodeMismatch
+	}
+
+	link := &Link{
+		DiscordUserId: discordUserId,
+		MinecraftUuid: challenge.MinecraftUuid,
+		MinecraftName: challenge.MinecraftName,
+		LinkedAt:      time.Now(),
+	}
+	err = l.Store.PutLink(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	return link, l.Store.DeleteChallenge(ctx, discordUserId)
+}
+
+// codes are numeric because trigger objectives only hold integers
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(900000))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(n.Int64()+100000, 10), nil
+}
@@ -0,0 +1,219 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tonkat-su/bot/mcuser"
+)
+
+const (
+	bsdlp = "a7ec7d80-a1f4-4d2c-9b6b-4ffb0e1f2f8d"
+	jcmp  = "3c2a3c71-53f4-4a4c-8fc5-2b2cbd6b6d3e"
+)
+
+// fakeVerifier hands out instructions and reports whatever code was entered
+// for each player
+type fakeVerifier struct {
+	prepared map[string]string
+	entered  map[string]string
+}
+
+func (v *fakeVerifier) Prepare(ctx context.Context, playerName, code string) (string, error) {
+	v.prepared[playerName] = code
+	return "enter " + code, nil
+}
+
+func (v *fakeVerifier) Verify(ctx context.Context, playerName, code string) (bool, error) {
+	return v.entered[playerName] == code, nil
+}
+
+func newTestLinker(t *testing.T) (*Linker, *fakeVerifier) {
+	t.Helper()
+	// names seen online don't have to be looked up
+	names := mcuser.NewCache(time.Hour, "")
+	names.Observe(bsdlp, "bsdlp")
+	names.Observe(jcmp, "jcmp")
+
+	verifier := &fakeVerifier{prepared: make(map[string]string), entered: make(map[string]string)}
+	return &Linker{
+		Store:    NewFileStore(filepath.Join(t.TempDir(), "links.json")),
+		Verifier: verifier,
+		Names:    names,
+	}, verifier
+}
+
+// link runs a member through linking playerName, entering the right code
+func link(t *testing.T, linker *Linker, verifier *fakeVerifier, discordUserId, playerName string) *Link {
+	t.Helper()
+	ctx := context.Background()
+	name, _, err := linker.Start(ctx, discordUserId, playerName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.entered[name] = verifier.prepared[name]
+	link, err := linker.Verify(ctx, discordUserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link
+}
+
+func TestLinkerStart(t *testing.T) {
+	ctx := context.Background()
+	linker, verifier := newTestLinker(t)
+
+	// the name as mojang has it is linked, not as typed
+	name, instructions, err := linker.Start(ctx, "1", "BSDLP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := verifier.prepared["bsdlp"]
+	if name != "bsdlp" || instructions != "enter "+code {
+		t.Fatalf("got %s with %q, want bsdlp with the verifier's instructions", name, instructions)
+	}
+
+	challenge, err := linker.Store.Challenge(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.MinecraftUuid != bsdlp || challenge.MinecraftName != "bsdlp" || challenge.Code != code || challenge.Expired() {
+		t.Fatalf("got challenge %+v, want an unexpired one for bsdlp with code %s", challenge, code)
+	}
+	if len(code) != 6 {
+		t.Fatalf("got code %q, want 6 digits", code)
+	}
+}
+
+func TestLinkerVerify(t *testing.T) {
+	ctx := context.Background()
+	linker, verifier := newTestLinker(t)
+
+	_, err := linker.Verify(ctx, "1")
+	if !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("got %v without starting, want %v", err, ErrNoChallenge)
+	}
+
+	_, _, err = linker.Start(ctx, "1", "bsdlp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.entered["bsdlp"] = "123"
+	_, err = linker.Verify(ctx, "1")
+	if !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("got %v for the wrong code, want %v", err, ErrCodeMismatch)
+	}
+
+	// the right code can still be entered after a wrong one
+	verifier.entered["bsdlp"] = verifier.prepared["bsdlp"]
+	got, err := linker.Verify(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DiscordUserId != "1" || got.MinecraftUuid != bsdlp || got.MinecraftName != "bsdlp" {
+		t.Fatalf("got link %+v, want 1 linked to bsdlp", got)
+	}
+	stored, err := linker.Store.Link(ctx, "1")
+	if err != nil || stored.MinecraftUuid != bsdlp {
+		t.Fatalf("got stored link %+v, %v, want bsdlp", stored, err)
+	}
+
+	// the code is used up
+	_, err = linker.Verify(ctx, "1")
+	if !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("got %v verifying again, want %v", err, ErrNoChallenge)
+	}
+}
+
+func TestLinkerVerifyExpired(t *testing.T) {
+	ctx := context.Background()
+	linker, verifier := newTestLinker(t)
+
+	err := linker.Store.PutChallenge(ctx, &Challenge{
+		DiscordUserId: "1",
+		MinecraftUuid: bsdlp,
+		MinecraftName: "bsdlp",
+		Code:          "123456",
+		ExpiresAt:     time.Now().Add(-time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// even the right code is too late
+	verifier.entered["bsdlp"] = "123456"
+	_, err = linker.Verify(ctx, "1")
+	if !errors.Is(err, ErrChallengeExpired) {
+		t.Fatalf("got %v, want %v", err, ErrChallengeExpired)
+	}
+	if _, err := linker.Store.Link(ctx, "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v looking up the link, want %v", err, ErrNotFound)
+	}
+}
+
+func TestLinkerRelink(t *testing.T) {
+	ctx := context.Background()
+	linker, verifier := newTestLinker(t)
+
+	link(t, linker, verifier, "1", "bsdlp")
+	// linking another account replaces the member's link
+	link(t, linker, verifier, "1", "jcmp")
+	// and an account can only be linked to one member
+	link(t, linker, verifier, "2", "jcmp")
+
+	links, err := linker.Store.Links(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].DiscordUserId != "2" || links[0].MinecraftUuid != jcmp {
+		t.Fatalf("got %d links, want only 2 linked to jcmp", len(links))
+	}
+}
+
+// fakeCommander answers rcon commands from a map
+type fakeCommander struct {
+	outputs map[string]string
+	sent    []string
+}
+
+func (c *fakeCommander) Send(ctx context.Context, command string) (string, error) {
+	c.sent = append(c.sent, command)
+	return c.outputs[command], nil
+}
+
+func TestTriggerVerifier(t *testing.T) {
+	ctx := context.Background()
+	commander := &fakeCommander{outputs: map[string]string{
+		"scoreboard players get bsdlp discordlink": "bsdlp has 123456 [discordlink]",
+		"scoreboard players get jcmp discordlink":  "Can't get value of discordlink for jcmp; none is set",
+	}}
+	verifier := &TriggerVerifier{Rcon: commander}
+
+	instructions, err := verifier.Prepare(ctx, "bsdlp", "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instructions != "type `/trigger discordlink set 123456` in game" || len(commander.sent) != 3 {
+		t.Fatalf("got %q after sending %v, want trigger instructions after setting the objective up", instructions, commander.sent)
+	}
+
+	for _, tt := range []struct {
+		player string
+		code   string
+		want   bool
+	}{
+		{"bsdlp", "123456", true},
+		{"bsdlp", "654321", false},
+		{"jcmp", "123456", false},
+	} {
+		got, err := verifier.Verify(ctx, tt.player, tt.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("verifying %s with %s got %t, want %t", tt.player, tt.code, got, tt.want)
+		}
+	}
+}
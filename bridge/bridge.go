@@ -0,0 +1,161 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/rcon"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+const (
+	// in game chat is posted through a webhook with this name so that each
+	// message can have the player's name and face
+	webhookName = "minecraft chat bridge"
+
+	// the longest message minecraft lets players send, which keeps tellraw
+	// commands well within rcon's limit
+	maxGameMessageLength = 256
+
+	relayTimeout = 5 * time.Second
+)
+
+// Bridge relays chat between a discord channel and the minecraft server
+type Bridge struct {
+	session   *discordgo.Session
+	rcon      *rcon.Client
+	channelId string
+
+	mu      sync.Mutex
+	webhook *discordgo.Webhook
+}
+
+func New(session *discordgo.Session, rconClient *rcon.Client, channelId string) *Bridge {
+	return &Bridge{
+		session:   session,
+		rcon:      rconClient,
+		channelId: channelId,
+	}
+}
+
+// HandleMessageCreate relays messages posted in the bridge channel into the
+// game, register it with the gateway session
+func (b *Bridge) HandleMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	// skipping webhooks and bots keeps relayed chat from echoing back
+	if m.ChannelID != b.channelId || m.Author == nil || m.Author.Bot || m.WebhookID != "" {
+		return
+	}
+
+	text := gameMessageText(m.Message)
+	if text == "" {
+		return
+	}
+
+	var color string
+	if c := s.State.MessageColor(m.Message); c != 0 {
+		color = fmt.Sprintf("#%06x", c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+	err := b.rcon.Tellraw(ctx, "@a", []rcon.TextComponent{
+		{Text: "[discord] ", Color: "dark_gray"},
+		{Text: authorName(m.Message), Color: color},
+		{Text: ": " + text},
+	})
+	// nobody is online to see it
+	if errors.Is(err, rcon.ErrPlayerNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("error relaying discord message %s to the game: %s", m.ID, err.Error())
+	}
+}
+
+func authorName(m *discordgo.Message) string {
+	if m.Member != nil && m.Member.Nick != "" {
+		return m.Member.Nick
+	}
+	return m.Author.Username
+}
+
+// gameMessageText flattens a discord message into a single chat line
+func gameMessageText(m *discordgo.Message) string {
+	// formatting codes would otherwise color and scramble the line in game
+	text := strings.Join(strings.Fields(rcon.StripFormatting(m.ContentWithMentionsReplaced())), " ")
+	for range m.Attachments {
+		text += " [attachment]"
+	}
+	text = strings.TrimSpace(text)
+
+	runes := []rune(text)
+	if len(runes) > maxGameMessageLength {
+		text = string(runes[:maxGameMessageLength-1]) + "…"
+	}
+	return text
+}
+
+// Run relays chat from the server log to discord until events is closed
+func (b *Bridge) Run(events <-chan serverlog.Event) {
+	for event := range events {
+		chat, ok := event.(*serverlog.Chat)
+		if !ok {
+			continue
+		}
+		err := b.relayChat(chat)
+		if err != nil {
+			log.Printf("error relaying chat from %s to discord: %s", chat.Player, err.Error())
+		}
+	}
+}
+
+func (b *Bridge) relayChat(chat *serverlog.Chat) error {
+	webhook, err := b.channelWebhook()
+	if err != nil {
+		return err
+	}
+
+	_, err = b.session.WebhookExecute(webhook.ID, webhook.Token, false, &discordgo.WebhookParams{
+		Content:   rcon.StripFormatting(chat.Message),
+		Username:  chat.Player,
+		AvatarURL: mcuser.FaceUrl(chat.Player),
+		// players can't ping the whole server from in game
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
+
+// channelWebhook finds the bridge's webhook in the channel, creating it the
+// first time
+func (b *Bridge) channelWebhook() (*discordgo.Webhook, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.webhook != nil {
+		return b.webhook, nil
+	}
+
+	webhooks, err := b.session.ChannelWebhooks(b.channelId)
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks: %w", err)
+	}
+	for _, webhook := range webhooks {
+		if webhook.Name == webhookName && webhook.Token != "" {
+			b.webhook = webhook
+			return webhook, nil
+		}
+	}
+
+	webhook, err := b.session.WebhookCreate(b.channelId, webhookName, "")
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+	b.webhook = webhook
+	return webhook, nil
+}
@@ -0,0 +1,195 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/rcon"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+const (
+	guildId   = "100"
+	channelId = "200"
+	roleId    = "300"
+)
+
+// fakeCommander records the commands sent to it
+type fakeCommander struct {
+	commands []string
+}
+
+func (c *fakeCommander) Send(ctx context.Context, command string) (string, error) {
+	c.commands = append(c.commands, command)
+	return "", nil
+}
+
+// fakeDiscord answers the webhook endpoints the bridge uses, recording what's
+// executed
+type fakeDiscord struct {
+	mu       sync.Mutex
+	created  int
+	executed []discordgo.WebhookParams
+}
+
+func (d *fakeDiscord) RoundTrip(r *http.Request) (*http.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status, body := http.StatusNotFound, `{"message": "404: Not Found", "code": 0}`
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/channels/"+channelId+"/webhooks"):
+		status, body = http.StatusOK, `[{"id": "1", "name": "someone else's", "token": "other"}]`
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/channels/"+channelId+"/webhooks"):
+		d.created++
+		status, body = http.StatusOK, `{"id": "2", "name": "`+webhookName+`", "token": "secret"}`
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/webhooks/2/secret"):
+		var params discordgo.WebhookParams
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			return nil, err
+		}
+		d.executed = append(d.executed, params)
+		status, body = http.StatusNoContent, ""
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Request:    r,
+	}, nil
+}
+
+func newTestBridge(t *testing.T) (*Bridge, *fakeCommander, *fakeDiscord) {
+	t.Helper()
+	session, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discord := &fakeDiscord{}
+	session.Client = &http.Client{Transport: discord}
+
+	err = session.State.GuildAdd(&discordgo.Guild{
+		ID:       guildId,
+		Roles:    []*discordgo.Role{{ID: roleId, Color: 0x3498db, Position: 1}},
+		Channels: []*discordgo.Channel{{ID: channelId, GuildID: guildId}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commander := &fakeCommander{}
+	return New(session, &rcon.Client{Commander: commander}, channelId), commander, discord
+}
+
+func TestHandleMessageCreate(t *testing.T) {
+	bsdlp := &discordgo.User{ID: "1", Username: "bsdlp"}
+	tests := []struct {
+		name    string
+		message *discordgo.Message
+		// the tellraw sent, empty if nothing should be
+		want string
+	}{
+		{
+			name:    "relayed",
+			message: &discordgo.Message{ChannelID: channelId, Author: bsdlp, Content: "hello"},
+			want:    `tellraw @a ["",{"text":"[discord] ","color":"dark_gray"},{"text":"bsdlp"},{"text":": hello"}]`,
+		},
+		{
+			name: "nickname and role color",
+			message: &discordgo.Message{
+				ChannelID: channelId,
+				Author:    bsdlp,
+				Member:    &discordgo.Member{Nick: "jon", Roles: []string{roleId}},
+				Content:   "hi <@2>",
+				Mentions:  []*discordgo.User{{ID: "2", Username: "jcmp"}},
+			},
+			want: `tellraw @a ["",{"text":"[discord] ","color":"dark_gray"},{"text":"jon","color":"#3498db"},{"text":": hi @jcmp"}]`,
+		},
+		{
+			name:    "formatting codes stripped",
+			message: &discordgo.Message{ChannelID: channelId, Author: bsdlp, Content: "§cred §lbold§r <plain> & \"quoted\""},
+			want:    `tellraw @a ["",{"text":"[discord] ","color":"dark_gray"},{"text":"bsdlp"},{"text":": red bold <plain> & \"quoted\""}]`,
+		},
+		{
+			name:    "flattened onto one line",
+			message: &discordgo.Message{ChannelID: channelId, Author: bsdlp, Content: "look\n\nhere", Attachments: []*discordgo.MessageAttachment{{}}},
+			want:    `tellraw @a ["",{"text":"[discord] ","color":"dark_gray"},{"text":"bsdlp"},{"text":": look here [attachment]"}]`,
+		},
+		{
+			name:    "nothing left",
+			message: &discordgo.Message{ChannelID: channelId, Author: bsdlp, Content: "§a"},
+		},
+		{
+			name:    "other channel",
+			message: &discordgo.Message{ChannelID: "201", Author: bsdlp, Content: "hello"},
+		},
+		{
+			name:    "bot",
+			message: &discordgo.Message{ChannelID: channelId, Author: &discordgo.User{ID: "3", Username: "bot", Bot: true}, Content: "hello"},
+		},
+		{
+			// chat relayed from the game, which would echo
+			name:    "webhook",
+			message: &discordgo.Message{ChannelID: channelId, Author: bsdlp, WebhookID: "2", Content: "hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, commander, _ := newTestBridge(t)
+			b.HandleMessageCreate(b.session, &discordgo.MessageCreate{Message: tt.message})
+
+			var want []string
+			if tt.want != "" {
+				want = []string{tt.want}
+			}
+			if strings.Join(commander.commands, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("got commands %q, want %q", commander.commands, want)
+			}
+		})
+	}
+}
+
+func TestGameMessageTextLength(t *testing.T) {
+	text := gameMessageText(&discordgo.Message{Content: strings.Repeat("é", 2*maxGameMessageLength)})
+	if runes := []rune(text); len(runes) != maxGameMessageLength || runes[len(runes)-1] != '…' {
+		t.Fatalf("got %d runes ending in %q, want %d ending in …", len(runes), runes[len(runes)-1], maxGameMessageLength)
+	}
+}
+
+func TestRunRelaysChat(t *testing.T) {
+	b, _, discord := newTestBridge(t)
+
+	events := make(chan serverlog.Event, 3)
+	events <- &serverlog.Chat{Player: "bsdlp", Message: "§6hello @everyone"}
+	events <- &serverlog.Join{Player: "jcmp"}
+	events <- &serverlog.Chat{Player: "jcmp", Message: "hi"}
+	close(events)
+	b.Run(events)
+
+	if discord.created != 1 {
+		t.Fatalf("created %d webhooks, want the bridge's to be created once", discord.created)
+	}
+	if len(discord.executed) != 2 {
+		t.Fatalf("got %d messages, want one per chat", len(discord.executed))
+	}
+	first := discord.executed[0]
+	if first.Content != "hello @everyone" || first.Username != "bsdlp" || !strings.Contains(first.AvatarURL, "bsdlp") {
+		t.Errorf("got %q from %s with avatar %s, want hello @everyone from bsdlp with their face", first.Content, first.Username, first.AvatarURL)
+	}
+	// players can't ping anyone from in game
+	if first.AllowedMentions == nil || len(first.AllowedMentions.Parse) != 0 {
+		t.Errorf("got allowed mentions %+v, want none", first.AllowedMentions)
+	}
+	if second := discord.executed[1]; second.Content != "hi" || second.Username != "jcmp" {
+		t.Errorf("got %q from %s, want hi from jcmp", second.Content, second.Username)
+	}
+}
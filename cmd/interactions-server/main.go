@@ -30,6 +30,8 @@ func main() {
 		}
 	}()
 
+	server.StartMonitors()
+
 	mux := http.NewServeMux()
 	mux.Handle("/interactions", server)
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bsdlp/envconfig"
+	"github.com/tonkat-su/bot/leaderboard"
+)
+
+type Config struct {
+	MinecraftServerName  string `split_words:"true" required:"true"`
+	LeaderboardStorePath string `split_words:"true" required:"true"`
+
+	// Force copies even if the local store already has scores, which would
+	// count them twice if they came from an earlier migration
+	Force bool `split_words:"true" default:"false"`
+}
+
+// copies the leaderboard's history out of cloudwatch into a local store, for
+// moving to a deployment without aws
+func main() {
+	var cfg Config
+	err := envconfig.Process("", &cfg)
+	if err != nil {
+		log.Fatalf("error reading envconfig: %s", err.Error())
+	}
+
+	ctx := context.Background()
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("error loading aws config: %s", err.Error())
+	}
+	from := leaderboard.NewCloudwatchStore(awsCfg, &leaderboard.Config{
+		NamespacePrefix: cfg.MinecraftServerName,
+	})
+	to := leaderboard.NewBoltStore(cfg.LeaderboardStorePath)
+
+	end := time.Now().Truncate(time.Hour)
+	start := end.Add(-leaderboard.CloudwatchRetention)
+
+	if !cfg.Force {
+		existing, err := to.QueryScores(ctx, &leaderboard.QueryScoresInput{
+			Start:  start,
+			End:    end.Add(time.Hour),
+			Period: end.Add(time.Hour).Sub(start),
+		})
+		if err != nil {
+			log.Fatalf("error reading local store: %s", err.Error())
+		}
+		if len(existing) > 0 {
+			log.Fatalf("%s already has scores, set FORCE=true to copy anyway", cfg.LeaderboardStorePath)
+		}
+	}
+
+	copied, err := leaderboard.Copy(ctx, from, to, start, end, time.Hour)
+	if err != nil {
+		log.Fatalf("error copying scores: %s", err.Error())
+	}
+	log.Printf("copied %d hourly scores into %s", copied, cfg.LeaderboardStorePath)
+}
@@ -1,69 +1,63 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/bsdlp/envconfig"
 	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/interactions"
 )
 
-var commands = []*discordgo.ApplicationCommand{
-	{
-		Name:        "whitelist",
-		Description: "whitelist command",
-		Options: []*discordgo.ApplicationCommandOption{
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "add",
-				Description: "add minecraft user to whitelist",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionString,
-						Name:        "username",
-						Description: "minecraft username to add to whitelist",
-						Required:    true,
-					},
-				},
-			},
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "remove",
-				Description: "command to remove minecraft user from whitelist",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionString,
-						Name:        "username",
-						Description: "minecraft username to remove from whitelist",
-						Required:    true,
-					},
-				},
-			},
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "list",
-				Description: "command to list users currently whitelisted",
-			},
-		},
-	},
-	{
-		Name:        "online",
-		Description: "list who is currently online",
-	},
-	{
-		Name:        "leaderboard",
-		Description: "see who's the biggest nerd on the server",
-	},
-	{
-		Name:        "version",
-		Description: "returns build information",
-	},
+type Config struct {
+	DiscordToken string   `split_words:"true" required:"true"`
+	GuildId      string   `split_words:"true"`
+	GuildIds     []string `split_words:"true"`
+	Global       bool     `split_words:"true" default:"false"`
+	AppId        string   `split_words:"true" required:"true"`
+	Clean        bool     `split_words:"true" default:"false"`
+
+	// Sync diffs the registered commands against the registry instead of
+	// overwriting them, and only applies the plan once confirmed
+	Sync bool `split_words:"true" default:"false"`
+	// DryRun prints the sync plan without changing anything, with or without
+	// Sync
+	DryRun bool `split_words:"true" default:"false"`
+	// Yes applies the sync plan without prompting
+	Yes bool `split_words:"true" default:"false"`
+
+	// ConsoleTemplates has to match the interactions server's for /mc
+	ConsoleTemplates []string `split_words:"true" delimiter:";"`
+	// these have to match the interactions server's too, /uptime is only
+	// registered if it monitors uptime. leave them unset for lambda, which
+	// doesn't.
+	UptimeStorePath     string        `split_words:"true"`
+	UptimeCheckInterval time.Duration `split_words:"true" default:"1m"`
+	// and the admins. discord hides admin commands from members without
+	// AdminPermissions unless admins are given by role or user id too
+	AdminRoleIds     []string `split_words:"true"`
+	AdminUserIds     []string `split_words:"true"`
+	AdminPermissions int64    `split_words:"true" default:"32"`
 }
 
-type Config struct {
-	DiscordToken string `split_words:"true" required:"true"`
-	GuildId      string `split_words:"true" required:"true"`
-	AppId        string `split_words:"true" required:"true"`
-	Clean        bool   `split_words:"true" default:"false"`
+func (cfg *Config) targets() []target {
+	targets := []target{}
+	if cfg.Global {
+		targets = append(targets, target{})
+	}
+	if cfg.GuildId != "" {
+		targets = append(targets, target{GuildId: cfg.GuildId})
+	}
+	for _, id := range cfg.GuildIds {
+		if id != cfg.GuildId {
+			targets = append(targets, target{GuildId: id})
+		}
+	}
+	return targets
 }
 
 func main() {
@@ -73,6 +67,15 @@ func main() {
 		log.Fatalf("error reading envconfig: %s", err.Error())
 	}
 
+	commands, err := interactions.ConfiguredCommands(cfg.ConsoleTemplates, interactions.UptimeMonitored(cfg.UptimeStorePath, cfg.UptimeCheckInterval))
+	if err != nil {
+		log.Fatalf("invalid console templates: %s", err.Error())
+	}
+	err = interactions.ValidateCommands(commands)
+	if err != nil {
+		log.Fatalf("invalid command registry: %s", err.Error())
+	}
+
 	client, err := discordgo.New("Bot " + cfg.DiscordToken)
 	if err != nil {
 		log.Fatalf("error initializing discord client %s", err.Error())
@@ -85,22 +88,87 @@ func main() {
 		}
 	}()
 
-	if cfg.Clean {
-		log.Println("deleting existing application commands as requested")
-		cmds, err := client.ApplicationCommands(cfg.AppId, cfg.GuildId)
+	targets := cfg.targets()
+	if len(targets) == 0 {
+		log.Fatalf("no targets, set GUILD_ID, GUILD_IDS or GLOBAL")
+	}
+	desired := interactions.ApplicationCommands(commands, &interactions.Authorization{
+		RoleIds:     cfg.AdminRoleIds,
+		UserIds:     cfg.AdminUserIds,
+		Permissions: cfg.AdminPermissions,
+	})
+
+	if cfg.Sync || cfg.DryRun {
+		err = syncCommands(client, &cfg, targets, desired)
 		if err != nil {
-			log.Fatalf("error fetching registered commands: %s", err.Error())
+			log.Fatal(err)
 		}
-		for _, cmd := range cmds {
-			err = client.ApplicationCommandDelete(cfg.AppId, cfg.GuildId, cmd.ID)
+		return
+	}
+
+	for _, t := range targets {
+		if cfg.Clean {
+			log.Printf("deleting existing application commands in %s as requested", t)
+			cmds, err := client.ApplicationCommands(cfg.AppId, t.GuildId)
 			if err != nil {
-				log.Fatalf("error deleting command (id %s, name %s): %s", cmd.ID, cmd.Name, err.Error())
+				log.Fatalf("error fetching registered commands: %s", err.Error())
+			}
+			for _, cmd := range cmds {
+				err = client.ApplicationCommandDelete(cfg.AppId, t.GuildId, cmd.ID)
+				if err != nil {
+					log.Fatalf("error deleting command (id %s, name %s): %s", cmd.ID, cmd.Name, err.Error())
+				}
 			}
 		}
+
+		_, err = client.ApplicationCommandBulkOverwrite(cfg.AppId, t.GuildId, desired)
+		if err != nil {
+			log.Fatalf("error registering commands in %s: %s", t, err.Error())
+		}
+	}
+}
+
+func syncCommands(client *discordgo.Session, cfg *Config, targets []target, desired []*discordgo.ApplicationCommand) error {
+	plans := make([]*syncPlan, len(targets))
+	changes := false
+	for i, t := range targets {
+		current, err := client.ApplicationCommands(cfg.AppId, t.GuildId)
+		if err != nil {
+			return fmt.Errorf("error fetching registered commands in %s: %s", t, err.Error())
+		}
+		plans[i] = planSync(t, current, desired)
+		plans[i].Print(os.Stdout)
+		if !plans[i].Empty() {
+			changes = true
+		}
 	}
 
-	_, err = client.ApplicationCommandBulkOverwrite(cfg.AppId, cfg.GuildId, commands)
+	if !changes || cfg.DryRun {
+		return nil
+	}
+
+	if !cfg.Yes && !confirm("apply these changes?") {
+		log.Println("not applying changes")
+		return nil
+	}
+
+	for _, plan := range plans {
+		err := plan.Apply(client, cfg.AppId)
+		if err != nil {
+			return err
+		}
+		log.Printf("synced commands in %s", plan.Target)
+	}
+	return nil
+}
+
+// confirm asks on stdin, anything but yes (including no terminal) is a no
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
-		log.Fatalf("error registering commands %s", err.Error())
+		return false
 	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
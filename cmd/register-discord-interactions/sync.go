@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// target is where commands get registered, an empty guild id means global
+type target struct {
+	GuildId string
+}
+
+func (t target) String() string {
+	if t.GuildId == "" {
+		return "global"
+	}
+	return "guild " + t.GuildId
+}
+
+type commandUpdate struct {
+	Id      string
+	Command *discordgo.ApplicationCommand
+	Fields  []string
+}
+
+// syncPlan is what needs to change for a target's registered commands to
+// match the desired commands
+type syncPlan struct {
+	Target  target
+	Creates []*discordgo.ApplicationCommand
+	Updates []*commandUpdate
+	Deletes []*discordgo.ApplicationCommand
+}
+
+func (p *syncPlan) Empty() bool {
+	return len(p.Creates) == 0 && len(p.Updates) == 0 && len(p.Deletes) == 0
+}
+
+func (p *syncPlan) Print(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintf(w, "%s: up to date\n", p.Target)
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", p.Target)
+	for _, cmd := range p.Creates {
+		fmt.Fprintf(w, "  + create %s\n", cmd.Name)
+	}
+	for _, update := range p.Updates {
+		fmt.Fprintf(w, "  ~ update %s (%s)\n", update.Command.Name, strings.Join(update.Fields, ", "))
+	}
+	for _, cmd := range p.Deletes {
+		fmt.Fprintf(w, "  - delete %s\n", cmd.Name)
+	}
+}
+
+func (p *syncPlan) Apply(client *discordgo.Session, appId string) error {
+	for _, cmd := range p.Creates {
+		_, err := client.ApplicationCommandCreate(appId, p.Target.GuildId, cmd)
+		if err != nil {
+			return fmt.Errorf("error creating command %s in %s: %s", cmd.Name, p.Target, err.Error())
+		}
+	}
+	for _, update := range p.Updates {
+		_, err := client.ApplicationCommandEdit(appId, p.Target.GuildId, update.Id, update.Command)
+		if err != nil {
+			return fmt.Errorf("error updating command %s in %s: %s", update.Command.Name, p.Target, err.Error())
+		}
+	}
+	for _, cmd := range p.Deletes {
+		err := client.ApplicationCommandDelete(appId, p.Target.GuildId, cmd.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting command %s in %s: %s", cmd.Name, p.Target, err.Error())
+		}
+	}
+	return nil
+}
+
+func planSync(t target, current, desired []*discordgo.ApplicationCommand) *syncPlan {
+	plan := &syncPlan{Target: t}
+
+	registered := make(map[string]*discordgo.ApplicationCommand, len(current))
+	for _, cmd := range current {
+		registered[commandKey(cmd)] = cmd
+	}
+
+	for _, cmd := range desired {
+		key := commandKey(cmd)
+		existing, ok := registered[key]
+		if !ok {
+			plan.Creates = append(plan.Creates, cmd)
+			continue
+		}
+		delete(registered, key)
+
+		fields := changedFields(existing, cmd)
+		if len(fields) > 0 {
+			plan.Updates = append(plan.Updates, &commandUpdate{
+				Id:      existing.ID,
+				Command: cmd,
+				Fields:  fields,
+			})
+		}
+	}
+
+	for _, cmd := range registered {
+		plan.Deletes = append(plan.Deletes, cmd)
+	}
+	sort.Slice(plan.Deletes, func(i, j int) bool {
+		return plan.Deletes[i].Name < plan.Deletes[j].Name
+	})
+
+	return plan
+}
+
+// commands are unique by name within each command type
+func commandKey(cmd *discordgo.ApplicationCommand) string {
+	return fmt.Sprintf("%d/%s", commandType(cmd), cmd.Name)
+}
+
+func commandType(cmd *discordgo.ApplicationCommand) discordgo.ApplicationCommandType {
+	if cmd.Type == 0 {
+		return discordgo.ChatApplicationCommand
+	}
+	return cmd.Type
+}
+
+// changedFields compares the parts of a command that we control, after
+// filling in the defaults discord applies so that they don't show up as changes
+func changedFields(current, desired *discordgo.ApplicationCommand) []string {
+	type field struct {
+		name             string
+		current, desired interface{}
+	}
+
+	fields := []field{
+		{"description", current.Description, desired.Description},
+		{"name localizations", localizations(current.NameLocalizations), localizations(desired.NameLocalizations)},
+		{"description localizations", localizations(current.DescriptionLocalizations), localizations(desired.DescriptionLocalizations)},
+		{"default member permissions", current.DefaultMemberPermissions, desired.DefaultMemberPermissions},
+		{"dm permission", boolOrDefault(current.DMPermission, true), boolOrDefault(desired.DMPermission, true)},
+		{"nsfw", boolOrDefault(current.NSFW, false), boolOrDefault(desired.NSFW, false)},
+		{"options", normalizeOptions(current.Options), normalizeOptions(desired.Options)},
+	}
+
+	changed := []string{}
+	for _, f := range fields {
+		if !jsonEqual(f.current, f.desired) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aJson, errA := json.Marshal(a)
+	bJson, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return string(aJson) == string(bJson)
+}
+
+func boolOrDefault(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+func localizations(l *map[discordgo.Locale]string) map[discordgo.Locale]string {
+	if l == nil || len(*l) == 0 {
+		return nil
+	}
+	return *l
+}
+
+// normalizeOptions copies options with empty collections set to nil, which
+// is how discord returns them
+func normalizeOptions(options []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	if len(options) == 0 {
+		return nil
+	}
+
+	normalized := make([]*discordgo.ApplicationCommandOption, len(options))
+	for i, option := range options {
+		o := *option
+		if len(o.NameLocalizations) == 0 {
+			o.NameLocalizations = nil
+		}
+		if len(o.DescriptionLocalizations) == 0 {
+			o.DescriptionLocalizations = nil
+		}
+		if len(o.ChannelTypes) == 0 {
+			o.ChannelTypes = nil
+		}
+		if len(o.Choices) == 0 {
+			o.Choices = nil
+		}
+		o.Options = normalizeOptions(o.Options)
+		normalized[i] = &o
+	}
+	return normalized
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+func int64Pointer(i int64) *int64 {
+	return &i
+}
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired *discordgo.ApplicationCommand
+		want             []string
+	}{
+		{
+			name:    "same",
+			current: &discordgo.ApplicationCommand{Name: "online", Description: "who's on"},
+			desired: &discordgo.ApplicationCommand{Name: "online", Description: "who's on"},
+			want:    []string{},
+		},
+		{
+			name:    "discord's defaults",
+			current: &discordgo.ApplicationCommand{Name: "online", DMPermission: boolPointer(true), NSFW: boolPointer(false), NameLocalizations: &map[discordgo.Locale]string{}},
+			desired: &discordgo.ApplicationCommand{Name: "online"},
+			want:    []string{},
+		},
+		{
+			name: "empty option collections",
+			current: &discordgo.ApplicationCommand{Name: "stats", Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "username"},
+			}},
+			desired: &discordgo.ApplicationCommand{Name: "stats", Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "username", Choices: []*discordgo.ApplicationCommandOptionChoice{}, ChannelTypes: []discordgo.ChannelType{}},
+			}},
+			want: []string{},
+		},
+		{
+			name:    "description",
+			current: &discordgo.ApplicationCommand{Name: "online", Description: "who's on"},
+			desired: &discordgo.ApplicationCommand{Name: "online", Description: "list who is currently online"},
+			want:    []string{"description"},
+		},
+		{
+			name:    "permissions",
+			current: &discordgo.ApplicationCommand{Name: "whitelist"},
+			desired: &discordgo.ApplicationCommand{Name: "whitelist", DefaultMemberPermissions: int64Pointer(32), DMPermission: boolPointer(false)},
+			want:    []string{"default member permissions", "dm permission"},
+		},
+		{
+			name: "options",
+			current: &discordgo.ApplicationCommand{Name: "stats", Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "username"},
+			}},
+			desired: &discordgo.ApplicationCommand{Name: "stats", Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "username", Required: true},
+			}},
+			want: []string{"options"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := changedFields(test.current, test.desired)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	current := []*discordgo.ApplicationCommand{
+		{ID: "1", Name: "online", Description: "who's on"},
+		{ID: "2", Name: "version", Description: "returns build information"},
+		{ID: "3", Name: "old", Description: "not in the registry anymore"},
+		// same name as a chat command, but a different command
+		{ID: "4", Name: "stats", Type: discordgo.UserApplicationCommand},
+	}
+	desired := []*discordgo.ApplicationCommand{
+		{Name: "online", Description: "list who is currently online"},
+		{Name: "version", Description: "returns build information"},
+		{Name: "stats", Description: "see how much someone has played"},
+	}
+
+	plan := planSync(target{GuildId: "123"}, current, desired)
+
+	if len(plan.Creates) != 1 || plan.Creates[0].Name != "stats" {
+		t.Errorf("got creates %v, want stats", plan.Creates)
+	}
+	if len(plan.Updates) != 1 || plan.Updates[0].Id != "1" || !reflect.DeepEqual(plan.Updates[0].Fields, []string{"description"}) {
+		t.Errorf("got updates %v, want online's description", plan.Updates)
+	}
+	var deletes []string
+	for _, cmd := range plan.Deletes {
+		deletes = append(deletes, cmd.ID)
+	}
+	if !reflect.DeepEqual(deletes, []string{"3", "4"}) {
+		t.Errorf("got deletes %v, want 3 and 4", deletes)
+	}
+
+	if plan := planSync(target{}, desiredAsRegistered(desired), desired); !plan.Empty() {
+		t.Errorf("got a plan for commands that are already registered: %+v", plan)
+	}
+}
+
+// desiredAsRegistered is how discord hands the desired commands back once
+// they're registered
+func desiredAsRegistered(desired []*discordgo.ApplicationCommand) []*discordgo.ApplicationCommand {
+	registered := make([]*discordgo.ApplicationCommand, len(desired))
+	for i, cmd := range desired {
+		c := *cmd
+		c.ID = cmd.Name
+		c.Type = discordgo.ChatApplicationCommand
+		c.DMPermission = boolPointer(true)
+		registered[i] = &c
+	}
+	return registered
+}
@@ -18,6 +18,9 @@ type Player struct {
 	Name string
 	Uuid string
 
+	// DiscordUserId is set when the player has linked their discord account
+	DiscordUserId string
+
 	emojiId string
 }
 
@@ -29,6 +32,14 @@ func (p *Player) EmojiTextCode() string {
 	return "<:" + p.EmojiName() + ":" + p.emojiId + ">"
 }
 
+// Mention returns a mention of the player's linked discord account, if any
+func (p *Player) Mention() string {
+	if p.DiscordUserId == "" {
+		return ""
+	}
+	return "<@" + p.DiscordUserId + ">"
+}
+
 func fillPlayerEmojis(input []*discordgo.Emoji, players []*Player, fill func(*Player) error) error {
 	// TODO: cache this
 	e := make(map[string]*discordgo.Emoji)
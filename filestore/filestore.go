@@ -0,0 +1,111 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File keeps a json encoded value on disk, for state that has to survive
+// restarts in deployments without a database. the file is also locked while
+// it's used, so processes sharing it, like lambda instances with the same efs
+// mount, don't step on each other.
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+func New(path string) *File {
+	return &File{path: path}
+}
+
+// Load decodes the file into v, leaving v untouched if nothing has been saved yet
+func (f *File) Load(v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return f.load(v)
+}
+
+// Save replaces the file with v
+func (f *File) Save(v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return f.save(v)
+}
+
+// Update loads the file into v, calls fn to modify it and saves v if fn
+// doesn't return an error
+func (f *File) Update(v interface{}, fn func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = f.load(v)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err != nil {
+		return err
+	}
+	return f.save(v)
+}
+
+func (f *File) load(v interface{}) error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// save writes to a temporary file first so a crash never leaves a partial file behind
+func (f *File) save(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.path)
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
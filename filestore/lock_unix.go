@@ -0,0 +1,31 @@
+//go:build unix
+
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lock takes an exclusive lock on a file next to f, the file itself is
+// replaced on every save so it can't hold the lock
+func (f *File) lock() (unlock func(), err error) {
+	err = os.MkdirAll(filepath.Dir(f.path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		lockFile.Close()
+	}, nil
+}
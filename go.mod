@@ -11,8 +11,9 @@ require (
 	github.com/awslabs/aws-lambda-go-api-proxy v0.14.0
 	github.com/bsdlp/envconfig v1.5.0
 	github.com/bwmarrin/discordgo v0.27.1
-	github.com/jltobler/go-rcon v0.3.0
 	github.com/vincent-petithory/dataurl v1.0.0
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/net v0.12.0
 )
 
 require (
@@ -30,5 +31,5 @@ require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/pires/go-proxyproto v0.7.0 // indirect
 	golang.org/x/crypto v0.12.0 // indirect
-	golang.org/x/sys v0.11.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
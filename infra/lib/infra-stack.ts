@@ -67,9 +67,11 @@ export class InfraStack extends Stack {
       }
     );
 
+    // scores are only recorded once per interval, which is checked with
+    // GetMetricData before writing
     giveCatTreatsLambda.addToRolePolicy(
       new iam.PolicyStatement({
-        actions: ["cloudwatch:PutMetricData"],
+        actions: ["cloudwatch:PutMetricData", "cloudwatch:GetMetricData"],
         resources: ["*"],
       })
     );
@@ -0,0 +1,94 @@
+package interactions
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Authorization describes who is allowed to run a command. A member is
+// allowed if they are listed in UserIds, have any of RoleIds, or have all of
+// the Permissions bits. An empty Authorization allows everyone.
+type Authorization struct {
+	RoleIds     []string
+	UserIds     []string
+	Permissions int64
+}
+
+func (srv *Server) adminAuthorization() *Authorization {
+	return &Authorization{
+		RoleIds:     srv.cfg.AdminRoleIds,
+		UserIds:     srv.cfg.AdminUserIds,
+		Permissions: srv.cfg.AdminPermissions,
+	}
+}
+
+func (a *Authorization) allows(event discordgo.Interaction) bool {
+	if a == nil || (len(a.RoleIds) == 0 && len(a.UserIds) == 0 && a.Permissions == 0) {
+		return true
+	}
+
+	// commands are only registered to the guild, so anything without a member is denied
+	if event.Member == nil || event.Member.User == nil {
+		return false
+	}
+
+	for _, id := range a.UserIds {
+		if id == event.Member.User.ID {
+			return true
+		}
+	}
+
+	for _, allowed := range a.RoleIds {
+		for _, role := range event.Member.Roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+
+	if a.Permissions != 0 {
+		if event.Member.Permissions&discordgo.PermissionAdministrator != 0 {
+			return true
+		}
+		if event.Member.Permissions&a.Permissions == a.Permissions {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commandPath joins the command name with any subcommand names, e.g. "whitelist add"
+func commandPath(data discordgo.ApplicationCommandInteractionData) string {
+	path := []string{data.Name}
+	options := data.Options
+	for len(options) > 0 {
+		option := options[0]
+		if option.Type != discordgo.ApplicationCommandOptionSubCommand && option.Type != discordgo.ApplicationCommandOptionSubCommandGroup {
+			break
+		}
+		path = append(path, option.Name)
+		options = option.Options
+	}
+	return strings.Join(path, " ")
+}
+
+// authorize checks the authorization registered under name, telling the member
+// why they were denied. returns whether the handler should run.
+func (srv *Server) authorize(w http.ResponseWriter, event discordgo.Interaction, name string) bool {
+	if srv.authorizations[name].allows(event) {
+		return true
+	}
+
+	var userId string
+	if event.Member != nil && event.Member.User != nil {
+		userId = event.Member.User.ID
+	}
+	log.Printf("denied '%s' for user %s", name, userId)
+
+	writeEphemeralResponse(w, "you don't have permission to use this, ask a server admin")
+	return false
+}
@@ -0,0 +1,84 @@
+package interactions
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discord only shows this many autocomplete choices
+const maxAutocompleteChoices = 25
+
+func (srv *Server) routeAutocomplete(w http.ResponseWriter, event discordgo.Interaction) {
+	data := event.ApplicationCommandData()
+	handler, ok := srv.autocompletes[data.Name]
+	if !ok {
+		log.Printf("no autocomplete handler for command: %s", data.Name)
+		respondWithChoices(w, nil)
+		return
+	}
+	// suggestions like the whitelist shouldn't leak to members who can't run
+	// the command
+	if path := commandPath(data); !srv.authorizations[path].allows(event) {
+		log.Printf("denied autocomplete for '%s'", path)
+		respondWithChoices(w, nil)
+		return
+	}
+	handler(w, event, srv.s)
+}
+
+// focusedOption walks nested subcommands to find the option the user is typing in
+func focusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Focused {
+			return option
+		}
+		if found := focusedOption(option.Options); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// respondWithChoices suggests the names that match what has been typed so far
+func respondWithChoices(w http.ResponseWriter, names []string) {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(names))
+	for _, name := range names {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: name,
+		})
+	}
+
+	respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
+
+// matchingNames returns the sorted names that start with prefix, ignoring case
+func matchingNames(names []string, prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	matches := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i]) < strings.ToLower(matches[j])
+	})
+	return matches
+}
+
+// how long a player seen online is suggested for
+const recentPlayerTTL = 7 * 24 * time.Hour
@@ -0,0 +1,391 @@
+package interactions
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CommandHandler serves a slash command on behalf of the server
+type CommandHandler func(*Server, http.ResponseWriter, discordgo.Interaction, *discordgo.Session)
+
+// Command ties a slash command's definition to the code that serves it so
+// that what gets registered with discord and what the server handles can't
+// drift apart
+type Command struct {
+	Definition *discordgo.ApplicationCommand
+
+	Handler CommandHandler
+	// Deferred handlers are acknowledged right away and edit their response
+	// once they finish, for anything that may take longer than 3 seconds
+	Deferred bool
+	// Ephemeral deferred responses are only shown to the member who ran the
+	// command
+	Ephemeral bool
+
+	// Autocomplete is required if any of the definition's options autocomplete
+	Autocomplete CommandHandler
+
+	// ParseOptions rejects malformed options before the handler runs, the
+	// error is shown to the member
+	ParseOptions func(discordgo.ApplicationCommandInteractionData) error
+
+	// AdminOnly lists the command paths (see commandPath) that require the
+	// admin authorization from Config
+	AdminOnly []string
+}
+
+// Commands is the registry of every slash command the bot serves
+var Commands = []*Command{
+	{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "whitelist",
+			Description: "whitelist command",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "add minecraft user to whitelist",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "username",
+							Description:  "minecraft username to add to whitelist",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "command to remove minecraft user from whitelist",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "username",
+							Description:  "minecraft username to remove from whitelist",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "command to list users currently whitelisted",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "request",
+					Description: "ask a moderator to whitelist your minecraft account",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "username",
+							Description: "minecraft username to whitelist",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		Handler:      (*Server).whitelist,
+		Autocomplete: (*Server).whitelistAutocomplete,
+		ParseOptions: parseUsernameOptions,
+		AdminOnly:    []string{"whitelist add", "whitelist remove"},
+	},
+	{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "online",
+			Description: "list who is currently online",
+		},
+		Handler:  (*Server).online,
+		Deferred: true,
+	},
+	{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "leaderboard",
+			Description: "see who's the biggest nerd on the server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "period",
+					Description: "which stretch of time to rank, the last 7 days by default",
+					Choices:     leaderboardPeriodChoices(),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "since",
+					Description: "first day of a custom range, like 2006-01-02",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "until",
+					Description: "last day of a custom range, like 2006-01-02, today by default",
+				},
+			},
+		},
+		Handler:      (*Server).leaderboard,
+		Deferred:     true,
+		ParseOptions: parseLeaderboardOptions,
+	},
+	{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "stats",
+			Description: "see how much someone has played, yourself by default",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "username",
+					Description: "minecraft username",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "member",
+					Description: "member with a linked minecraft account",
+				},
+			},
+		},
+		Handler:      (*Server).stats,
+		Deferred:     true,
+		ParseOptions: parseUsernameOptions,
+	},
+	{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "link",
+			Description: "link your discord account to your minecraft account",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "start",
+					Description: "start linking a minecraft account",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "username",
+							Description: "your minecraft username",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "verify",
+					Description: "finish linking once you've entered your code in game",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "unlink your minecraft account",
+				},
+			},
+		},
+		Handler:      (*Server).link,
+		Deferred:     true,
+		Ephemeral:    true,
+		ParseOptions: parseUsernameOptions,
+	},
+	{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "version",
+			Description: "returns build information",
+		},
+		Handler: (*Server).version,
+	},
+}
+
+// ApplicationCommands returns the definitions of every command in the
+// registry. discord hides commands only admins can run from members without
+// the admins' permissions, unless admins are given by role or user id too,
+// since they wouldn't see them either.
+func ApplicationCommands(commands []*Command, admins *Authorization) []*discordgo.ApplicationCommand {
+	permissions := admins.Permissions
+	if len(admins.RoleIds) > 0 || len(admins.UserIds) > 0 {
+		permissions = 0
+	}
+
+	definitions := make([]*discordgo.ApplicationCommand, len(commands))
+	for i, cmd := range commands {
+		definitions[i] = cmd.Definition
+		if permissions != 0 && cmd.adminOnly() {
+			hidden := *cmd.Definition
+			hidden.DefaultMemberPermissions = &permissions
+			definitions[i] = &hidden
+		}
+	}
+	return definitions
+}
+
+// adminOnly reports whether everything the command can run is restricted to
+// admins
+func (cmd *Command) adminOnly() bool {
+	restricted := make(map[string]bool, len(cmd.AdminOnly))
+	for _, path := range cmd.AdminOnly {
+		restricted[path] = true
+	}
+	for path := range runnablePaths(cmd.Definition.Name, cmd.Definition.Options) {
+		if !restricted[path] {
+			return false
+		}
+	}
+	return true
+}
+
+// runnablePaths lists the command paths that can be run, e.g. "whitelist add"
+// but not "whitelist" which only groups its subcommands
+func runnablePaths(prefix string, options []*discordgo.ApplicationCommandOption) map[string]bool {
+	paths := map[string]bool{}
+	for _, option := range options {
+		if option.Type != discordgo.ApplicationCommandOptionSubCommand && option.Type != discordgo.ApplicationCommandOptionSubCommandGroup {
+			continue
+		}
+		for path := range runnablePaths(prefix+" "+option.Name, option.Options) {
+			paths[path] = true
+		}
+	}
+	if len(paths) == 0 {
+		paths[prefix] = true
+	}
+	return paths
+}
+
+// ValidateCommands checks that every command in the registry can be both
+// registered and served
+func ValidateCommands(commands []*Command) error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, cmd := range commands {
+		if cmd.Definition == nil {
+			errs = append(errs, fmt.Errorf("command %d has a handler but no definition", i))
+			continue
+		}
+
+		name := cmd.Definition.Name
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("command '%s' is registered more than once", name))
+		}
+		seen[name] = true
+
+		if cmd.Handler == nil {
+			errs = append(errs, fmt.Errorf("command '%s' has a definition but no handler", name))
+		}
+
+		autocompletes := hasAutocompleteOption(cmd.Definition.Options)
+		if autocompletes && cmd.Autocomplete == nil {
+			errs = append(errs, fmt.Errorf("command '%s' has autocomplete options but no autocomplete handler", name))
+		}
+		if !autocompletes && cmd.Autocomplete != nil {
+			errs = append(errs, fmt.Errorf("command '%s' has an autocomplete handler but no autocomplete options", name))
+		}
+
+		paths := definitionPaths(name, cmd.Definition.Options)
+		for _, path := range cmd.AdminOnly {
+			if !paths[path] {
+				errs = append(errs, fmt.Errorf("command '%s' restricts '%s' which isn't defined", name, path))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hasAutocompleteOption(options []*discordgo.ApplicationCommandOption) bool {
+	for _, option := range options {
+		if option.Autocomplete || hasAutocompleteOption(option.Options) {
+			return true
+		}
+	}
+	return false
+}
+
+// definitionPaths lists every command path that can be invoked, e.g. "whitelist add"
+func definitionPaths(prefix string, options []*discordgo.ApplicationCommandOption) map[string]bool {
+	paths := map[string]bool{prefix: true}
+	for _, option := range options {
+		if option.Type != discordgo.ApplicationCommandOptionSubCommand && option.Type != discordgo.ApplicationCommandOptionSubCommandGroup {
+			continue
+		}
+		for path := range definitionPaths(prefix+" "+option.Name, option.Options) {
+			paths[path] = true
+		}
+	}
+	return paths
+}
+
+// registerCommands binds the registry to the server
+func (srv *Server) registerCommands(commands []*Command) error {
+	err := ValidateCommands(commands)
+	if err != nil {
+		return err
+	}
+
+	srv.handlers = make(map[string]InteractionHandler, len(commands))
+	srv.autocompletes = make(map[string]InteractionHandler)
+	for _, cmd := range commands {
+		name := cmd.Definition.Name
+		srv.handlers[name] = srv.bindCommand(cmd)
+		if cmd.Autocomplete != nil {
+			srv.autocompletes[name] = srv.bindHandler(cmd.Autocomplete)
+		}
+		for _, path := range cmd.AdminOnly {
+			srv.authorizations[path] = srv.adminAuthorization()
+		}
+	}
+	return nil
+}
+
+func (srv *Server) bindHandler(handler CommandHandler) InteractionHandler {
+	return func(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+		handler(srv, w, event, s)
+	}
+}
+
+func (srv *Server) bindCommand(cmd *Command) InteractionHandler {
+	handler := srv.bindHandler(cmd.Handler)
+	if cmd.Deferred && cmd.Ephemeral {
+		handler = srv.deferredEphemeral(handler)
+	} else if cmd.Deferred {
+		handler = srv.deferred(handler)
+	}
+	if cmd.ParseOptions == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+		err := cmd.ParseOptions(event.ApplicationCommandData())
+		if err != nil {
+			log.Printf("invalid options for '%s': %s", cmd.Definition.Name, err.Error())
+			writeEphemeralResponse(w, err.Error())
+			return
+		}
+		handler(w, event, s)
+	}
+}
+
+var minecraftUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+
+// parseUsernameOptions makes sure any username option looks like a minecraft
+// username before it is sent anywhere
+func parseUsernameOptions(data discordgo.ApplicationCommandInteractionData) error {
+	return checkUsernameOptions(data.Options)
+}
+
+func checkUsernameOptions(options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	for _, option := range options {
+		if option.Name == "username" {
+			username, ok := option.Value.(string)
+			if !ok || !minecraftUsernamePattern.MatchString(username) {
+				return fmt.Errorf("'%v' is not a valid minecraft username", option.Value)
+			}
+		}
+		err := checkUsernameOptions(option.Options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package interactions
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestCommandsValid(t *testing.T) {
+	err := ValidateCommands(Commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// and with /mc, as it's registered
+	commands, err := ConfiguredCommands(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ValidateCommands(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateCommands(t *testing.T) {
+	handler := func(*Server, http.ResponseWriter, discordgo.Interaction, *discordgo.Session) {}
+	tests := []struct {
+		name     string
+		commands []*Command
+		wantErr  string
+	}{
+		{
+			name:     "no definition",
+			commands: []*Command{{Handler: handler}},
+			wantErr:  "no definition",
+		},
+		{
+			name:     "no handler",
+			commands: []*Command{{Definition: &discordgo.ApplicationCommand{Name: "ping"}}},
+			wantErr:  "no handler",
+		},
+		{
+			name: "duplicate",
+			commands: []*Command{
+				{Definition: &discordgo.ApplicationCommand{Name: "ping"}, Handler: handler},
+				{Definition: &discordgo.ApplicationCommand{Name: "ping"}, Handler: handler},
+			},
+			wantErr: "more than once",
+		},
+		{
+			name: "autocomplete without a handler",
+			commands: []*Command{{
+				Definition: &discordgo.ApplicationCommand{Name: "ping", Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "host", Autocomplete: true},
+				}},
+				Handler: handler,
+			}},
+			wantErr: "no autocomplete handler",
+		},
+		{
+			name: "restricts an undefined path",
+			commands: []*Command{{
+				Definition: &discordgo.ApplicationCommand{Name: "ping"},
+				Handler:    handler,
+				AdminOnly:  []string{"ping all"},
+			}},
+			wantErr: "restricts 'ping all'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCommands(tt.commands)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplicationCommandsHidesAdminCommands(t *testing.T) {
+	commands, err := ConfiguredCommands(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hidden := func(definitions []*discordgo.ApplicationCommand) map[string]int64 {
+		got := make(map[string]int64)
+		for _, definition := range definitions {
+			if definition.DefaultMemberPermissions != nil {
+				got[definition.Name] = *definition.DefaultMemberPermissions
+			}
+		}
+		return got
+	}
+
+	// /whitelist list is for everyone
+	got := hidden(ApplicationCommands(commands, &Authorization{Permissions: discordgo.PermissionManageServer}))
+	if len(got) != 1 || got["mc"] != discordgo.PermissionManageServer {
+		t.Fatalf("got %v hidden, want only mc behind manage server", got)
+	}
+
+	// admins given by role or user id wouldn't see them either
+	for _, admins := range []*Authorization{
+		{RoleIds: []string{"1234"}, Permissions: discordgo.PermissionManageServer},
+		{UserIds: []string{"5678"}, Permissions: discordgo.PermissionManageServer},
+		{},
+	} {
+		if got := hidden(ApplicationCommands(commands, admins)); len(got) != 0 {
+			t.Fatalf("got %v hidden for %+v, want none", got, admins)
+		}
+	}
+
+	// the registry itself isn't changed
+	for _, cmd := range commands {
+		if cmd.Definition.DefaultMemberPermissions != nil {
+			t.Fatalf("%s was changed in the registry", cmd.Definition.Name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package interactions
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discord rejects custom ids longer than this
+const maxCustomIdLength = 100
+
+// ComponentHandler handles message component interactions (buttons, select
+// menus) whose custom_id starts with the prefix the handler is registered with
+type ComponentHandler func(http.ResponseWriter, discordgo.Interaction, *discordgo.Session, ComponentState)
+
+// ComponentState is whatever state was encoded into a component's custom_id
+// after its prefix
+type ComponentState string
+
+// Decode unmarshals the state into v, leaving v untouched if the component
+// was created without any state
+func (state ComponentState) Decode(v interface{}) error {
+	if state == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(state), v)
+}
+
+// componentCustomId encodes state into a custom_id that routes back to the
+// handler registered for prefix. state may be nil.
+func componentCustomId(prefix string, state interface{}) (string, error) {
+	if state == nil {
+		return prefix, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+
+	customId := prefix + ":" + string(data)
+	if len(customId) > maxCustomIdLength {
+		return "", fmt.Errorf("custom id for '%s' is too long (%d > %d)", prefix, len(customId), maxCustomIdLength)
+	}
+	return customId, nil
+}
+
+func parseCustomId(customId string) (prefix string, state ComponentState) {
+	prefix, rawState, _ := strings.Cut(customId, ":")
+	return prefix, ComponentState(rawState)
+}
+
+func (srv *Server) routeMessageComponent(w http.ResponseWriter, event discordgo.Interaction) {
+	prefix, state := parseCustomId(event.MessageComponentData().CustomID)
+	handler, ok := srv.components[prefix]
+	if !ok {
+		log.Printf("no handler for message component: %s", prefix)
+		writeResponse(w, http.StatusUnprocessableEntity, "unknown message component")
+		return
+	}
+	if !srv.authorize(w, event, prefix) {
+		return
+	}
+	handler(w, event, srv.s, state)
+}
+
+// deferredComponent is deferred for message component handlers
+func (srv *Server) deferredComponent(handler ComponentHandler) ComponentHandler {
+	return func(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState) {
+		srv.deferred(func(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+			handler(w, event, s, state)
+		})(w, event, s)
+	}
+}
+
+// updateMessage replaces the message the component was attached to
+func updateMessage(w http.ResponseWriter, data *discordgo.InteractionResponseData) {
+	respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+}
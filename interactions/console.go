@@ -0,0 +1,322 @@
+package interactions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/rcon"
+)
+
+const (
+	consolePagePrefix = "console_page"
+
+	// leaves room for the command and page number within discord's 2000
+	// character message limit
+	consolePageSize = 1800
+
+	// interaction tokens expire after 15 minutes, so buttons can't be used
+	// after that anyway
+	consoleOutputTTL = 15 * time.Minute
+
+	// deferred handlers have until the interaction token expires, but nobody
+	// wants to wait that long
+	consoleTimeout = 30 * time.Second
+)
+
+type consolePageState struct {
+	Id   string `json:"id"`
+	Page int    `json:"p"`
+}
+
+// ConsoleCommand builds the /mc command from the allowlisted templates
+func ConsoleCommand(templates []*ConsoleTemplate) *Command {
+	byName := make(map[string]*ConsoleTemplate, len(templates))
+	options := make([]*discordgo.ApplicationCommandOption, len(templates))
+	adminOnly := make([]string, len(templates))
+	for i, t := range templates {
+		byName[t.Name] = t
+		options[i] = t.subcommand()
+		adminOnly[i] = "mc " + t.Name
+	}
+
+	return &Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "mc",
+			Description: "run an allowlisted command on the minecraft server",
+			Options:     options,
+		},
+		Handler: func(srv *Server, w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+			srv.console(w, event, s, byName)
+		},
+		Deferred:     true,
+		Autocomplete: (*Server).consoleAutocomplete,
+		AdminOnly:    adminOnly,
+	}
+}
+
+// ConfiguredCommands is the registry with /mc built from consoleTemplates,
+// or DefaultConsoleTemplates if there aren't any, and /uptime if uptime is
+// monitored
+func ConfiguredCommands(consoleTemplates []string, uptimeMonitored bool) ([]*Command, error) {
+	templates, err := ParseConsoleTemplates(consoleTemplates)
+	if err != nil {
+		return nil, err
+	}
+	commands := make([]*Command, 0, len(Commands)+2)
+	commands = append(commands, Commands...)
+	if uptimeMonitored {
+		commands = append(commands, UptimeCommand)
+	}
+	return append(commands, ConsoleCommand(templates)), nil
+}
+
+func (srv *Server) console(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, templates map[string]*ConsoleTemplate) {
+	subcommand := event.ApplicationCommandData().Options[0]
+	template, ok := templates[subcommand.Name]
+	if !ok {
+		log.Printf("invalid console subcommand: %s", subcommand.Name)
+		writeResponse(w, http.StatusUnprocessableEntity, "invalid mc subcommand")
+		return
+	}
+
+	command, err := template.Render(subcommand.Options)
+	if err != nil {
+		writeResponse(w, http.StatusOK, err.Error())
+		return
+	}
+
+	srv.auditConsoleCommand(s, event, command)
+
+	ctx, cancel := context.WithTimeout(context.Background(), consoleTimeout)
+	defer cancel()
+	output, err := srv.rcon.Send(ctx, command)
+	if err != nil {
+		log.Printf("error running console command '%s': %s", command, err.Error())
+		writeResponse(w, http.StatusFailedDependency, err.Error())
+		return
+	}
+
+	pages := paginateConsoleOutput(rcon.StripFormatting(output))
+	// on lambda the page buttons would mostly land on another instance,
+	// which wouldn't have the output, so only the first page is shown
+	var id string
+	if !srv.cfg.InlineDeferred {
+		id, err = srv.consoleOutputs.save(command, pages)
+		if err != nil {
+			log.Printf("error saving console output: %s", err.Error())
+			writeResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+	}
+	srv.respondWithConsolePage(w, discordgo.InteractionResponseChannelMessageWithSource, id, command, pages, 0)
+}
+
+// auditConsoleCommand records who ran what, in the logs and in the audit
+// channel if there is one
+func (srv *Server) auditConsoleCommand(s *discordgo.Session, event discordgo.Interaction, command string) {
+	var userId, username string
+	if event.Member != nil && event.Member.User != nil {
+		userId = event.Member.User.ID
+		username = event.Member.User.String()
+	}
+	log.Printf("audit: %s (%s) ran console command: %s", username, userId, command)
+
+	if srv.cfg.ConsoleAuditChannelId == "" {
+		return
+	}
+	_, err := s.ChannelMessageSendComplex(srv.cfg.ConsoleAuditChannelId, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<@%s> ran `%s`", userId, strings.ReplaceAll(command, "`", "'")),
+		// don't ping the admin every time they run something
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("error posting console audit log: %s", err.Error())
+	}
+}
+
+func (srv *Server) consolePage(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState) {
+	var pageState consolePageState
+	err := state.Decode(&pageState)
+	if err != nil {
+		log.Printf("error decoding console page state: %s", err)
+		writeResponse(w, http.StatusBadRequest, "invalid console page")
+		return
+	}
+
+	command, pages, ok := srv.consoleOutputs.load(pageState.Id)
+	if !ok {
+		updateMessage(w, &discordgo.InteractionResponseData{
+			Content:    "this output has expired, run the command again to see the rest of it",
+			Components: []discordgo.MessageComponent{},
+		})
+		return
+	}
+	srv.respondWithConsolePage(w, discordgo.InteractionResponseUpdateMessage, pageState.Id, command, pages, pageState.Page)
+}
+
+// respondWithConsolePage shows page of the output saved under id, with
+// buttons to page through the rest unless id is empty
+func (srv *Server) respondWithConsolePage(w http.ResponseWriter, responseType discordgo.InteractionResponseType, id, command string, pages []string, page int) {
+	if page < 0 {
+		page = 0
+	}
+	if page >= len(pages) {
+		page = len(pages) - 1
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "`%s`\n```\n%s\n```", strings.ReplaceAll(command, "`", "'"), pages[page])
+	data := &discordgo.InteractionResponseData{}
+
+	if len(pages) > 1 && id == "" {
+		fmt.Fprintf(&builder, "\npage %d/%d, the rest of the output can't be paged through here", page+1, len(pages))
+	} else if len(pages) > 1 {
+		fmt.Fprintf(&builder, "\npage %d/%d", page+1, len(pages))
+
+		previousId, err := componentCustomId(consolePagePrefix, consolePageState{Id: id, Page: page - 1})
+		if err != nil {
+			log.Printf("error preparing console buttons: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		nextId, err := componentCustomId(consolePagePrefix, consolePageState{Id: id, Page: page + 1})
+		if err != nil {
+			log.Printf("error preparing console buttons: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		data.Components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "previous",
+						Style:    discordgo.SecondaryButton,
+						CustomID: previousId,
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Label:    "next",
+						Style:    discordgo.SecondaryButton,
+						CustomID: nextId,
+						Disabled: page == len(pages)-1,
+					},
+				},
+			},
+		}
+	}
+	data.Content = builder.String()
+
+	respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+		Type: responseType,
+		Data: data,
+	})
+}
+
+// paginateConsoleOutput splits output into code block sized pages, breaking
+// on lines where possible
+func paginateConsoleOutput(output string) []string {
+	// a stray ``` would end the code block early
+	output = strings.ReplaceAll(strings.TrimSpace(output), "```", "`\u200b``")
+	if output == "" {
+		return []string{"(no output)"}
+	}
+
+	pages := []string{}
+	var page strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		for len(line) > consolePageSize {
+			if page.Len() > 0 {
+				pages = append(pages, page.String())
+				page.Reset()
+			}
+			// don't cut a character in half
+			cut := consolePageSize
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			pages = append(pages, line[:cut])
+			line = line[cut:]
+		}
+		if page.Len() > 0 && page.Len()+1+len(line) > consolePageSize {
+			pages = append(pages, page.String())
+			page.Reset()
+		}
+		if page.Len() > 0 {
+			page.WriteString("\n")
+		}
+		page.WriteString(line)
+	}
+	if page.Len() > 0 {
+		pages = append(pages, page.String())
+	}
+	return pages
+}
+
+func (srv *Server) consoleAutocomplete(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	var typed string
+	if option := focusedOption(event.ApplicationCommandData().Options); option != nil {
+		typed, _ = option.Value.(string)
+	}
+	respondWithChoices(w, matchingNames(mcuser.RecentlySeen(recentPlayerTTL), typed))
+}
+
+// consoleOutputs keeps command output around for the page buttons, since it
+// doesn't fit in a custom id
+type consoleOutputs struct {
+	mu      sync.Mutex
+	entries map[string]*consoleOutput
+}
+
+type consoleOutput struct {
+	command string
+	pages   []string
+	expires time.Time
+}
+
+func (o *consoleOutputs) save(command string, pages []string) (string, error) {
+	raw := make([]byte, 8)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(raw)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	for key, entry := range o.entries {
+		if now.After(entry.expires) {
+			delete(o.entries, key)
+		}
+	}
+	if o.entries == nil {
+		o.entries = make(map[string]*consoleOutput)
+	}
+	o.entries[id] = &consoleOutput{
+		command: command,
+		pages:   pages,
+		expires: now.Add(consoleOutputTTL),
+	}
+	return id, nil
+}
+
+func (o *consoleOutputs) load(id string) (string, []string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, ok := o.entries[id]
+	if !ok || time.Now().After(entry.expires) {
+		return "", nil, false
+	}
+	return entry.command, entry.pages, true
+}
@@ -0,0 +1,270 @@
+package interactions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/rcon"
+)
+
+// DefaultConsoleTemplates is the /mc allowlist used unless CONSOLE_TEMPLATES is set
+var DefaultConsoleTemplates = []string{
+	"say <message:text>",
+	"kick <player> [reason:text]",
+	"time set <time>",
+	"weather clear",
+	"weather rain",
+	"save-all flush",
+	"whitelist reload",
+	"list",
+}
+
+// discord allows at most this many subcommands per command
+const maxConsoleTemplates = 25
+
+type consoleParamType string
+
+const (
+	// a single word
+	consoleParamString consoleParamType = "string"
+	// the rest of the command, spaces and all
+	consoleParamText    consoleParamType = "text"
+	consoleParamInteger consoleParamType = "integer"
+	consoleParamNumber  consoleParamType = "number"
+	consoleParamBoolean consoleParamType = "boolean"
+	// a minecraft username, autocompleted from recently seen players
+	consoleParamPlayer consoleParamType = "player"
+)
+
+var consoleParamOptionTypes = map[consoleParamType]discordgo.ApplicationCommandOptionType{
+	consoleParamString:  discordgo.ApplicationCommandOptionString,
+	consoleParamText:    discordgo.ApplicationCommandOptionString,
+	consoleParamInteger: discordgo.ApplicationCommandOptionInteger,
+	consoleParamNumber:  discordgo.ApplicationCommandOptionNumber,
+	consoleParamBoolean: discordgo.ApplicationCommandOptionBoolean,
+	consoleParamPlayer:  discordgo.ApplicationCommandOptionString,
+}
+
+// one word of a template, either literal text or a parameter
+type consoleTemplatePart struct {
+	Literal string
+
+	Param    string
+	Type     consoleParamType
+	Required bool
+}
+
+// ConsoleTemplate is an allowlisted rcon command, e.g. "kick <player> [reason:text]".
+// <name> parameters are required and [name] ones optional, both can be
+// given a type after a colon and default to string, or player if named player.
+type ConsoleTemplate struct {
+	// the /mc subcommand, the template's literal words joined with dashes
+	Name     string
+	Template string
+	parts    []consoleTemplatePart
+}
+
+var (
+	consoleParamPattern       = regexp.MustCompile(`^(<([a-z0-9_]+)(?::([a-z]+))?>|\[([a-z0-9_]+)(?::([a-z]+))?\])$`)
+	consoleSubcommandSanitize = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+func ParseConsoleTemplate(template string) (*ConsoleTemplate, error) {
+	words := strings.Fields(template)
+	if len(words) == 0 {
+		return nil, errors.New("console template is empty")
+	}
+
+	parsed := &ConsoleTemplate{Template: strings.Join(words, " ")}
+	var literals []string
+	seen := make(map[string]bool)
+	for i, word := range words {
+		if !strings.HasPrefix(word, "<") && !strings.HasPrefix(word, "[") {
+			if i > 0 && parsed.parts[i-1].Param != "" && !parsed.parts[i-1].Required {
+				return nil, fmt.Errorf("console template '%s': optional parameters must come last", template)
+			}
+			parsed.parts = append(parsed.parts, consoleTemplatePart{Literal: word})
+			literals = append(literals, strings.ToLower(word))
+			continue
+		}
+
+		match := consoleParamPattern.FindStringSubmatch(word)
+		if match == nil {
+			return nil, fmt.Errorf("console template '%s': invalid parameter '%s'", template, word)
+		}
+		part := consoleTemplatePart{Param: match[2], Type: consoleParamType(match[3]), Required: true}
+		if match[4] != "" {
+			part = consoleTemplatePart{Param: match[4], Type: consoleParamType(match[5])}
+		}
+		if part.Type == "" {
+			part.Type = consoleParamString
+			if part.Param == "player" {
+				part.Type = consoleParamPlayer
+			}
+		}
+		if _, ok := consoleParamOptionTypes[part.Type]; !ok {
+			return nil, fmt.Errorf("console template '%s': unknown parameter type '%s'", template, part.Type)
+		}
+		if seen[part.Param] {
+			return nil, fmt.Errorf("console template '%s': parameter '%s' is used more than once", template, part.Param)
+		}
+		seen[part.Param] = true
+		if i > 0 && parsed.parts[i-1].Param != "" && !parsed.parts[i-1].Required && part.Required {
+			return nil, fmt.Errorf("console template '%s': optional parameters must come last", template)
+		}
+		parsed.parts = append(parsed.parts, part)
+	}
+
+	if parsed.parts[0].Literal == "" {
+		return nil, fmt.Errorf("console template '%s' has to start with the command", template)
+	}
+	for i, part := range parsed.parts {
+		if part.Type == consoleParamText && i != len(parsed.parts)-1 {
+			return nil, fmt.Errorf("console template '%s': text parameters must come last", template)
+		}
+	}
+
+	name := consoleSubcommandSanitize.ReplaceAllString(strings.Join(literals, "-"), "_")
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	parsed.Name = name
+	return parsed, nil
+}
+
+func (t *ConsoleTemplate) subcommand() *discordgo.ApplicationCommandOption {
+	description := "runs " + t.Template
+	if len(description) > 100 {
+		description = description[:100]
+	}
+	subcommand := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        t.Name,
+		Description: description,
+	}
+	for _, part := range t.parts {
+		if part.Param == "" {
+			continue
+		}
+		subcommand.Options = append(subcommand.Options, &discordgo.ApplicationCommandOption{
+			Type:         consoleParamOptionTypes[part.Type],
+			Name:         part.Param,
+			Description:  fmt.Sprintf("%s (%s)", part.Param, part.Type),
+			Required:     part.Required,
+			Autocomplete: part.Type == consoleParamPlayer,
+		})
+	}
+	return subcommand
+}
+
+// Render fills the template in with the options the command was invoked with
+func (t *ConsoleTemplate) Render(options []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	values := make(map[string]interface{}, len(options))
+	for _, option := range options {
+		values[option.Name] = option.Value
+	}
+
+	words := make([]string, 0, len(t.parts))
+	for _, part := range t.parts {
+		if part.Literal != "" {
+			words = append(words, part.Literal)
+			continue
+		}
+
+		value, ok := values[part.Param]
+		if !ok {
+			if part.Required {
+				return "", fmt.Errorf("%s is required", part.Param)
+			}
+			continue
+		}
+		word, err := renderConsoleParam(part, value)
+		if err != nil {
+			return "", err
+		}
+		words = append(words, word)
+	}
+
+	command := strings.Join(words, " ")
+	if len(command) > rcon.MaxCommandLength {
+		return "", fmt.Errorf("the command can be at most %d bytes long", rcon.MaxCommandLength)
+	}
+	return command, nil
+}
+
+func renderConsoleParam(part consoleTemplatePart, value interface{}) (string, error) {
+	switch part.Type {
+	case consoleParamInteger:
+		// discord sends every number as a float
+		number, ok := value.(float64)
+		if !ok {
+			return "", fmt.Errorf("%s has to be a whole number", part.Param)
+		}
+		return strconv.FormatInt(int64(number), 10), nil
+	case consoleParamNumber:
+		number, ok := value.(float64)
+		if !ok {
+			return "", fmt.Errorf("%s has to be a number", part.Param)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case consoleParamBoolean:
+		boolean, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("%s has to be true or false", part.Param)
+		}
+		return strconv.FormatBool(boolean), nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s has to be text", part.Param)
+	}
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return "", fmt.Errorf("%s can't be empty", part.Param)
+	case strings.ContainsAny(text, "\r\n"):
+		return "", fmt.Errorf("%s can't span multiple lines", part.Param)
+	case part.Type == consoleParamPlayer && !minecraftUsernamePattern.MatchString(text):
+		return "", fmt.Errorf("'%s' is not a valid minecraft username", text)
+	case part.Type == consoleParamString && strings.ContainsAny(text, " \t"):
+		return "", fmt.Errorf("%s has to be a single word", part.Param)
+	}
+	return text, nil
+}
+
+// ParseConsoleTemplates parses the /mc allowlist, falling back to
+// DefaultConsoleTemplates when templates is empty
+func ParseConsoleTemplates(templates []string) ([]*ConsoleTemplate, error) {
+	if len(templates) == 0 {
+		templates = DefaultConsoleTemplates
+	}
+	if len(templates) > maxConsoleTemplates {
+		return nil, fmt.Errorf("at most %d console templates are allowed, got %d", maxConsoleTemplates, len(templates))
+	}
+
+	var errs []error
+	parsed := make([]*ConsoleTemplate, 0, len(templates))
+	names := make(map[string]string)
+	for _, template := range templates {
+		t, err := ParseConsoleTemplate(template)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := names[t.Name]; ok {
+			errs = append(errs, fmt.Errorf("console templates '%s' and '%s' would both be /mc %s", other, t.Template, t.Name))
+			continue
+		}
+		names[t.Name] = t.Template
+		parsed = append(parsed, t)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return parsed, nil
+}
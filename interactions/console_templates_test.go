@@ -0,0 +1,243 @@
+package interactions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseConsoleTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []consoleTemplatePart
+		wantName string
+		wantErr  string
+	}{
+		{
+			name:     "literal",
+			template: "weather  clear",
+			want:     []consoleTemplatePart{{Literal: "weather"}, {Literal: "clear"}},
+			wantName: "weather-clear",
+		},
+		{
+			name:     "params",
+			template: "kick <player> [reason:text]",
+			want: []consoleTemplatePart{
+				{Literal: "kick"},
+				{Param: "player", Type: consoleParamPlayer, Required: true},
+				{Param: "reason", Type: consoleParamText},
+			},
+			wantName: "kick",
+		},
+		{
+			name:     "typed",
+			template: "xp add <target> <amount:integer> [levels:boolean]",
+			want: []consoleTemplatePart{
+				{Literal: "xp"},
+				{Literal: "add"},
+				{Param: "target", Type: consoleParamString, Required: true},
+				{Param: "amount", Type: consoleParamInteger, Required: true},
+				{Param: "levels", Type: consoleParamBoolean},
+			},
+			wantName: "xp-add",
+		},
+		{
+			name:     "empty",
+			template: "  ",
+			wantErr:  "empty",
+		},
+		{
+			name:     "starts with a param",
+			template: "<command:text>",
+			wantErr:  "has to start with the command",
+		},
+		{
+			name:     "unknown type",
+			template: "give <player> <item:json>",
+			wantErr:  "unknown parameter type 'json'",
+		},
+		{
+			name:     "malformed param",
+			template: "say <Message>",
+			wantErr:  "invalid parameter '<Message>'",
+		},
+		{
+			name:     "unclosed param",
+			template: "say <message",
+			wantErr:  "invalid parameter '<message'",
+		},
+		{
+			name:     "repeated param",
+			template: "tp <player> <player>",
+			wantErr:  "used more than once",
+		},
+		{
+			name:     "required after optional",
+			template: "kick [reason] <player>",
+			wantErr:  "optional parameters must come last",
+		},
+		{
+			name:     "literal after optional",
+			template: "time set [time] now",
+			wantErr:  "optional parameters must come last",
+		},
+		{
+			name:     "text before the end",
+			template: "say <message:text> <player>",
+			wantErr:  "text parameters must come last",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConsoleTemplate(tt.template)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.parts, tt.want) {
+				t.Errorf("got parts %+v, want %+v", got.parts, tt.want)
+			}
+			if got.Name != tt.wantName {
+				t.Errorf("got name %q, want %q", got.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestParseConsoleTemplates(t *testing.T) {
+	parsed, err := ParseConsoleTemplates(nil)
+	if err != nil {
+		t.Fatalf("default templates don't parse: %s", err)
+	}
+	if len(parsed) != len(DefaultConsoleTemplates) {
+		t.Fatalf("got %d default templates, want %d", len(parsed), len(DefaultConsoleTemplates))
+	}
+
+	_, err = ParseConsoleTemplates([]string{"say <message:text>", "say [message]"})
+	if err == nil || !strings.Contains(err.Error(), "would both be /mc say") {
+		t.Fatalf("got error %v, want a name collision", err)
+	}
+}
+
+func TestRenderConsoleTemplate(t *testing.T) {
+	option := func(name string, value interface{}) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Value: value}
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		options  []*discordgo.ApplicationCommandInteractionDataOption
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "text",
+			template: "say <message:text>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("message", "  hello there ")},
+			want:     "say hello there",
+		},
+		{
+			name:     "optional left out",
+			template: "kick <player> [reason:text]",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("player", "bsdlp")},
+			want:     "kick bsdlp",
+		},
+		{
+			name:     "numbers",
+			template: "xp add <target> <amount:integer> <scale:number> [levels:boolean]",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("target", "bsdlp"),
+				option("amount", float64(30)),
+				option("scale", 1.5),
+				option("levels", true),
+			},
+			want: "xp add bsdlp 30 1.5 true",
+		},
+		{
+			// minecraft doesn't chain commands, so a semicolon is just text
+			name:     "semicolon in text",
+			template: "say <message:text>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("message", "hi; op bsdlp")},
+			want:     "say hi; op bsdlp",
+		},
+		{
+			name:     "missing required",
+			template: "kick <player>",
+			wantErr:  "player is required",
+		},
+		{
+			name:     "newline in text",
+			template: "say <message:text>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("message", "hi\nop bsdlp")},
+			wantErr:  "can't span multiple lines",
+		},
+		{
+			name:     "carriage return in a word",
+			template: "time set <time>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("time", "day\rop")},
+			wantErr:  "can't span multiple lines",
+		},
+		{
+			name:     "space in a word",
+			template: "time set <time>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("time", "day op bsdlp")},
+			wantErr:  "has to be a single word",
+		},
+		{
+			name:     "semicolon in a player",
+			template: "kick <player>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("player", "bsdlp;op")},
+			wantErr:  "not a valid minecraft username",
+		},
+		{
+			name:     "blank",
+			template: "say <message:text>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("message", "   ")},
+			wantErr:  "can't be empty",
+		},
+		{
+			name:     "wrong type",
+			template: "xp add <target> <amount:integer>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("target", "bsdlp"), option("amount", "30")},
+			wantErr:  "has to be a whole number",
+		},
+		{
+			name:     "too long",
+			template: "say <message:text>",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{option("message", strings.Repeat("a", 1500))},
+			wantErr:  "at most 1446 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := ParseConsoleTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := template.Render(tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package interactions
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPaginateConsoleOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			output: " \n",
+			want:   []string{"(no output)"},
+		},
+		{
+			name:   "one page",
+			output: "There are 0 of a max of 20 players online:\n",
+			want:   []string{"There are 0 of a max of 20 players online:"},
+		},
+		{
+			name:   "code fence",
+			output: "```",
+			want:   []string{"`\u200b``"},
+		},
+		{
+			name:   "breaks on lines",
+			output: strings.Repeat("a", consolePageSize-1) + "\n" + "bb",
+			want:   []string{strings.Repeat("a", consolePageSize-1), "bb"},
+		},
+		{
+			name:   "long line",
+			output: strings.Repeat("a", consolePageSize+2),
+			want:   []string{strings.Repeat("a", consolePageSize), "aa"},
+		},
+		{
+			// é is two bytes and would straddle the page boundary
+			name:   "long line of runes",
+			output: "a" + strings.Repeat("é", consolePageSize/2),
+			want:   []string{"a" + strings.Repeat("é", consolePageSize/2-1), "é"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := paginateConsoleOutput(tt.output)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d pages, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("page %d: got %q, want %q", i, got[i], tt.want[i])
+				}
+				if !utf8.ValidString(got[i]) {
+					t.Errorf("page %d isn't valid utf-8", i)
+				}
+			}
+		})
+	}
+}
+
+func TestConsolePageButtons(t *testing.T) {
+	srv := &Server{}
+	pages := []string{"one", "two"}
+
+	rec := newInteractionRecorder()
+	srv.respondWithConsolePage(rec, discordgo.InteractionResponseChannelMessageWithSource, "abcd", "list", pages, 0)
+	if len(rec.response.Data.Components) != 1 {
+		t.Fatalf("got %d component rows, want the page buttons", len(rec.response.Data.Components))
+	}
+
+	// output that wasn't saved can't be paged through
+	rec = newInteractionRecorder()
+	srv.respondWithConsolePage(rec, discordgo.InteractionResponseChannelMessageWithSource, "", "list", pages, 0)
+	if len(rec.response.Data.Components) != 0 {
+		t.Fatalf("got %d component rows, want no page buttons", len(rec.response.Data.Components))
+	}
+	if !strings.Contains(rec.response.Data.Content, "page 1/2") {
+		t.Fatalf("got %q, want it to say there are more pages", rec.response.Data.Content)
+	}
+}
@@ -0,0 +1,194 @@
+package interactions
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// deferred wraps a handler that may take longer than discord's 3 second
+// deadline. the interaction is acknowledged right away and the handler runs in
+// the background, with whatever it responds with sent as an edit to the
+// original response. for message components the original response is the
+// message the component is attached to. ephemeral responses are sent as a
+// follow-up instead, since the original response can't be made ephemeral
+// once it's acknowledged.
+func (srv *Server) deferred(handler InteractionHandler) InteractionHandler {
+	return srv.deferResponse(handler, 0)
+}
+
+// deferredEphemeral is deferred for commands whose response only the member
+// who ran them should see
+func (srv *Server) deferredEphemeral(handler InteractionHandler) InteractionHandler {
+	return srv.deferResponse(handler, discordgo.MessageFlagsEphemeral)
+}
+
+func (srv *Server) deferResponse(handler InteractionHandler, flags discordgo.MessageFlags) InteractionHandler {
+	return func(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+		ack := discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		}
+		if flags != 0 {
+			ack.Data = &discordgo.InteractionResponseData{Flags: flags}
+		}
+		if event.Type == discordgo.InteractionMessageComponent {
+			ack.Type = discordgo.InteractionResponseDeferredMessageUpdate
+		}
+
+		if srv.cfg.InlineDeferred {
+			// acknowledged through the callback endpoint since the webhook
+			// response isn't sent until the handler is done
+			err := s.InteractionRespond(&event, &ack)
+			if err != nil {
+				log.Printf("error acknowledging interaction %s: %s", event.ID, err.Error())
+				writeResponse(w, http.StatusInternalServerError, "internal server error")
+				return
+			}
+			srv.runDeferred(handler, event, s, flags)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		respondToInteraction(w, http.StatusOK, ack)
+
+		srv.deferredWork.Add(1)
+		go func() {
+			defer srv.deferredWork.Done()
+			srv.runDeferred(handler, event, s, flags)
+		}()
+	}
+}
+
+// runDeferred runs handler and edits the acknowledged response with what it
+// responded with, or an error if it panicked. flags are the ack's.
+func (srv *Server) runDeferred(handler InteractionHandler, event discordgo.Interaction, s *discordgo.Session, flags discordgo.MessageFlags) {
+	rec := newInteractionRecorder()
+	func() {
+		// net/http isn't here to recover, and a panic would take the whole
+		// server down with it
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic handling interaction %s: %v\n%s", event.ID, r, debug.Stack())
+				rec.response = nil
+				writeResponse(rec, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+		handler(rec, event, s)
+	}()
+
+	if rec.ephemeral() && flags&discordgo.MessageFlagsEphemeral == 0 {
+		srv.followUpEphemeral(rec, event, s)
+		return
+	}
+
+	_, err := s.InteractionResponseEdit(&event, rec.webhookEdit())
+	if err != nil {
+		log.Printf("error editing deferred response for interaction %s: %s", event.ID, err.Error())
+	}
+}
+
+// followUpEphemeral sends an ephemeral response to a public ack as a
+// follow-up, editing it in would show it to everyone
+func (srv *Server) followUpEphemeral(rec *interactionRecorder, event discordgo.Interaction, s *discordgo.Session) {
+	_, err := s.FollowupMessageCreate(&event, true, rec.webhookParams())
+	if err != nil {
+		log.Printf("error sending ephemeral follow-up for interaction %s: %s", event.ID, err.Error())
+		return
+	}
+
+	// the component's message is left as it is rather than deleted
+	if event.Type == discordgo.InteractionMessageComponent {
+		return
+	}
+	err = s.InteractionResponseDelete(&event)
+	if err != nil {
+		log.Printf("error deleting deferred response for interaction %s: %s", event.ID, err.Error())
+	}
+}
+
+// interactionRecorder is handed to deferred handlers in place of the webhook
+// response writer so their response can be replayed through the follow-up api
+type interactionRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+
+	response *discordgo.InteractionResponse
+	files    []*discordgo.File
+}
+
+func newInteractionRecorder() *interactionRecorder {
+	return &interactionRecorder{
+		header:     make(http.Header),
+		statusCode: http.StatusOK,
+	}
+}
+
+func (rec *interactionRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *interactionRecorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+func (rec *interactionRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+}
+
+func (rec *interactionRecorder) record(statusCode int, response *discordgo.InteractionResponse, files []*discordgo.File) {
+	// keep the first response, same as the webhook would
+	if rec.response != nil {
+		return
+	}
+	rec.statusCode = statusCode
+	rec.response = response
+	rec.files = files
+}
+
+// ephemeral is whether the handler responded with an ephemeral message
+func (rec *interactionRecorder) ephemeral() bool {
+	return rec.response != nil && rec.response.Data != nil && rec.response.Data.Flags&discordgo.MessageFlagsEphemeral != 0
+}
+
+// webhookParams is the recorded response as an ephemeral follow-up
+func (rec *interactionRecorder) webhookParams() *discordgo.WebhookParams {
+	data := rec.response.Data
+	return &discordgo.WebhookParams{
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+		Files:      append(data.Files, rec.files...),
+		Flags:      discordgo.MessageFlagsEphemeral,
+	}
+}
+
+func (rec *interactionRecorder) webhookEdit() *discordgo.WebhookEdit {
+	if rec.response == nil || rec.response.Data == nil {
+		// handlers that wrote a plain http error instead of an interaction response
+		content := strings.TrimSpace(rec.body.String())
+		if content == "" {
+			content = http.StatusText(rec.statusCode)
+		}
+		return &discordgo.WebhookEdit{
+			Content: &content,
+		}
+	}
+
+	data := rec.response.Data
+	edit := &discordgo.WebhookEdit{
+		Content: &data.Content,
+		Files:   append(data.Files, rec.files...),
+	}
+	if data.Embeds != nil {
+		edit.Embeds = &data.Embeds
+	}
+	if data.Components != nil {
+		edit.Components = &data.Components
+	}
+	return edit
+}
@@ -0,0 +1,35 @@
+package interactions
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestInteractionRecorderEphemeral(t *testing.T) {
+	rec := newInteractionRecorder()
+	writeEphemeralResponse(rec, "pick either a username or a member")
+	// only the first response counts, same as the webhook
+	writeResponse(rec, http.StatusOK, "everyone sees this")
+	if !rec.ephemeral() {
+		t.Fatal("want the recorded response to be ephemeral")
+	}
+	params := rec.webhookParams()
+	if params.Content != "pick either a username or a member" || params.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Fatalf("got %+v, want an ephemeral follow-up with the first response", params)
+	}
+
+	public := newInteractionRecorder()
+	writeResponse(public, http.StatusOK, "everyone sees this")
+	if public.ephemeral() {
+		t.Fatal("want a public response not to be ephemeral")
+	}
+
+	// handlers that wrote a plain http error aren't ephemeral either
+	plain := newInteractionRecorder()
+	http.Error(plain, "internal server error", http.StatusInternalServerError)
+	if plain.ephemeral() {
+		t.Fatal("want a plain http error not to be ephemeral")
+	}
+}
@@ -2,42 +2,187 @@ package interactions
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/bwmarrin/discordgo"
 	"github.com/tonkat-su/bot/leaderboard"
 )
 
+const (
+	leaderboardPagePrefix = "leaderboard_page"
+	leaderboardPageSize   = 10
+)
+
+// leaderboardOptions are the /leaderboard options, kept in the page buttons
+// so every page covers the same window
+type leaderboardOptions struct {
+	Period string `json:"w,omitempty"`
+	Since  string `json:"s,omitempty"`
+	Until  string `json:"u,omitempty"`
+}
+
+type leaderboardPageState struct {
+	Page int `json:"p"`
+	leaderboardOptions
+}
+
+// leaderboardPeriodChoices offers every period, the first is the default
+func leaderboardPeriodChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(leaderboard.Periods))
+	for i, period := range leaderboard.Periods {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  period.Name(),
+			Value: string(period),
+		}
+	}
+	return choices
+}
+
+func readLeaderboardOptions(options []*discordgo.ApplicationCommandInteractionDataOption) leaderboardOptions {
+	var opts leaderboardOptions
+	for _, v := range options {
+		value, _ := v.Value.(string)
+		switch v.Name {
+		case "period":
+			opts.Period = value
+		case "since":
+			opts.Since = value
+		case "until":
+			opts.Until = value
+		}
+	}
+	return opts
+}
+
+// query is the window the options cover at now, either a named period or
+// the days from since through until
+func (opts leaderboardOptions) query(now time.Time) (*leaderboard.StandingsQuery, error) {
+	if opts.Since == "" && opts.Until == "" {
+		period := leaderboard.PeriodLastWeek
+		if opts.Period != "" {
+			period = leaderboard.Period(opts.Period)
+		}
+		return period.Query(now)
+	}
+
+	if opts.Period != "" {
+		return nil, errors.New("pick either a period or since and until, not both")
+	}
+	if opts.Since == "" {
+		return nil, errors.New("since is required with until")
+	}
+	since, err := time.ParseInLocation(leaderboard.DateLayout, opts.Since, now.Location())
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a date like %s", opts.Since, leaderboard.DateLayout)
+	}
+	until := now
+	if opts.Until != "" {
+		until, err = time.ParseInLocation(leaderboard.DateLayout, opts.Until, now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a date like %s", opts.Until, leaderboard.DateLayout)
+		}
+	}
+
+	query, err := leaderboard.RangeQuery(since, until)
+	if errors.Is(err, leaderboard.ErrInvalidRange) {
+		return nil, errors.New("since has to be on or before until")
+	}
+	return query, err
+}
+
+// parseLeaderboardOptions rejects windows that can't be queried before the
+// command is deferred
+func parseLeaderboardOptions(data discordgo.ApplicationCommandInteractionData) error {
+	_, err := readLeaderboardOptions(data.Options).query(time.Now())
+	return err
+}
+
 func (srv *Server) leaderboard(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
-	awsCfg, err := config.LoadDefaultConfig(context.Background())
+	opts := readLeaderboardOptions(event.ApplicationCommandData().Options)
+	srv.respondWithStandings(w, s, discordgo.InteractionResponseChannelMessageWithSource, opts, 0)
+}
+
+// leaderboardPage swaps the leaderboard embed for another page of standings
+func (srv *Server) leaderboardPage(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState) {
+	var pageState leaderboardPageState
+	err := state.Decode(&pageState)
 	if err != nil {
-		log.Printf("error loading aws config: %s", err)
-		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		log.Printf("error decoding leaderboard page state: %s", err)
+		writeResponse(w, http.StatusBadRequest, "invalid leaderboard page")
 		return
 	}
+	srv.respondWithStandings(w, s, discordgo.InteractionResponseUpdateMessage, pageState.leaderboardOptions, pageState.Page)
+}
+
+// leaderboardService reads scores from the local store if one is configured,
+// and cloudwatch otherwise
+func (srv *Server) leaderboardService() (*leaderboard.Service, error) {
+	if srv.cfg.LeaderboardStorePath != "" {
+		return leaderboard.NewService(leaderboard.NewBoltStore(srv.cfg.LeaderboardStorePath)), nil
+	}
 
-	board, err := leaderboard.New(awsCfg, &leaderboard.Config{
+	awsCfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return leaderboard.NewService(leaderboard.NewCloudwatchStore(awsCfg, &leaderboard.Config{
 		NamespacePrefix: srv.cfg.MinecraftServerName,
-	})
+	})), nil
+}
+
+func (srv *Server) respondWithStandings(w http.ResponseWriter, s *discordgo.Session, responseType discordgo.InteractionResponseType, opts leaderboardOptions, page int) {
+	query, err := opts.query(time.Now())
+	if err != nil {
+		log.Printf("error reading leaderboard options: %s", err)
+		writeResponse(w, http.StatusBadRequest, "invalid leaderboard period")
+		return
+	}
+
+	board, err := srv.leaderboardService()
 	if err != nil {
 		log.Printf("error instantiating leaderboard: %s", err)
 		writeResponse(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	standings, err := board.GetStandings(context.Background())
+	standings, err := board.GetStandings(context.Background(), query)
 	if err != nil {
 		log.Printf("error fetching leaderboard: %s", err)
 		writeResponse(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
+	pageCount := (len(standings.SortedStandings) + leaderboardPageSize - 1) / leaderboardPageSize
+	if pageCount == 0 {
+		pageCount = 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	if page >= pageCount {
+		page = pageCount - 1
+	}
+
+	start := page * leaderboardPageSize
+	end := start + leaderboardPageSize
+	if end > len(standings.SortedStandings) {
+		end = len(standings.SortedStandings)
+	}
+
 	messageEmbed, err := leaderboard.PrepareStandingsEmbed(&leaderboard.PrepareStandingsEmbedRequest{
-		Standings: standings,
-		Session:   s,
-		GuildId:   srv.cfg.DiscordGuildId,
+		Standings: &leaderboard.Standings{
+			SortedStandings: standings.SortedStandings[start:end],
+			LastUpdated:     standings.LastUpdated,
+			Description:     standings.Description,
+		},
+		Session: s,
+		GuildId: srv.cfg.DiscordGuildId,
+		Links:   srv.linkDirectory(),
 	})
 	if err != nil {
 		log.Printf("error preparing standings: %s", err)
@@ -45,11 +190,51 @@ func (srv *Server) leaderboard(w http.ResponseWriter, event discordgo.Interactio
 		return
 	}
 
+	data := &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{messageEmbed},
+	}
+
+	if pageCount > 1 {
+		messageEmbed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("page %d/%d", page+1, pageCount),
+		}
+
+		previousId, err := componentCustomId(leaderboardPagePrefix, leaderboardPageState{Page: page - 1, leaderboardOptions: opts})
+		if err != nil {
+			log.Printf("error preparing leaderboard buttons: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		nextId, err := componentCustomId(leaderboardPagePrefix, leaderboardPageState{Page: page + 1, leaderboardOptions: opts})
+		if err != nil {
+			log.Printf("error preparing leaderboard buttons: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		data.Components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "previous",
+						Style:    discordgo.SecondaryButton,
+						CustomID: previousId,
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Label:    "next",
+						Style:    discordgo.SecondaryButton,
+						CustomID: nextId,
+						Disabled: page == pageCount-1,
+					},
+				},
+			},
+		}
+	}
+
 	response := discordgo.InteractionResponse{
-		Type: 4,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{messageEmbed},
-		},
+		Type: responseType,
+		Data: data,
 	}
 	respondToInteraction(w, http.StatusOK, response)
 }
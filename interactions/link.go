@@ -0,0 +1,98 @@
+package interactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/accounts"
+	"github.com/tonkat-su/bot/mcuser"
+)
+
+// /link is deferred, and starting one looks the player up as well as
+// preparing the server over rcon, which rconTimeout is too short for
+const linkTimeout = 15 * time.Second
+
+func (srv *Server) link(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	if event.Member == nil || event.Member.User == nil {
+		writeEphemeralResponse(w, "linking only works in the server")
+		return
+	}
+	if srv.links == nil {
+		writeEphemeralResponse(w, "account linking isn't enabled, ask a server admin")
+		return
+	}
+	discordUserId := event.Member.User.ID
+
+	linker := &accounts.Linker{
+		Store: srv.links,
+		Verifier: &accounts.TriggerVerifier{
+			Rcon: srv.rcon,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), linkTimeout)
+	defer cancel()
+	subcommand := event.ApplicationCommandData().Options[0]
+	switch subcommand.Name {
+	case "start":
+		var username string
+		for _, v := range subcommand.Options {
+			if v.Name == "username" {
+				username, _ = v.Value.(string)
+			}
+		}
+
+		name, instructions, err := linker.Start(ctx, discordUserId, username)
+		if errors.Is(err, mcuser.ErrPlayerNotFound) {
+			writeEphemeralResponse(w, fmt.Sprintf("couldn't find a minecraft account named %s", username))
+			return
+		}
+		if err != nil {
+			log.Printf("error starting link for %s: %s", username, err.Error())
+			writeEphemeralResponse(w, "error starting link, is the server up?")
+			return
+		}
+		writeEphemeralResponse(w, fmt.Sprintf("to prove %s is yours, %s while logged in, then run `/link verify`", name, instructions))
+	case "verify":
+		link, err := linker.Verify(ctx, discordUserId)
+		if errors.Is(err, accounts.ErrNoChallenge) || errors.Is(err, accounts.ErrChallengeExpired) || errors.Is(err, accounts.ErrCodeMismatch) {
+			writeEphemeralResponse(w, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("error verifying link for %s: %s", discordUserId, err.Error())
+			writeEphemeralResponse(w, "error verifying link, is the server up?")
+			return
+		}
+		writeEphemeralResponse(w, fmt.Sprintf("linked to %s", link.MinecraftName))
+	case "remove":
+		err := srv.links.DeleteLink(ctx, discordUserId)
+		if err != nil {
+			log.Printf("error removing link for %s: %s", discordUserId, err.Error())
+			writeEphemeralResponse(w, "internal server error")
+			return
+		}
+		writeEphemeralResponse(w, "unlinked your minecraft account")
+	default:
+		log.Printf("invalid command: %s", subcommand.Name)
+		writeResponse(w, http.StatusUnprocessableEntity, "invalid link subcommand")
+	}
+}
+
+// linkDirectory is best effort, rendering without mentions beats not rendering at all
+func (srv *Server) linkDirectory() *accounts.Directory {
+	if srv.links == nil {
+		return nil
+	}
+	dir, err := accounts.LoadDirectory(context.Background(), srv.links)
+	if err != nil {
+		log.Printf("error loading account links: %s", err.Error())
+		return nil
+	}
+	return dir
+}
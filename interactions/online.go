@@ -8,42 +8,67 @@ import (
 	"github.com/tonkat-su/bot/online"
 )
 
+const onlineRefreshPrefix = "online_refresh"
+
 func (srv *Server) online(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	srv.respondWithStatus(w, s, discordgo.InteractionResponseChannelMessageWithSource)
+}
+
+// onlineRefresh re-renders the status embed in place when the refresh button is pressed
+func (srv *Server) onlineRefresh(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, _ ComponentState) {
+	srv.respondWithStatus(w, s, discordgo.InteractionResponseUpdateMessage)
+}
+
+func (srv *Server) respondWithStatus(w http.ResponseWriter, s *discordgo.Session, responseType discordgo.InteractionResponseType) {
 	prepareStatusResponse, err := online.PrepareStatus(&online.PrepareStatusRequest{
-		Session:        s,
-		GuildId:        srv.cfg.DiscordGuildId,
-		ServerHostname: srv.cfg.MinecraftServerHost,
-		ServerName:     srv.cfg.MinecraftServerName,
+		Session:         s,
+		GuildId:         srv.cfg.DiscordGuildId,
+		ServerHostname:  srv.cfg.MinecraftServerHost,
+		ServerName:      srv.cfg.MinecraftServerName,
+		Links:           srv.linkDirectory(),
+		QueryPort:       srv.cfg.MinecraftQueryPort,
+		BedrockHostname: srv.cfg.BedrockServerHost,
+		FloodgatePrefix: srv.cfg.FloodgatePrefix,
 	})
 	if err != nil {
 		log.Printf("error rendering online message embed: %s", err.Error())
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
+	if prepareStatusResponse == nil {
+		// the host has no srv or a records
+		respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content: "no servers found for " + srv.cfg.MinecraftServerHost,
+			},
+		})
+		return
+	}
 
 	response := discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Type: responseType,
 		Data: &discordgo.InteractionResponseData{
 			Embeds: prepareStatusResponse.MessageEmbeds,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "refresh",
+							Style:    discordgo.SecondaryButton,
+							CustomID: onlineRefreshPrefix,
+						},
+					},
+				},
+			},
 		},
 	}
 
-	/*
-		can't use respondToInteraction because we need to use multipart response
-		to upload files
-	*/
-	contentType, responseBody, err := discordgo.MultipartBodyWithJSON(response, prepareStatusResponse.Files)
-	if err != nil {
-		log.Printf("error preparing multipart body: %s", err.Error())
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("content-type", contentType)
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(responseBody)
-	if err != nil {
-		log.Printf("error writing response body: %s", err.Error())
+	if responseType == discordgo.InteractionResponseUpdateMessage {
+		// the favicon uploaded with the message is kept, uploading it again
+		// would add another copy
+		respondToInteraction(w, http.StatusOK, response)
 		return
 	}
+	respondToInteractionWithFiles(w, http.StatusOK, response, prepareStatusResponse.Files)
 }
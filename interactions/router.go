@@ -1,27 +1,129 @@
 package interactions
 
 import (
+	"context"
 	"crypto/ed25519"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/accounts"
+	"github.com/tonkat-su/bot/bridge"
+	"github.com/tonkat-su/bot/leaderboard"
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/notifier"
+	"github.com/tonkat-su/bot/online"
+	"github.com/tonkat-su/bot/presence"
+	"github.com/tonkat-su/bot/rcon"
+	"github.com/tonkat-su/bot/serverlog"
+	"github.com/tonkat-su/bot/sessions"
+	"github.com/tonkat-su/bot/uptime"
+	"github.com/tonkat-su/bot/whitelist"
 )
 
 type Config struct {
 	DiscordToken         string `split_words:"true" required:"true"`
 	DiscordWebhookPubkey string `split_words:"true" required:"true"`
 
+	// InlineDeferred runs deferred handlers before the webhook is answered,
+	// for lambda which freezes anything still running once it has. it's set
+	// by the lambda rather than read from the environment.
+	InlineDeferred bool `ignored:"true"`
+
 	MinecraftServerName string `split_words:"true" required:"true"`
 	MinecraftServerHost string `split_words:"true" required:"true"`
 	RconPassword        string `split_words:"true" required:"true"`
 	RconHostport        string `split_words:"true" required:"true"`
 
+	// set to the server's query.port if it has enable-query on, so everyone
+	// online is listed rather than the ping's sample of up to 12 players
+	MinecraftQueryPort int `split_words:"true"`
+
+	// a bedrock server to show in /online, e.g. geyser's "host:19132". players
+	// joining through floodgate have their names prefixed with FloodgatePrefix
+	BedrockServerHost string `split_words:"true"`
+	FloodgatePrefix   string `split_words:"true" default:"."`
+
 	DiscordGuildId string `split_words:"true" required:"true"`
+
+	// who may run privileged commands like /whitelist add. AdminPermissions
+	// defaults to manage server
+	AdminRoleIds     []string `split_words:"true"`
+	AdminUserIds     []string `split_words:"true"`
+	AdminPermissions int64    `split_words:"true" default:"32"`
+
+	// where discord to minecraft account links are kept, linking is turned
+	// off without it. on lambda it has to be on an efs mount.
+	LinkStorePath string `split_words:"true"`
+
+	// whitelist requests are posted to this channel for moderators to approve.
+	// on lambda the store has to be on an efs mount to survive cold starts.
+	WhitelistRequestChannelId string `split_words:"true"`
+	WhitelistRequestStorePath string `split_words:"true" default:"whitelist_requests.json"`
+
+	// templates allowlisted for /mc, separated by semicolons, see ConsoleTemplate
+	ConsoleTemplates []string `split_words:"true" delimiter:";"`
+	// every /mc command run is posted here as well as logged
+	ConsoleAuditChannelId string `split_words:"true"`
+
+	// chat in this channel is relayed to the game and back
+	BridgeChannelId string `split_words:"true"`
+	// where to follow the server's latest.log from, needed to relay in game
+	// chat to discord. either a local path or a command that streams it,
+	// e.g. "ssh minecraft tail -F -n 0 logs/latest.log"
+	ServerLogPath    string `split_words:"true"`
+	ServerLogCommand string `split_words:"true"`
+
+	// joins, leaves, deaths and advancements are posted to this channel.
+	// without a server log only joins and leaves are noticed, by pinging the
+	// server every NotifyPollInterval
+	NotifyChannelId    string        `split_words:"true"`
+	NotifyJoins        bool          `split_words:"true" default:"true"`
+	NotifyLeaves       bool          `split_words:"true" default:"true"`
+	NotifyDeaths       bool          `split_words:"true" default:"true"`
+	NotifyAdvancements bool          `split_words:"true" default:"true"`
+	NotifyBatchWindow  time.Duration `split_words:"true" default:"10s"`
+	NotifyPollInterval time.Duration `split_words:"true" default:"1m"`
+
+	// a status message in this channel is kept up to date in place of /online
+	StatusChannelId       string        `split_words:"true"`
+	StatusRefreshInterval time.Duration `split_words:"true" default:"5m"`
+	StatusStorePath       string        `split_words:"true" default:"status_message.json"`
+
+	// scores are kept in this bbolt database rather than cloudwatch if it's
+	// set, and come from sessions tracked here since there's no lambda to hand
+	// them out. open sessions are credited every LeaderboardScoreInterval.
+	LeaderboardStorePath     string        `split_words:"true"`
+	LeaderboardScoreInterval time.Duration `split_words:"true" default:"5m"`
+	SessionStorePath         string        `split_words:"true" default:"sessions.json"`
+
+	// player names are looked up from playerdb and kept this long, only in
+	// memory unless there's a UsernameCachePath
+	UsernameCachePath string        `split_words:"true"`
+	UsernameCacheTtl  time.Duration `split_words:"true" default:"24h"`
+
+	// the bot's presence shows the server's player count, or its motd with a
+	// style of "motd". while the server is down it goes idle, or dnd.
+	PresenceStyle           string        `split_words:"true" default:"players"`
+	PresenceRefreshInterval time.Duration `split_words:"true" default:"1m"`
+	PresenceDownStatus      string        `split_words:"true" default:"idle"`
+
+	// the server is pinged every UptimeCheckInterval for /uptime, which is
+	// only registered if there's an UptimeStorePath and the interval isn't 0.
+	// outages are alerted in UptimeAlertChannelId, mentioning UptimeAlertRoleId
+	UptimeStorePath         string        `split_words:"true"`
+	UptimeCheckInterval     time.Duration `split_words:"true" default:"1m"`
+	UptimeFailureThreshold  int           `split_words:"true" default:"3"`
+	UptimeRecoveryThreshold int           `split_words:"true" default:"2"`
+	UptimeAlertChannelId    string        `split_words:"true"`
+	UptimeAlertRoleId       string        `split_words:"true"`
 }
 
 func NewServer(cfg *Config) (*Server, error) {
@@ -32,20 +134,78 @@ func NewServer(cfg *Config) (*Server, error) {
 
 	discordClient.ShouldReconnectOnError = true
 
+	mcuser.Names = mcuser.NewCache(cfg.UsernameCacheTtl, cfg.UsernameCachePath)
+
 	srv := &Server{
-		s:   discordClient,
-		cfg: cfg,
+		s:    discordClient,
+		cfg:  cfg,
+		rcon: rcon.NewClient(cfg.RconHostport, cfg.RconPassword),
+
+		whitelistRequests: whitelist.NewFileStore(cfg.WhitelistRequestStorePath),
+	}
+	uptimeMonitored := UptimeMonitored(cfg.UptimeStorePath, cfg.UptimeCheckInterval)
+	if uptimeMonitored {
+		srv.uptimeHistory = uptime.NewFileStore(cfg.UptimeStorePath)
+	}
+	if cfg.LinkStorePath != "" {
+		srv.links = accounts.NewFileStore(cfg.LinkStorePath)
+	}
+
+	srv.authorizations = map[string]*Authorization{
+		whitelistRemovePrefix:  srv.adminAuthorization(),
+		whitelistApprovePrefix: srv.adminAuthorization(),
+		whitelistDenyPrefix:    srv.adminAuthorization(),
+		consolePagePrefix:      srv.adminAuthorization(),
+	}
+
+	commands, err := ConfiguredCommands(cfg.ConsoleTemplates, uptimeMonitored)
+	if err != nil {
+		return nil, err
 	}
+	err = srv.registerCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.components = map[string]ComponentHandler{
+		onlineRefreshPrefix:   srv.deferredComponent(srv.onlineRefresh),
+		leaderboardPagePrefix: srv.deferredComponent(srv.leaderboardPage),
+		whitelistRemovePrefix: srv.whitelistRemoveConfirm,
+		whitelistCancelPrefix: srv.whitelistRemoveCancel,
 
-	srv.handlers = map[string]InteractionHandler{
-		"online":      srv.online,
-		"whitelist":   srv.whitelist,
-		"version":     srv.version,
-		"leaderboard": srv.leaderboard,
+		whitelistApprovePrefix: srv.deferredComponent(srv.whitelistApprove),
+		whitelistDenyPrefix:    srv.deferredComponent(srv.whitelistDeny),
+		consolePagePrefix:      srv.consolePage,
 	}
 
+	if cfg.PresenceRefreshInterval > 0 {
+		switch presence.Style(cfg.PresenceStyle) {
+		case presence.StylePlayers, presence.StyleMotd:
+		default:
+			return nil, fmt.Errorf("unknown presence style '%s'", cfg.PresenceStyle)
+		}
+		switch discordgo.Status(cfg.PresenceDownStatus) {
+		case discordgo.StatusIdle, discordgo.StatusDoNotDisturb:
+		default:
+			return nil, fmt.Errorf("presence down status must be idle or dnd, not '%s'", cfg.PresenceDownStatus)
+		}
+		srv.presence = presence.NewDaemon(discordClient, &presence.Config{
+			Host:       cfg.MinecraftServerHost,
+			Style:      presence.Style(cfg.PresenceStyle),
+			Interval:   cfg.PresenceRefreshInterval,
+			DownStatus: discordgo.Status(cfg.PresenceDownStatus),
+		})
+	}
 	discordClient.AddHandler(srv.onReady)
 
+	// the bridge needs the message content intent to read what members
+	// write, which has to be asked for before the gateway is opened
+	if cfg.BridgeChannelId != "" {
+		discordClient.Identify.Intents |= discordgo.IntentsMessageContent
+	}
+
+	srv.background, srv.stopBackground = context.WithCancel(context.Background())
+
 	/*
 		this is required because discord doesn't allow sending custom emojis
 		from guilds that the bot is not connected to
@@ -59,13 +219,95 @@ func NewServer(cfg *Config) (*Server, error) {
 	return srv, nil
 }
 
+// StartMonitors starts what has to keep running between interactions, which
+// is only done by a long running server. lambda freezes instances between
+// requests and runs several at once, each of which would alert on its own.
+func (srv *Server) StartMonitors() {
+	srv.serverEvents = &serverlog.Feed{}
+	srv.serverLog = srv.serverLogSource()
+
+	// instances would also fight over the presence
+	if srv.presence != nil {
+		go srv.presence.Run(srv.background)
+	}
+	if srv.uptimeHistory != nil {
+		go uptime.NewMonitor(srv.s, srv.uptimeHistory, &uptime.Config{
+			Host:              srv.cfg.MinecraftServerHost,
+			ServerName:        srv.cfg.MinecraftServerName,
+			Interval:          srv.cfg.UptimeCheckInterval,
+			FailureThreshold:  srv.cfg.UptimeFailureThreshold,
+			RecoveryThreshold: srv.cfg.UptimeRecoveryThreshold,
+			ChannelId:         srv.cfg.UptimeAlertChannelId,
+			MentionRoleId:     srv.cfg.UptimeAlertRoleId,
+		}).Run(srv.background)
+	}
+	// instances would each post every join
+	if srv.cfg.NotifyChannelId != "" {
+		srv.startNotifier()
+	}
+	// or fight over the status message
+	if srv.cfg.StatusChannelId != "" {
+		srv.startStatusBoard()
+	}
+	// or relay the same chat more than once
+	if srv.cfg.BridgeChannelId != "" {
+		srv.startBridge()
+	}
+	// or credit the same sessions
+	if srv.cfg.LeaderboardStorePath != "" && srv.cfg.LeaderboardScoreInterval > 0 {
+		srv.startSessionTracker()
+	}
+
+	// the log is followed once everything has subscribed
+	if srv.serverEvents.Subscribed() {
+		go srv.serverEvents.Run(serverlog.Tail(srv.background, srv.serverLog))
+	}
+}
+
 type Server struct {
 	s        *discordgo.Session
 	cfg      *Config
 	handlers map[string]InteractionHandler
+
+	// keyed by command path (see commandPath) or component custom_id prefix
+	authorizations map[string]*Authorization
+
+	// autocomplete handlers keyed by command name
+	autocompletes map[string]InteractionHandler
+
+	// message component handlers keyed by custom_id prefix
+	components map[string]ComponentHandler
+
+	rcon              *rcon.Client
+	whitelistRequests whitelist.Store
+
+	// nil if linking or uptime monitoring are turned off
+	links         accounts.Store
+	uptimeHistory uptime.Store
+
+	// cancelled on Close to stop anything running alongside the gateway session
+	background     context.Context
+	stopBackground context.CancelFunc
+
+	// nil if presence updates are turned off
+	presence *presence.Daemon
+
+	// the server log's events, only followed by StartMonitors. serverLog is
+	// nil if there's no log to follow
+	serverEvents *serverlog.Feed
+	serverLog    serverlog.Source
+
+	// /mc output for the page buttons
+	consoleOutputs consoleOutputs
+
+	// tracks deferred handlers that are still running in the background
+	deferredWork sync.WaitGroup
 }
 
 func (srv *Server) Close() error {
+	srv.stopBackground()
+	srv.deferredWork.Wait()
+	srv.rcon.Close()
 	return srv.s.Close()
 }
 
@@ -113,8 +355,11 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case discordgo.InteractionApplicationCommand:
 		srv.routeApplicationCommand(w, event)
 		return
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		srv.routeAutocomplete(w, event)
+		return
 	case discordgo.InteractionMessageComponent:
-		writeResponse(w, http.StatusUnprocessableEntity, "message interaction not implemented yet")
+		srv.routeMessageComponent(w, event)
 		return
 	}
 
@@ -124,11 +369,19 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (srv *Server) routeApplicationCommand(w http.ResponseWriter, event discordgo.Interaction) {
 	data := event.ApplicationCommandData()
 	if handler, ok := srv.handlers[data.Name]; ok {
+		if !srv.authorize(w, event, commandPath(data)) {
+			return
+		}
 		handler(w, event, srv.s)
 	}
 }
 
 func respondToInteraction(w http.ResponseWriter, statusCode int, response discordgo.InteractionResponse) {
+	if rec, ok := w.(*interactionRecorder); ok {
+		rec.record(statusCode, &response, nil)
+		return
+	}
+
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(statusCode)
 	err := json.NewEncoder(w).Encode(response)
@@ -139,20 +392,47 @@ func respondToInteraction(w http.ResponseWriter, statusCode int, response discor
 	}
 }
 
-func writeResponse(w http.ResponseWriter, statusCode int, body string) {
-	w.Header().Set("content-type", "application/json")
+// respondToInteractionWithFiles uses a multipart response so that files can be
+// uploaded alongside the interaction response
+func respondToInteractionWithFiles(w http.ResponseWriter, statusCode int, response discordgo.InteractionResponse, files []*discordgo.File) {
+	if rec, ok := w.(*interactionRecorder); ok {
+		rec.record(statusCode, &response, files)
+		return
+	}
+
+	contentType, responseBody, err := discordgo.MultipartBodyWithJSON(response, files)
+	if err != nil {
+		log.Printf("error preparing multipart body: %s", err.Error())
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", contentType)
 	w.WriteHeader(statusCode)
-	err := json.NewEncoder(w).Encode(discordgo.InteractionResponse{
+	_, err = w.Write(responseBody)
+	if err != nil {
+		log.Printf("error writing response body: %s", err.Error())
+		return
+	}
+}
+
+func writeEphemeralResponse(w http.ResponseWriter, body string) {
+	respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: body,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, body string) {
+	respondToInteraction(w, statusCode, discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Content: body,
 		},
 	})
-	if err != nil {
-		log.Printf("failed to encode body: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
 }
 
 func decodeDiscordWebhookPubkey(k string) (ed25519.PublicKey, error) {
@@ -163,10 +443,98 @@ func decodeDiscordWebhookPubkey(k string) (ed25519.PublicKey, error) {
 	return ed25519.PublicKey(data), nil
 }
 
+// startNotifier posts player notifications, from the server log if it's
+// followed and otherwise by pinging the server
+func (srv *Server) startNotifier() {
+	notifications := notifier.New(srv.s, &notifier.Config{
+		ChannelId:    srv.cfg.NotifyChannelId,
+		GuildId:      srv.cfg.DiscordGuildId,
+		Joins:        srv.cfg.NotifyJoins,
+		Leaves:       srv.cfg.NotifyLeaves,
+		Deaths:       srv.cfg.NotifyDeaths,
+		Advancements: srv.cfg.NotifyAdvancements,
+		BatchWindow:  srv.cfg.NotifyBatchWindow,
+
+		FloodgatePrefix: srv.cfg.FloodgatePrefix,
+	})
+	if srv.serverLog == nil {
+		log.Printf("no server log configured, only joins and leaves will be notified by pinging the server")
+		go notifications.Run(notifier.Poll(srv.background, srv.cfg.MinecraftServerHost, srv.cfg.MinecraftQueryPort, srv.cfg.FloodgatePrefix, srv.cfg.NotifyPollInterval))
+	} else {
+		go notifications.Run(srv.serverEvents.Subscribe())
+	}
+}
+
+// startBridge relays chat between the bridge channel and the game
+func (srv *Server) startBridge() {
+	chat := bridge.New(srv.s, srv.rcon, srv.cfg.BridgeChannelId)
+	srv.s.AddHandler(chat.HandleMessageCreate)
+	if srv.serverLog == nil {
+		log.Printf("no server log configured, in game chat won't be relayed to discord")
+	} else {
+		go chat.Run(srv.serverEvents.Subscribe())
+	}
+}
+
+// startStatusBoard keeps the status message up to date
+func (srv *Server) startStatusBoard() {
+	board := online.NewBoard(srv.s, &online.BoardConfig{
+		ChannelId:       srv.cfg.StatusChannelId,
+		GuildId:         srv.cfg.DiscordGuildId,
+		ServerHostname:  srv.cfg.MinecraftServerHost,
+		ServerName:      srv.cfg.MinecraftServerName,
+		QueryPort:       srv.cfg.MinecraftQueryPort,
+		BedrockHostname: srv.cfg.BedrockServerHost,
+		FloodgatePrefix: srv.cfg.FloodgatePrefix,
+		Links:           srv.linkDirectory,
+		Interval:        srv.cfg.StatusRefreshInterval,
+		StorePath:       srv.cfg.StatusStorePath,
+	})
+	// joins and leaves refresh the board sooner when the log is followed
+	go board.Run(srv.background, srv.subscribeServerLog())
+}
+
+// startSessionTracker credits the leaderboard with play sessions, followed
+// from the server log if it is and otherwise from who's online
+func (srv *Server) startSessionTracker() {
+	tracker := sessions.NewTracker(sessions.NewFileStore(srv.cfg.SessionStorePath), leaderboard.NewService(leaderboard.NewBoltStore(srv.cfg.LeaderboardStorePath)), &sessions.Config{
+		Host:            srv.cfg.MinecraftServerHost,
+		QueryPort:       srv.cfg.MinecraftQueryPort,
+		FloodgatePrefix: srv.cfg.FloodgatePrefix,
+		Interval:        srv.cfg.LeaderboardScoreInterval,
+	})
+	go tracker.Run(srv.background, srv.subscribeServerLog())
+}
+
+// subscribeServerLog returns the server log's events, or nil if it isn't
+// followed
+func (srv *Server) subscribeServerLog() <-chan serverlog.Event {
+	if srv.serverLog == nil {
+		return nil
+	}
+	return srv.serverEvents.Subscribe()
+}
+
+// serverLogSource is where the server log is followed from, or nil if it
+// isn't configured
+func (srv *Server) serverLogSource() serverlog.Source {
+	if srv.cfg.ServerLogPath != "" {
+		return &serverlog.File{Path: srv.cfg.ServerLogPath}
+	}
+	if args := strings.Fields(srv.cfg.ServerLogCommand); len(args) > 0 {
+		return serverlog.Command(args[0], args[1:]...)
+	}
+	return nil
+}
+
 func (srv *Server) onReady(s *discordgo.Session, event *discordgo.Ready) {
 	guilds := []string{}
 	for _, guild := range event.Guilds {
 		guilds = append(guilds, guild.Name)
 	}
 	log.Printf("guilds joined: %s", strings.Join(guilds, ", "))
+
+	if srv.presence != nil {
+		srv.presence.Refresh()
+	}
 }
@@ -0,0 +1,131 @@
+package interactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/accounts"
+	"github.com/tonkat-su/bot/leaderboard"
+	"github.com/tonkat-su/bot/mcuser"
+)
+
+// statsPlayer is who /stats is about
+type statsPlayer struct {
+	uuid          string
+	name          string
+	discordUserId string
+}
+
+func (srv *Server) stats(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	var username, memberId string
+	for _, v := range event.ApplicationCommandData().Options {
+		switch v.Name {
+		case "username":
+			username, _ = v.Value.(string)
+		case "member":
+			memberId, _ = v.Value.(string)
+		}
+	}
+
+	player, message, err := srv.resolveStatsPlayer(event, username, memberId)
+	if err != nil {
+		log.Printf("error resolving player for stats: %s", err.Error())
+		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if message != "" {
+		writeResponse(w, http.StatusOK, message)
+		return
+	}
+
+	board, err := srv.leaderboardService()
+	if err != nil {
+		log.Printf("error instantiating leaderboard: %s", err.Error())
+		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	now := time.Now()
+	stats, err := board.GetPlayerStats(context.Background(), player.uuid, now)
+	if err != nil {
+		log.Printf("error fetching stats for %s: %s", player.name, err.Error())
+		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	prepared, err := leaderboard.PrepareStatsEmbed(&leaderboard.PrepareStatsEmbedRequest{
+		Stats:         stats,
+		PlayerName:    player.name,
+		Now:           now,
+		DiscordUserId: player.discordUserId,
+	})
+	if err != nil {
+		log.Printf("error preparing stats for %s: %s", player.name, err.Error())
+		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	response := discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{prepared.Embed},
+		},
+	}
+	respondToInteractionWithFiles(w, http.StatusOK, response, prepared.Files)
+}
+
+// resolveStatsPlayer looks up a minecraft username, or else the account linked
+// to a member, defaulting to whoever ran the command. message explains why
+// nobody was found.
+func (srv *Server) resolveStatsPlayer(event discordgo.Interaction, username, memberId string) (player *statsPlayer, message string, err error) {
+	if username != "" && memberId != "" {
+		return nil, "pick either a username or a member, not both", nil
+	}
+
+	if username != "" {
+		uuid, err := mcuser.GetUuid(username)
+		if errors.Is(err, mcuser.ErrPlayerNotFound) {
+			return nil, fmt.Sprintf("couldn't find a minecraft account named %s", username), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return &statsPlayer{
+			uuid:          uuid,
+			name:          username,
+			discordUserId: srv.linkDirectory().DiscordUserId(uuid, username),
+		}, "", nil
+	}
+
+	self := memberId == ""
+	if self {
+		if event.Member == nil || event.Member.User == nil {
+			return nil, "pass a minecraft username or a member", nil
+		}
+		memberId = event.Member.User.ID
+	}
+
+	if srv.links == nil {
+		return nil, "account linking isn't enabled, pass a username", nil
+	}
+	link, err := srv.links.Link(context.Background(), memberId)
+	if errors.Is(err, accounts.ErrNotFound) {
+		if self {
+			return nil, "link your minecraft account with `/link start` or pass a username", nil
+		}
+		return nil, "that member hasn't linked a minecraft account", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &statsPlayer{
+		uuid:          link.MinecraftUuid,
+		name:          link.MinecraftName,
+		discordUserId: link.DiscordUserId,
+	}, "", nil
+}
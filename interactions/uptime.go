@@ -0,0 +1,100 @@
+package interactions
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/uptime"
+)
+
+// how many of the latest outages /uptime lists
+const uptimeRecentOutages = 5
+
+// UptimeCommand is only registered if uptime is monitored, see ConfiguredCommands
+var UptimeCommand = &Command{
+	Definition: &discordgo.ApplicationCommand{
+		Name:        "uptime",
+		Description: "how reliably the server has been up",
+	},
+	Handler: (*Server).uptime,
+}
+
+// UptimeMonitored reports whether the uptime monitor is turned on, it needs
+// somewhere to keep its history
+func UptimeMonitored(storePath string, checkInterval time.Duration) bool {
+	return storePath != "" && checkInterval > 0
+}
+
+func (srv *Server) uptime(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	history, err := srv.uptimeHistory.History(srv.background)
+	if err != nil {
+		log.Printf("error loading uptime history: %s", err.Error())
+		writeResponse(w, http.StatusInternalServerError, "error loading uptime history")
+		return
+	}
+	if history.Since().IsZero() {
+		writeResponse(w, http.StatusOK, "the server isn't being monitored yet")
+		return
+	}
+
+	respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{uptimeEmbed(srv.cfg.MinecraftServerName, history, time.Now())},
+		},
+	})
+}
+
+func uptimeEmbed(serverName string, history *uptime.History, now time.Time) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       serverName + " uptime",
+		Color:       0x43b581,
+		Description: "up",
+	}
+	if ongoing := history.Ongoing(); ongoing != nil {
+		embed.Color = 0xf04747
+		embed.Description = fmt.Sprintf("down since <t:%d:R>", ongoing.Start.Unix())
+	}
+
+	for _, period := range []struct {
+		name   string
+		length time.Duration
+	}{
+		{"24h", 24 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+	} {
+		availability, observed := history.Availability(now.Add(-period.length), now)
+		value := fmt.Sprintf("%.2f%%", availability*100)
+		// monitoring hasn't been running for the whole period
+		if observed < period.length*99/100 {
+			value += fmt.Sprintf(" (%s monitored)", uptime.FormatDuration(observed))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   period.name,
+			Value:  value,
+			Inline: true,
+		})
+	}
+
+	var outages []string
+	for i := len(history.Outages) - 1; i >= 0 && len(outages) < uptimeRecentOutages; i-- {
+		outage := history.Outages[i]
+		outages = append(outages, fmt.Sprintf("<t:%d:f> for %s", outage.Start.Unix(), uptime.FormatDuration(outage.Duration(now))))
+	}
+	if len(outages) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "recent outages",
+			Value: strings.Join(outages, "\n"),
+		})
+	}
+
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: "monitored since " + history.Since().UTC().Format("2006-01-02"),
+	}
+	return embed
+}
@@ -1,85 +1,234 @@
 package interactions
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/jltobler/go-rcon"
+	"github.com/tonkat-su/bot/accounts"
 	"github.com/tonkat-su/bot/emoji"
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/rcon"
+)
+
+const (
+	whitelistRemovePrefix = "whitelist_remove"
+	whitelistCancelPrefix = "whitelist_cancel"
 )
 
 func (srv *Server) whitelist(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
 	log.Println("handling whitelist request")
 
 	subcommand := event.ApplicationCommandData().Options[0]
-	rconClient := rcon.NewClient("rcon://"+srv.cfg.RconHostport, srv.cfg.RconPassword)
 
-	var rconCommand string
 	// TODO: refactor this to call each subcommand as its own handler to avoid a giant whitelist handler here
 	switch subcommand.Name {
 	case "list":
-		rconCommand = "whitelist list"
+		ctx, cancel := context.WithTimeout(context.Background(), rconTimeout)
+		defer cancel()
+		players, err := srv.rcon.WhitelistList(ctx)
+		if err != nil {
+			log.Printf("error listing whitelist: %s", err.Error())
+			writeResponse(w, http.StatusFailedDependency, err.Error())
+			return
+		}
+		embed, err := prepareWhitelistedEmbed(&prepareWhitelistedEmbedParams{
+			Session:        s,
+			DiscordGuildId: srv.cfg.DiscordGuildId,
+			Players:        players,
+			Links:          srv.linkDirectory(),
+		})
+		if err != nil {
+			log.Printf("error syncing avatars to emoji for whitelist list: %s", err.Error())
+			writeResponse(w, http.StatusFailedDependency, err.Error())
+			return
+		}
+		respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{embed},
+			},
+		})
+		return
+	case "request":
+		// looking the player up and posting the card can take a while
+		srv.deferredEphemeral(srv.bindHandler((*Server).requestWhitelist))(w, event, s)
+		return
 	case "add":
 		for _, v := range subcommand.Options {
 			if v.Name == "username" {
 				if username, ok := v.Value.(string); ok {
-					rconCommand = fmt.Sprintf("whitelist add %s", username)
+					srv.whitelistAdd(w, username)
+					return
 				}
 			}
 		}
+		writeResponse(w, http.StatusUnprocessableEntity, "username is required")
+		return
 	case "remove":
 		for _, v := range subcommand.Options {
 			if v.Name == "username" {
 				if username, ok := v.Value.(string); ok {
-					rconCommand = fmt.Sprintf("whitelist remove %s", username)
+					srv.promptWhitelistRemove(w, username)
+					return
 				}
 			}
 		}
+		writeResponse(w, http.StatusUnprocessableEntity, "username is required")
+		return
 	default:
 		log.Printf("invalid command: %s", subcommand.Name)
 		writeResponse(w, http.StatusUnprocessableEntity, "invalid whitelist subcommand")
 		return
 	}
+}
 
-	log.Printf("sending rcon command: %s", rconCommand)
-	output, err := rconClient.Send(rconCommand)
-	if err != nil {
-		log.Printf("error sending rcon command: %s", err.Error())
+func (srv *Server) whitelistAdd(w http.ResponseWriter, username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), rconTimeout)
+	defer cancel()
+	err := srv.rcon.WhitelistAdd(ctx, username)
+	switch {
+	case errors.Is(err, rcon.ErrAlreadyWhitelisted):
+		writeResponse(w, http.StatusOK, fmt.Sprintf("%s is already whitelisted", username))
+		return
+	case errors.Is(err, rcon.ErrPlayerNotFound):
+		writeResponse(w, http.StatusOK, fmt.Sprintf("couldn't find a minecraft account named %s", username))
+		return
+	case err != nil:
+		log.Printf("error adding %s to whitelist: %s", username, err.Error())
 		writeResponse(w, http.StatusFailedDependency, err.Error())
+		return
 	}
 
-	if subcommand.Name == "list" {
-		embed, err := prepareWhitelistedEmbed(&prepareWhitelistedEmbedParams{
-			Session:        s,
-			DiscordGuildId: srv.cfg.DiscordGuildId,
-			Players:        strings.Split(strings.Split(output, ": ")[1], ", "),
-		})
+	// TODO: if we add someone new then we should call sync emoji
+
+	writeResponse(w, http.StatusOK, fmt.Sprintf("added %s to the whitelist", username))
+	log.Println("rcon command successful")
+}
+
+const (
+	// leaves time to respond within discord's 3 second deadline
+	rconTimeout = 2 * time.Second
+
+	// autocomplete has to answer within discord's 3 second deadline too
+	autocompleteTimeout = 2 * time.Second
+)
+
+func (srv *Server) whitelistAutocomplete(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	data := event.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		respondWithChoices(w, nil)
+		return
+	}
+	subcommand := data.Options[0]
+
+	var typed string
+	if option := focusedOption(subcommand.Options); option != nil {
+		typed, _ = option.Value.(string)
+	}
+
+	switch subcommand.Name {
+	case "remove":
+		ctx, cancel := context.WithTimeout(context.Background(), autocompleteTimeout)
+		defer cancel()
+		players, err := srv.rcon.WhitelistList(ctx)
 		if err != nil {
-			log.Printf("error syncing avatars to emoji for whitelist list: %s", err.Error())
-			writeResponse(w, http.StatusFailedDependency, err.Error())
+			log.Printf("error fetching whitelist for autocomplete: %s", err.Error())
+			respondWithChoices(w, nil)
+			return
 		}
-		respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Embeds: []*discordgo.MessageEmbed{embed},
+		respondWithChoices(w, matchingNames(players, typed))
+	case "add":
+		respondWithChoices(w, matchingNames(mcuser.RecentlySeen(recentPlayerTTL), typed))
+	default:
+		respondWithChoices(w, nil)
+	}
+}
+
+type whitelistRemoveState struct {
+	Username string `json:"u"`
+}
+
+// promptWhitelistRemove asks for confirmation before removing someone from the whitelist
+func (srv *Server) promptWhitelistRemove(w http.ResponseWriter, username string) {
+	confirmId, err := componentCustomId(whitelistRemovePrefix, whitelistRemoveState{Username: username})
+	if err != nil {
+		log.Printf("error preparing whitelist remove confirmation: %s", err.Error())
+		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	respondToInteraction(w, http.StatusOK, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("remove %s from the whitelist?", username),
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "confirm remove",
+							Style:    discordgo.DangerButton,
+							CustomID: confirmId,
+						},
+						discordgo.Button{
+							Label:    "cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: whitelistCancelPrefix,
+						},
+					},
+				},
 			},
-		})
+		},
+	})
+}
+
+func (srv *Server) whitelistRemoveConfirm(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState) {
+	var removeState whitelistRemoveState
+	err := state.Decode(&removeState)
+	if err != nil || removeState.Username == "" {
+		log.Printf("invalid whitelist remove state: %s", state)
+		writeResponse(w, http.StatusBadRequest, "invalid whitelist remove request")
 		return
 	}
 
-	// TODO: if we add someone new then we should call sync emoji
+	ctx, cancel := context.WithTimeout(context.Background(), rconTimeout)
+	defer cancel()
+	output := fmt.Sprintf("removed %s from the whitelist", removeState.Username)
+	err = srv.rcon.WhitelistRemove(ctx, removeState.Username)
+	switch {
+	case errors.Is(err, rcon.ErrNotWhitelisted):
+		output = fmt.Sprintf("%s isn't whitelisted", removeState.Username)
+	case errors.Is(err, rcon.ErrPlayerNotFound):
+		output = fmt.Sprintf("couldn't find a minecraft account named %s", removeState.Username)
+	case err != nil:
+		log.Printf("error removing %s from whitelist: %s", removeState.Username, err.Error())
+		output = err.Error()
+	}
 
-	writeResponse(w, http.StatusOK, output)
-	log.Println("rcon command successful")
+	updateMessage(w, &discordgo.InteractionResponseData{
+		Content:    output,
+		Components: []discordgo.MessageComponent{},
+	})
+}
+
+func (srv *Server) whitelistRemoveCancel(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, _ ComponentState) {
+	updateMessage(w, &discordgo.InteractionResponseData{
+		Content:    "whitelist remove cancelled",
+		Components: []discordgo.MessageComponent{},
+	})
 }
 
 type prepareWhitelistedEmbedParams struct {
 	Session        *discordgo.Session
 	Players        []string
 	DiscordGuildId string
+	Links          *accounts.Directory
 }
 
 func prepareWhitelistedEmbed(params *prepareWhitelistedEmbedParams) (*discordgo.MessageEmbed, error) {
@@ -89,6 +238,8 @@ func prepareWhitelistedEmbed(params *prepareWhitelistedEmbedParams) (*discordgo.
 			Name: name,
 		})
 	}
+	params.Links.Annotate(players)
+
 	err := emoji.HydrateEmojiIds(params.Session, params.DiscordGuildId, players)
 	if err != nil {
 		return nil, err
@@ -97,6 +248,9 @@ func prepareWhitelistedEmbed(params *prepareWhitelistedEmbedParams) (*discordgo.
 	var builder strings.Builder
 	for i, v := range players {
 		fmt.Fprintf(&builder, "%s %s", v.EmojiTextCode(), v.Name)
+		if mention := v.Mention(); mention != "" {
+			fmt.Fprintf(&builder, " (%s)", mention)
+		}
 		if i != len(players)-1 {
 			builder.WriteString("\n")
 		}
@@ -0,0 +1,295 @@
+package interactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/emoji"
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/rcon"
+	"github.com/tonkat-su/bot/whitelist"
+)
+
+const (
+	whitelistApprovePrefix = "whitelist_approve"
+	whitelistDenyPrefix    = "whitelist_deny"
+)
+
+type whitelistRequestState struct {
+	Id string `json:"id"`
+}
+
+// requestWhitelist posts an approval card to the moderator channel for the
+// requested player
+func (srv *Server) requestWhitelist(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session) {
+	var username string
+	for _, v := range event.ApplicationCommandData().Options[0].Options {
+		if v.Name == "username" {
+			username, _ = v.Value.(string)
+		}
+	}
+	if username == "" {
+		writeEphemeralResponse(w, "username is required")
+		return
+	}
+
+	if srv.cfg.WhitelistRequestChannelId == "" {
+		writeEphemeralResponse(w, "whitelist requests aren't enabled, ask a server admin")
+		return
+	}
+	if event.Member == nil || event.Member.User == nil {
+		writeEphemeralResponse(w, "whitelist requests only work in the server")
+		return
+	}
+
+	uuid, err := mcuser.GetUuid(username)
+	if errors.Is(err, mcuser.ErrPlayerNotFound) {
+		writeEphemeralResponse(w, fmt.Sprintf("couldn't find a minecraft account named %s", username))
+		return
+	}
+	if err != nil {
+		log.Printf("error looking up uuid for %s: %s", username, err.Error())
+		writeEphemeralResponse(w, "internal server error")
+		return
+	}
+
+	ctx := context.Background()
+	request, err := whitelist.NewRequest(event.Member.User.ID, username, uuid)
+	if err != nil {
+		log.Printf("error creating whitelist request: %s", err.Error())
+		writeEphemeralResponse(w, "internal server error")
+		return
+	}
+	err = srv.whitelistRequests.Create(ctx, request)
+	if errors.Is(err, whitelist.ErrAlreadyPending) {
+		writeEphemeralResponse(w, fmt.Sprintf("%s already has a pending whitelist request", username))
+		return
+	}
+	if err != nil {
+		log.Printf("error saving whitelist request: %s", err.Error())
+		writeEphemeralResponse(w, "internal server error")
+		return
+	}
+
+	card, err := postWhitelistRequestCard(s, srv.cfg.WhitelistRequestChannelId, request)
+	if err != nil {
+		log.Printf("error posting whitelist request card: %s", err.Error())
+		// nobody could ever decide it, and it would block asking again
+		err = srv.whitelistRequests.Delete(ctx, request.Id)
+		if err != nil {
+			log.Printf("error deleting whitelist request %s without a card: %s", request.Id, err.Error())
+		}
+		writeEphemeralResponse(w, "internal server error")
+		return
+	}
+
+	request.CardChannelId = card.ChannelID
+	request.CardMessageId = card.ID
+	err = srv.whitelistRequests.Put(ctx, request)
+	if err != nil {
+		log.Printf("error saving whitelist request card: %s", err.Error())
+	}
+
+	writeEphemeralResponse(w, fmt.Sprintf("requested whitelisting for %s, you'll get a message once a moderator has looked at it", username))
+}
+
+func postWhitelistRequestCard(s *discordgo.Session, channelId string, request *whitelist.Request) (*discordgo.Message, error) {
+	components, err := whitelistRequestButtons(request)
+	if err != nil {
+		return nil, err
+	}
+	return s.ChannelMessageSendComplex(channelId, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{whitelistRequestEmbed(request)},
+		Components: components,
+	})
+}
+
+func whitelistRequestButtons(request *whitelist.Request) ([]discordgo.MessageComponent, error) {
+	state := whitelistRequestState{Id: request.Id}
+	approveId, err := componentCustomId(whitelistApprovePrefix, state)
+	if err != nil {
+		return nil, err
+	}
+	denyId, err := componentCustomId(whitelistDenyPrefix, state)
+	if err != nil {
+		return nil, err
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: approveId,
+				},
+				discordgo.Button{
+					Label:    "deny",
+					Style:    discordgo.DangerButton,
+					CustomID: denyId,
+				},
+			},
+		},
+	}, nil
+}
+
+func whitelistRequestEmbed(request *whitelist.Request) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title: "whitelist request",
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: fmt.Sprintf("https://minotar.net/helm/%s/128.png", request.MinecraftName),
+		},
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "player",
+				Value:  request.MinecraftName,
+				Inline: true,
+			},
+			{
+				Name:   "requested by",
+				Value:  "<@" + request.DiscordUserId + ">",
+				Inline: true,
+			},
+		},
+		Timestamp: request.RequestedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	switch request.Status {
+	case whitelist.StatusApproved:
+		embed.Color = 0x43b581
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "approved by",
+			Value: "<@" + request.DecidedBy + ">",
+		})
+	case whitelist.StatusDenied:
+		embed.Color = 0xf04747
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "denied by",
+			Value: "<@" + request.DecidedBy + ">",
+		})
+	default:
+		embed.Color = 0xfaa61a
+	}
+	return embed
+}
+
+func (srv *Server) whitelistApprove(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState) {
+	srv.decideWhitelistRequest(w, event, s, state, whitelist.StatusApproved)
+}
+
+func (srv *Server) whitelistDeny(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState) {
+	srv.decideWhitelistRequest(w, event, s, state, whitelist.StatusDenied)
+}
+
+func (srv *Server) decideWhitelistRequest(w http.ResponseWriter, event discordgo.Interaction, s *discordgo.Session, state ComponentState, decision whitelist.Status) {
+	var requestState whitelistRequestState
+	err := state.Decode(&requestState)
+	if err != nil {
+		log.Printf("invalid whitelist request state: %s", state)
+		writeResponse(w, http.StatusBadRequest, "invalid whitelist request")
+		return
+	}
+
+	// claimed first so that an approve and deny clicked at once can't both
+	// be carried out
+	ctx := context.Background()
+	request, err := srv.whitelistRequests.Claim(ctx, requestState.Id)
+	if errors.Is(err, whitelist.ErrNotFound) {
+		// decided while this click was on its way, the card already shows
+		// what was decided
+		updateMessage(w, &discordgo.InteractionResponseData{
+			Components: []discordgo.MessageComponent{},
+		})
+		return
+	}
+	if errors.Is(err, whitelist.ErrNotPending) {
+		// someone else got to it first, just show what they decided. the
+		// buttons stay while they're still deciding in case it fails.
+		data := &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{whitelistRequestEmbed(request)},
+		}
+		if request.Status != whitelist.StatusDeciding {
+			data.Components = []discordgo.MessageComponent{}
+		}
+		updateMessage(w, data)
+		return
+	}
+	if err != nil {
+		log.Printf("error claiming whitelist request %s: %s", requestState.Id, err.Error())
+		writeResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if decision == whitelist.StatusApproved {
+		rconCtx, cancel := context.WithTimeout(ctx, rconTimeout)
+		err = srv.rcon.WhitelistAdd(rconCtx, request.MinecraftName)
+		cancel()
+		if err != nil && !errors.Is(err, rcon.ErrAlreadyWhitelisted) {
+			log.Printf("error adding %s to whitelist: %s", request.MinecraftName, err.Error())
+			srv.releaseWhitelistRequest(ctx, request)
+			writeResponse(w, http.StatusFailedDependency, err.Error())
+			return
+		}
+
+		err = emoji.SyncMinecraftAvatarsToEmoji(s, srv.cfg.DiscordGuildId, []*emoji.Player{
+			{
+				Name: request.MinecraftName,
+				Uuid: request.MinecraftUuid,
+			},
+		})
+		if err != nil {
+			log.Printf("error syncing emoji for %s: %s", request.MinecraftName, err.Error())
+		}
+	}
+
+	request.Status = decision
+	if event.Member != nil && event.Member.User != nil {
+		request.DecidedBy = event.Member.User.ID
+	}
+	request.DecidedAt = time.Now()
+	// the card shows the decision from here on, so the request isn't kept
+	err = srv.whitelistRequests.Delete(ctx, request.Id)
+	if err != nil {
+		log.Printf("error deleting decided whitelist request %s: %s", request.Id, err.Error())
+	}
+
+	srv.notifyWhitelistRequester(s, request)
+
+	updateMessage(w, &discordgo.InteractionResponseData{
+		Embeds:     []*discordgo.MessageEmbed{whitelistRequestEmbed(request)},
+		Components: []discordgo.MessageComponent{},
+	})
+}
+
+// releaseWhitelistRequest puts a claimed request back to pending so it can
+// be decided again
+func (srv *Server) releaseWhitelistRequest(ctx context.Context, request *whitelist.Request) {
+	request.Status = whitelist.StatusPending
+	err := srv.whitelistRequests.Put(ctx, request)
+	if err != nil {
+		log.Printf("error releasing whitelist request %s: %s", request.Id, err.Error())
+	}
+}
+
+// notifyWhitelistRequester lets the requester know over dm, which can fail if
+// they don't accept dms from server members
+func (srv *Server) notifyWhitelistRequester(s *discordgo.Session, request *whitelist.Request) {
+	channel, err := s.UserChannelCreate(request.DiscordUserId)
+	if err != nil {
+		log.Printf("error opening dm with %s: %s", request.DiscordUserId, err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("your whitelist request for %s was approved, see you in game!", request.MinecraftName)
+	if request.Status == whitelist.StatusDenied {
+		message = fmt.Sprintf("your whitelist request for %s was denied", request.MinecraftName)
+	}
+	_, err = s.ChannelMessageSend(channel.ID, message)
+	if err != nil {
+		log.Printf("error notifying %s about whitelist request: %s", request.DiscordUserId, err.Error())
+	}
+}
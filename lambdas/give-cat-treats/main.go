@@ -2,54 +2,49 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"strings"
+	"time"
 
-	mcpinger "github.com/Raqbit/mc-pinger"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/bsdlp/envconfig"
 	"github.com/tonkat-su/bot/leaderboard"
-	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/sessions"
 )
 
-// triggered by cloudwatch event to query the minecraft server and give cat treats to players
-func Handler(cfg Config, leaderboardService *leaderboard.Service) func(context.Context, *events.CloudWatchEvent) error {
-	return func(ctx context.Context, event *events.CloudWatchEvent) error {
-		hostports, err := mclookup.ResolveMinecraftHostPort(ctx, nil, cfg.MinecraftServerHost)
-		if err != nil {
-			return fmt.Errorf("error resolving server host '%s': %s", cfg.MinecraftServerHost, err.Error())
-		}
-		if len(hostports) == 0 {
-			return nil
-		}
-
-		pong, err := mcpinger.New(hostports[0].Host, hostports[0].Port).Ping()
-		if err != nil {
-			return err
-		}
-
-		if pong.Players.Online == 0 {
-			return nil
-		}
+// lambda mounts efs file systems under here
+const lambdaMountPrefix = "/mnt/"
 
-		input := &leaderboard.RecordScoresInput{
-			Scores: make([]*leaderboard.PlayerScore, len(pong.Players.Sample)),
-		}
-		for i, v := range pong.Players.Sample {
-			input.Scores[i] = &leaderboard.PlayerScore{
-				PlayerId: v.ID,
-				Score:    1,
-			}
+// triggered by cloudwatch event to query the minecraft server and give cat treats to players.
+// with a session store each run is a step of a session tracker, otherwise
+// playtime is sampled every ScoreInterval rather than measured.
+func Handler(cfg Config, leaderboardService *leaderboard.Service, tracker *sessions.Tracker) func(context.Context, *events.CloudWatchEvent) error {
+	return func(ctx context.Context, event *events.CloudWatchEvent) error {
+		if tracker != nil {
+			return tracker.Step(ctx, time.Now())
 		}
-		return leaderboardService.RecordScores(ctx, input)
+		return leaderboardService.GiveCatTreats(ctx, cfg.MinecraftServerHost, cfg.MinecraftQueryPort, cfg.ScoreInterval)
 	}
 }
 
 type Config struct {
 	MinecraftServerName string `required:"true" split_words:"true"`
 	MinecraftServerHost string `required:"true" split_words:"true"`
+	// set if the server has enable-query on, to score everyone online
+	// rather than the ping's sample
+	MinecraftQueryPort int `split_words:"true"`
+	// bedrock players joining through floodgate have their names prefixed
+	// with this
+	FloodgatePrefix string `split_words:"true" default:"."`
+	// how often the lambda is scheduled, everyone online is credited this
+	// many minutes each run
+	ScoreInterval time.Duration `split_words:"true" default:"5m"`
+	// where sessions are kept between runs, on an efs mount. players are
+	// credited the minutes they were on between runs rather than sampled,
+	// which needs a complete player list, see sessions.Tracker.Run
+	SessionStorePath string `split_words:"true"`
 }
 
 func main() {
@@ -64,12 +59,23 @@ func main() {
 		log.Fatalf("error loading aws config: %s", err)
 	}
 
-	leaderboardService, err := leaderboard.New(awsCfg, &leaderboard.Config{
+	leaderboardService := leaderboard.NewService(leaderboard.NewCloudwatchStore(awsCfg, &leaderboard.Config{
 		NamespacePrefix: cfg.MinecraftServerName,
-	})
-	if err != nil {
-		log.Fatal(err)
+	}))
+
+	var tracker *sessions.Tracker
+	if cfg.SessionStorePath != "" {
+		// anything else is lost whenever the instance is
+		if !strings.HasPrefix(cfg.SessionStorePath, lambdaMountPrefix) {
+			log.Fatalf("session store %s has to be on an efs mount under %s", cfg.SessionStorePath, lambdaMountPrefix)
+		}
+		tracker = sessions.NewTracker(sessions.NewFileStore(cfg.SessionStorePath), leaderboardService, &sessions.Config{
+			Host:            cfg.MinecraftServerHost,
+			QueryPort:       cfg.MinecraftQueryPort,
+			FloodgatePrefix: cfg.FloodgatePrefix,
+			Interval:        cfg.ScoreInterval,
+		})
 	}
 
-	lambda.Start(Handler(cfg, leaderboardService))
+	lambda.Start(Handler(cfg, leaderboardService, tracker))
 }
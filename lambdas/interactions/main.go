@@ -1,8 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
@@ -10,6 +14,9 @@ import (
 	"github.com/tonkat-su/bot/interactions"
 )
 
+// lambda mounts efs file systems under here
+const lambdaMountPrefix = "/mnt/"
+
 var (
 	config interactions.Config
 	server *interactions.Server
@@ -20,6 +27,23 @@ func main() {
 	if err != nil {
 		log.Fatalf("error reading envconfig: %s", err.Error())
 	}
+	config.InlineDeferred = true
+	// the uptime monitor only runs from a long running server, so there'd be
+	// no history for /uptime
+	config.UptimeStorePath = ""
+
+	if config.LinkStorePath != "" {
+		err = checkMounted(config.LinkStorePath)
+		if err != nil {
+			log.Fatalf("link store: %s", err.Error())
+		}
+	}
+	if config.WhitelistRequestChannelId != "" {
+		err = checkMounted(config.WhitelistRequestStorePath)
+		if err != nil {
+			log.Fatalf("whitelist request store: %s", err.Error())
+		}
+	}
 
 	server, err = interactions.NewServer(&config)
 	if err != nil {
@@ -40,3 +64,21 @@ func main() {
 
 	lambda.Start(httpadapter.New(mux).ProxyWithContext)
 }
+
+// checkMounted makes sure a store is on a writable efs mount, anything else is
+// lost whenever the instance is
+func checkMounted(path string) error {
+	if !strings.HasPrefix(path, lambdaMountPrefix) {
+		return fmt.Errorf("%s has to be on an efs mount under %s", path, lambdaMountPrefix)
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("%s isn't writable: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".writable.*")
+	if err != nil {
+		return fmt.Errorf("%s isn't writable: %w", path, err)
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
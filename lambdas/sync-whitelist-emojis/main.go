@@ -3,13 +3,12 @@ package main
 import (
 	"context"
 	"log"
-	"strings"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/bsdlp/envconfig"
 	"github.com/bwmarrin/discordgo"
-	"github.com/jltobler/go-rcon"
 	"github.com/tonkat-su/bot/emoji"
+	"github.com/tonkat-su/bot/rcon"
 )
 
 type Config struct {
@@ -32,18 +31,16 @@ func main() {
 		log.Fatalf("error creating discord client: %s", err.Error())
 	}
 
-	rconClient := rcon.NewClient("rcon://"+cfg.MinecraftRconHostPort, cfg.MinecraftRconPassword)
+	// created once so warm invocations reuse the connection
+	rconClient := rcon.NewClient(cfg.MinecraftRconHostPort, cfg.MinecraftRconPassword)
 
 	lambda.Start(func(ctx context.Context) error {
 		// get players in whitelist
-		output, err := rconClient.Send("whitelist list")
+		players, err := rconClient.WhitelistList(ctx)
 		if err != nil {
 			return err
 		}
 
-		// parse rcon output, looks something like:
-		// There are 14 whitelisted players: ouroboronn, MuchJokes, ImBith, Tigglywuff, piecatjustice, Rainefan, Nomibby, Sharisi, scholtez, Afadra, seputus, odiistorm, piecat314, bsdlp
-		players := strings.Split(strings.Split(output, ": ")[1], ", ")
 		emojis := make([]*emoji.Player, len(players))
 		for i, v := range players {
 			emojis[i] = &emoji.Player{
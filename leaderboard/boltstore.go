@@ -0,0 +1,236 @@
+package leaderboard
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// scores are summed into buckets this long on disk, so queries are only
+	// exact for periods and start times on the hour
+	boltStoreResolution = time.Hour
+	// scores are kept as long as cloudwatch keeps them
+	boltStoreRetention = CloudwatchRetention
+	// scores recorded once are remembered this long before the latest one,
+	// retries come well before then
+	onceRetention = 7 * 24 * time.Hour
+	// how long to wait for another process using the database
+	boltOpenTimeout = 30 * time.Second
+)
+
+var (
+	// scoresBucket has a bucket per player id, mapping the unix time each
+	// hour starts at to the hour's sum
+	scoresBucket = []byte("scores")
+	// recordedBucket has a bucket per player id, with the unix times of
+	// scores recorded once as keys
+	recordedBucket = []byte("recorded")
+)
+
+// BoltStore keeps scores in an embedded bbolt database, for deployments
+// without aws. the database is only opened while it's used, so processes
+// sharing it, like lambda instances with the same efs mount, take turns.
+//
+// scores are summed by the hour, so a query starting within an hour leaves
+// that hour out, see standingsResolution. hours are counted from the unix
+// epoch, so in locations whose offset isn't whole hours days are cut at an
+// hour rather than at midnight, /stats' daily buckets included.
+type BoltStore struct {
+	path string
+}
+
+func NewBoltStore(path string) *BoltStore {
+	return &BoltStore{path: path}
+}
+
+func (store *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	if !readOnly {
+		err := os.MkdirAll(filepath.Dir(store.path), 0o755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bolt.Open(store.path, 0o644, &bolt.Options{Timeout: boltOpenTimeout, ReadOnly: readOnly})
+}
+
+func boltKey(at time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(at.Unix()))
+	return key
+}
+
+func boltTime(key []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(key)), 0)
+}
+
+func boltScore(value []byte) int64 {
+	if len(value) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+func (store *BoltStore) RecordScores(ctx context.Context, input *RecordScoresInput) error {
+	err := validateScores(input)
+	if err != nil {
+		return err
+	}
+
+	at := input.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	bucket := boltKey(at.Truncate(boltStoreResolution))
+
+	db, err := store.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		scores, err := tx.CreateBucketIfNotExists(scoresBucket)
+		if err != nil {
+			return err
+		}
+		recorded, err := tx.CreateBucketIfNotExists(recordedBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range input.Scores {
+			if input.Once {
+				times, err := recorded.CreateBucketIfNotExists([]byte(v.PlayerId))
+				if err != nil {
+					return err
+				}
+				if times.Get(boltKey(at)) != nil {
+					continue
+				}
+				err = times.Put(boltKey(at), []byte{})
+				if err != nil {
+					return err
+				}
+			}
+
+			buckets, err := scores.CreateBucketIfNotExists([]byte(v.PlayerId))
+			if err != nil {
+				return err
+			}
+			sum := make([]byte, 8)
+			binary.BigEndian.PutUint64(sum, uint64(boltScore(buckets.Get(bucket))+v.Score))
+			err = buckets.Put(bucket, sum)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = pruneBolt(scores, at.Add(-boltStoreRetention))
+		if err != nil {
+			return err
+		}
+		return pruneBolt(recorded, at.Add(-onceRetention))
+	})
+}
+
+// pruneBolt deletes keys from before before in each player's bucket, and the
+// buckets left empty
+func pruneBolt(players *bolt.Bucket, before time.Time) error {
+	var emptied [][]byte
+	err := players.ForEach(func(playerId, _ []byte) error {
+		times := players.Bucket(playerId)
+		if times == nil {
+			return nil
+		}
+		// deleting while the cursor moves skips keys
+		var stale [][]byte
+		c := times.Cursor()
+		for k, _ := c.First(); k != nil && boltTime(k).Before(before); k, _ = c.Next() {
+			stale = append(stale, k)
+		}
+		for _, k := range stale {
+			err := times.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		if k, _ := times.Cursor().First(); k == nil {
+			emptied = append(emptied, playerId)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, playerId := range emptied {
+		err = players.DeleteBucket(playerId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *BoltStore) QueryScores(ctx context.Context, input *QueryScoresInput) ([]*PlayerHistory, error) {
+	// read only opens can't create the database, nothing has been recorded yet
+	info, err := os.Stat(store.path)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && info.Size() == 0 {
+		return []*PlayerHistory{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	db, err := store.open(true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	histories := []*PlayerHistory{}
+	err = db.View(func(tx *bolt.Tx) error {
+		scores := tx.Bucket(scoresBucket)
+		if scores == nil {
+			return nil
+		}
+		return scores.ForEach(func(playerId, _ []byte) error {
+			if input.PlayerId != "" && string(playerId) != input.PlayerId {
+				return nil
+			}
+			buckets := scores.Bucket(playerId)
+			if buckets == nil {
+				return nil
+			}
+
+			var history *PlayerHistory
+			c := buckets.Cursor()
+			for k, v := c.Seek(boltKey(input.Start)); k != nil && boltTime(k).Before(input.End); k, v = c.Next() {
+				pointTime := input.Start.Add(boltTime(k).Sub(input.Start) / input.Period * input.Period)
+				if history == nil {
+					history = &PlayerHistory{PlayerId: string(playerId)}
+				}
+				// keys come in order, so points do too
+				if n := len(history.Points); n > 0 && history.Points[n-1].Time.Equal(pointTime) {
+					history.Points[n-1].Score += boltScore(v)
+					continue
+				}
+				history.Points = append(history.Points, &ScorePoint{Time: pointTime, Score: boltScore(v)})
+			}
+			if history != nil {
+				histories = append(histories, history)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// bolt keeps buckets sorted by key, so histories are sorted by player id
+	return histories, nil
+}
@@ -0,0 +1,145 @@
+package leaderboard
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"time"
+)
+
+const (
+	chartWidth  = 600
+	chartHeight = 240
+
+	// room for the hour labels on the left and day labels underneath
+	chartMarginLeft   = 34
+	chartMarginRight  = 8
+	chartMarginTop    = 10
+	chartMarginBottom = 20
+
+	// glyphs are 3x5 pixels drawn at this scale
+	glyphScale   = 2
+	maxGridLines = 6
+)
+
+var (
+	chartBackground = color.RGBA{0x2b, 0x2d, 0x31, 0xff}
+	chartGrid       = color.RGBA{0x3f, 0x41, 0x47, 0xff}
+	chartText       = color.RGBA{0xb5, 0xba, 0xc1, 0xff}
+	chartBar        = color.RGBA{0x57, 0xf2, 0x87, 0xff}
+	chartToday      = color.RGBA{0xfe, 0xe7, 0x5c, 0xff}
+)
+
+// glyphs is just enough of a font for the axis labels, each row is 3 pixels
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'h': {"#..", "#..", "###", "#.#", "#.#"},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+}
+
+// RenderChart draws days as a png bar chart of minutes played, the last day
+// is highlighted as today
+func RenderChart(days []*ScorePoint) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	plot := image.Rect(chartMarginLeft, chartMarginTop, chartWidth-chartMarginRight, chartHeight-chartMarginBottom)
+
+	var most time.Duration
+	for _, day := range days {
+		if playtime := time.Duration(day.Score) * time.Minute; playtime > most {
+			most = playtime
+		}
+	}
+	// the y axis is whole hours, with a grid line every step hours
+	hours := int((most + time.Hour - 1) / time.Hour)
+	if hours == 0 {
+		hours = 1
+	}
+	step := (hours + maxGridLines - 1) / maxGridLines
+	hours = (hours + step - 1) / step * step
+	top := time.Duration(hours) * time.Hour
+
+	for h := 0; h <= hours; h += step {
+		y := plot.Max.Y - int(time.Duration(h)*time.Hour*time.Duration(plot.Dy())/top)
+		draw.Draw(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), &image.Uniform{chartGrid}, image.Point{}, draw.Src)
+		label := strconv.Itoa(h) + "h"
+		drawText(img, plot.Min.X-textWidth(label)-4, y-glyphHeight()/2, label, chartText)
+	}
+
+	if len(days) == 0 {
+		return encodePng(img)
+	}
+	slot := plot.Dx() / len(days)
+	gap := slot / 5
+	if gap < 1 {
+		gap = 1
+	}
+	for i, day := range days {
+		x := plot.Min.X + i*slot
+		playtime := time.Duration(day.Score) * time.Minute
+		height := int(playtime * time.Duration(plot.Dy()) / top)
+		fill := chartBar
+		if i == len(days)-1 {
+			fill = chartToday
+		}
+		if height > 0 {
+			draw.Draw(img, image.Rect(x+gap/2, plot.Max.Y-height, x+slot-(gap-gap/2), plot.Max.Y), &image.Uniform{fill}, image.Point{}, draw.Src)
+		}
+
+		// label every week back from today
+		if (len(days)-1-i)%7 == 0 {
+			label := strconv.Itoa(int(day.Time.Month())) + "/" + strconv.Itoa(day.Time.Day())
+			drawText(img, x+slot/2-textWidth(label)/2, plot.Max.Y+6, label, chartText)
+		}
+	}
+
+	return encodePng(img)
+}
+
+func encodePng(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func glyphHeight() int {
+	return 5 * glyphScale
+}
+
+func textWidth(text string) int {
+	if text == "" {
+		return 0
+	}
+	return len(text)*4*glyphScale - glyphScale
+}
+
+func drawText(img draw.Image, x, y int, text string, c color.Color) {
+	for _, r := range text {
+		for row, line := range glyphs[r] {
+			for col, pixel := range line {
+				if pixel != '#' {
+					continue
+				}
+				px, py := x+col*glyphScale, y+row*glyphScale
+				draw.Draw(img, image.Rect(px, py, px+glyphScale, py+glyphScale), &image.Uniform{c}, image.Point{}, draw.Src)
+			}
+		}
+		x += 4 * glyphScale
+	}
+}
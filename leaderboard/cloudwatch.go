@@ -0,0 +1,353 @@
+package leaderboard
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	// cloudwatch takes at most this many metric data queries per request
+	maxMetricDataQueries = 500
+	// and this many metric datums per put
+	maxMetricData = 1000
+	// and returns at most this many data points per request
+	maxDataPoints = 100800
+
+	// CloudwatchRetention is how long cloudwatch keeps metrics
+	CloudwatchRetention = 455 * 24 * time.Hour
+
+	minutesMetric = "MinutesPlayed"
+	// scores from before they were minutes, see legacyScoreMinutes
+	legacyScoreMetric = "PlayerScore"
+)
+
+type CloudwatchClient interface {
+	cloudwatch.ListMetricsAPIClient
+	cloudwatch.GetMetricDataAPIClient
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// CloudwatchStore keeps scores as cloudwatch metrics, which age out after 15
+// months and lose resolution well before then
+type CloudwatchStore struct {
+	cloudwatch      CloudwatchClient
+	namespacePrefix string
+}
+
+type Config struct {
+	NamespacePrefix string
+}
+
+func NewCloudwatchStore(awsCfg aws.Config, cfg *Config) *CloudwatchStore {
+	return &CloudwatchStore{
+		cloudwatch:      cloudwatch.NewFromConfig(awsCfg),
+		namespacePrefix: cfg.NamespacePrefix,
+	}
+}
+
+func (score *PlayerScore) metricDatum(timestamp *time.Time) types.MetricDatum {
+	return types.MetricDatum{
+		Dimensions: []types.Dimension{
+			{
+				Name:  aws.String("PlayerId"),
+				Value: aws.String(score.PlayerId),
+			},
+		},
+		MetricName: aws.String(minutesMetric),
+		Timestamp:  timestamp,
+		Value:      aws.Float64(float64(score.Score)),
+	}
+}
+
+func (store *CloudwatchStore) metricsNamespace() string {
+	return filepath.Join(store.namespacePrefix, "Leaderboard")
+}
+
+func (store *CloudwatchStore) RecordScores(ctx context.Context, input *RecordScoresInput) error {
+	err := validateScores(input)
+	if err != nil {
+		return err
+	}
+
+	var timestamp *time.Time
+	if !input.Time.IsZero() {
+		timestamp = aws.Time(input.Time)
+	}
+
+	scores := input.Scores
+	if input.Once {
+		scores, err = store.unrecorded(ctx, input)
+		if err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(scores); start += maxMetricData {
+		end := start + maxMetricData
+		if end > len(scores) {
+			end = len(scores)
+		}
+
+		metricInput := &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(store.metricsNamespace()),
+			MetricData: make([]types.MetricDatum, 0, end-start),
+		}
+		for _, v := range scores[start:end] {
+			metricInput.MetricData = append(metricInput.MetricData, v.metricDatum(timestamp))
+		}
+		_, err = store.cloudwatch.PutMetricData(ctx, metricInput)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unrecorded drops the scores that already have a data point in Time's
+// minute. cloudwatch can't write conditionally, so a duplicate that lands
+// at the same moment still counts.
+func (store *CloudwatchStore) unrecorded(ctx context.Context, input *RecordScoresInput) ([]*PlayerScore, error) {
+	minute := input.Time.Truncate(time.Minute)
+	recorded := make(map[string]bool)
+	for start := 0; start < len(input.Scores); start += maxMetricDataQueries {
+		end := start + maxMetricDataQueries
+		if end > len(input.Scores) {
+			end = len(input.Scores)
+		}
+
+		queries := make([]types.MetricDataQuery, 0, end-start)
+		for i, v := range input.Scores[start:end] {
+			datum := v.metricDatum(nil)
+			queries = append(queries, types.MetricDataQuery{
+				Id:    aws.String(fmt.Sprintf("query%d", i)),
+				Label: aws.String(v.PlayerId),
+				MetricStat: &types.MetricStat{
+					Metric: &types.Metric{
+						Namespace:  aws.String(store.metricsNamespace()),
+						MetricName: datum.MetricName,
+						Dimensions: datum.Dimensions,
+					},
+					Period: aws.Int32(60),
+					Stat:   aws.String("SampleCount"),
+				},
+			})
+		}
+
+		paginator := cloudwatch.NewGetMetricDataPaginator(store.cloudwatch, &cloudwatch.GetMetricDataInput{
+			StartTime:         aws.Time(minute),
+			EndTime:           aws.Time(minute.Add(time.Minute)),
+			MetricDataQueries: queries,
+		})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, result := range output.MetricDataResults {
+				for _, count := range result.Values {
+					if count > 0 {
+						recorded[*result.Label] = true
+					}
+				}
+			}
+		}
+	}
+
+	scores := make([]*PlayerScore, 0, len(input.Scores))
+	for _, v := range input.Scores {
+		if !recorded[v.PlayerId] {
+			scores = append(scores, v)
+		}
+	}
+	return scores, nil
+}
+
+// listMetrics lists both the minutes and legacy score metrics
+func (store *CloudwatchStore) listMetrics(ctx context.Context, playerId string) ([]types.Metric, error) {
+	metrics := []types.Metric{}
+	for _, name := range []string{minutesMetric, legacyScoreMetric} {
+		listMetricsInput := &cloudwatch.ListMetricsInput{
+			Namespace:  aws.String(store.metricsNamespace()),
+			MetricName: aws.String(name),
+		}
+		if playerId != "" {
+			listMetricsInput.Dimensions = []types.DimensionFilter{
+				{
+					Name:  aws.String("PlayerId"),
+					Value: aws.String(playerId),
+				},
+			}
+		}
+
+		paginator := cloudwatch.NewListMetricsPaginator(store.cloudwatch, listMetricsInput)
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, output.Metrics...)
+		}
+	}
+	return metrics, nil
+}
+
+// minimumPeriod is the finest period cloudwatch still has for data points
+// from start on, it rolls them up as they age
+func minimumPeriod(start, now time.Time) time.Duration {
+	switch age := now.Sub(start); {
+	case age > 63*24*time.Hour:
+		return time.Hour
+	case age > 15*24*time.Hour:
+		return 5 * time.Minute
+	default:
+		return time.Minute
+	}
+}
+
+// fitQuery clamps the query to cloudwatch's retention and rounds the period up
+// to one cloudwatch has at that age. buckets start at the clamped start,
+// which is on the hour.
+func fitQuery(input *QueryScoresInput, now time.Time) *QueryScoresInput {
+	fitted := *input
+	if oldest := now.Add(-CloudwatchRetention).Truncate(time.Hour); fitted.Start.Before(oldest) {
+		fitted.Start = oldest
+	}
+
+	resolution := minimumPeriod(fitted.Start, now)
+	// a single bucket covers everything
+	if span := fitted.End.Sub(fitted.Start); fitted.Period > span {
+		fitted.Period = span
+	}
+	if fitted.Period < resolution {
+		fitted.Period = resolution
+	}
+	fitted.Period = (fitted.Period + resolution - 1) / resolution * resolution
+	return &fitted
+}
+
+func (store *CloudwatchStore) QueryScores(ctx context.Context, input *QueryScoresInput) ([]*PlayerHistory, error) {
+	input = fitQuery(input, time.Now())
+	if !input.Start.Before(input.End) {
+		return []*PlayerHistory{}, nil
+	}
+
+	metrics, err := store.listMetrics(ctx, input.PlayerId)
+	if err != nil {
+		return nil, err
+	}
+
+	// as many metrics per request as keeps it under the data point limit
+	points := int((input.End.Sub(input.Start) + input.Period - 1) / input.Period)
+	batchSize := maxDataPoints / points
+	if batchSize > maxMetricDataQueries {
+		batchSize = maxMetricDataQueries
+	}
+
+	histories := make(map[string]*PlayerHistory)
+	for start := 0; start < len(metrics); start += batchSize {
+		end := start + batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		err = store.queryMetrics(ctx, input, metrics[start:end], histories)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]*PlayerHistory, 0, len(histories))
+	for _, history := range histories {
+		if len(history.Points) == 0 {
+			continue
+		}
+		sort.SliceStable(history.Points, func(i, j int) bool {
+			return history.Points[i].Time.Before(history.Points[j].Time)
+		})
+		history.Points = mergePoints(history.Points)
+		sorted = append(sorted, history)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PlayerId < sorted[j].PlayerId
+	})
+	return sorted, nil
+}
+
+// mergePoints sums sorted points that share a time, a player's minutes and
+// legacy scores come back separately
+func mergePoints(points []*ScorePoint) []*ScorePoint {
+	merged := points[:0]
+	for _, point := range points {
+		if last := len(merged) - 1; last >= 0 && merged[last].Time.Equal(point.Time) {
+			merged[last].Score += point.Score
+			continue
+		}
+		merged = append(merged, point)
+	}
+	return merged
+}
+
+// queryMetrics adds the sums of metrics in minutes to histories, keyed by
+// player id
+func (store *CloudwatchStore) queryMetrics(ctx context.Context, input *QueryScoresInput, metrics []types.Metric, histories map[string]*PlayerHistory) error {
+	queries := make([]types.MetricDataQuery, len(metrics))
+	scales := make(map[string]float64, len(metrics))
+	for i, v := range metrics {
+		v := v
+		var playerId string
+		for _, dimension := range v.Dimensions {
+			if *dimension.Name == "PlayerId" {
+				playerId = *dimension.Value
+			}
+		}
+		id := fmt.Sprintf("query%d", i)
+		scales[id] = 1
+		if aws.ToString(v.MetricName) == legacyScoreMetric {
+			scales[id] = legacyScoreMinutes
+		}
+		queries[i] = types.MetricDataQuery{
+			Id:    aws.String(id),
+			Label: aws.String(playerId),
+			MetricStat: &types.MetricStat{
+				Metric: &v,
+				Period: aws.Int32(int32(input.Period / time.Second)),
+				Stat:   aws.String("Sum"),
+			},
+		}
+	}
+
+	getMetricDataInput := &cloudwatch.GetMetricDataInput{
+		StartTime:         aws.Time(input.Start),
+		EndTime:           aws.Time(input.End),
+		MetricDataQueries: queries,
+		ScanBy:            types.ScanByTimestampAscending,
+	}
+	paginator := cloudwatch.NewGetMetricDataPaginator(store.cloudwatch, getMetricDataInput)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		// a query's values can be split across pages
+		for _, result := range output.MetricDataResults {
+			history, ok := histories[*result.Label]
+			if !ok {
+				history = &PlayerHistory{PlayerId: *result.Label, Points: []*ScorePoint{}}
+				histories[*result.Label] = history
+			}
+			for i, timestamp := range result.Timestamps {
+				history.Points = append(history.Points, &ScorePoint{
+					Time:  timestamp,
+					Score: int64(result.Values[i] * scales[*result.Id]),
+				})
+			}
+		}
+	}
+	return nil
+}
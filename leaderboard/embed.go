@@ -1,10 +1,13 @@
 package leaderboard
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/accounts"
 	"github.com/tonkat-su/bot/emoji"
 	"github.com/tonkat-su/bot/mcuser"
 )
@@ -13,14 +16,24 @@ type PrepareStandingsEmbedRequest struct {
 	Standings *Standings
 	Session   *discordgo.Session
 	GuildId   string
+
+	// Links is optional, linked players are shown with a mention
+	Links *accounts.Directory
 }
 
 func PrepareStandingsEmbed(params *PrepareStandingsEmbedRequest) (*discordgo.MessageEmbed, error) {
+	uuids := make([]string, len(params.Standings.SortedStandings))
+	for i, v := range params.Standings.SortedStandings {
+		uuids[i] = v.PlayerId
+	}
+	names := mcuser.GetUsernames(context.Background(), uuids)
+
 	players := make([]*emoji.Player, len(params.Standings.SortedStandings))
 	for i, v := range params.Standings.SortedStandings {
-		username, err := mcuser.GetUsername(v.PlayerId)
-		if err != nil {
-			return nil, fmt.Errorf("error getting username: %s", err)
+		username, ok := names[v.PlayerId]
+		if !ok {
+			// better than failing the whole leaderboard over one player
+			username = mcuser.ShortUuid(v.PlayerId)
 		}
 		players[i] = &emoji.Player{
 			Name: username,
@@ -28,6 +41,8 @@ func PrepareStandingsEmbed(params *PrepareStandingsEmbedRequest) (*discordgo.Mes
 		}
 	}
 
+	params.Links.Annotate(players)
+
 	err := emoji.HydrateEmojiIds(params.Session, params.GuildId, players)
 	if err != nil {
 		return nil, err
@@ -35,15 +50,18 @@ func PrepareStandingsEmbed(params *PrepareStandingsEmbedRequest) (*discordgo.Mes
 
 	var builder strings.Builder
 	for i, v := range params.Standings.SortedStandings {
-		fmt.Fprintf(&builder, "%s %s: %d", players[i].EmojiTextCode(), players[i].Name, v.Score)
+		fmt.Fprintf(&builder, "%s %s", players[i].EmojiTextCode(), players[i].Name)
+		if mention := players[i].Mention(); mention != "" {
+			fmt.Fprintf(&builder, " (%s)", mention)
+		}
+		fmt.Fprintf(&builder, ": %s", formatPlaytime(time.Duration(v.Score)*time.Minute))
 		if i != len(params.Standings.SortedStandings)-1 {
 			builder.WriteString("\n")
 		}
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title: `biggest nerds on the server
-(in the last 7 days)`,
+		Title: fmt.Sprintf("biggest nerds on the server\n(%s)", params.Standings.Description),
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Value: builder.String(),
@@ -3,173 +3,140 @@ package leaderboard
 import (
 	"context"
 	"errors"
-	"fmt"
-	"path/filepath"
 	"sort"
 	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
-type CloudwatchClient interface {
-	cloudwatch.ListMetricsAPIClient
-	cloudwatch.GetMetricDataAPIClient
-	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
-}
+// scores used to be a point for each 5 minute ping a player showed up in
+const legacyScoreMinutes = 5
 
-type Service struct {
-	cloudwatch      CloudwatchClient
-	namespacePrefix string
-}
+// standings start on the hour, since the bolt store only keeps hourly sums
+// and would otherwise leave out the hour a window like the last 7 days
+// starts in while cloudwatch counted it
+const standingsResolution = boltStoreResolution
 
-type Config struct {
-	NamespacePrefix string
-}
+var (
+	ErrInvalidPlayerId = errors.New("leaderboard: got invalid player id")
+	ErrOnceWithoutTime = errors.New("leaderboard: recording once needs a time")
+)
 
-func New(awsCfg aws.Config, cfg *Config) (*Service, error) {
-	return &Service{
-		cloudwatch:      cloudwatch.NewFromConfig(awsCfg),
-		namespacePrefix: cfg.NamespacePrefix,
-	}, nil
+// Store keeps players' scores over time
+type Store interface {
+	RecordScores(ctx context.Context, input *RecordScoresInput) error
+	// QueryScores sums each player's scores between Start and End into
+	// buckets of Period starting at Start, leaving out players and buckets
+	// with nothing recorded
+	QueryScores(ctx context.Context, input *QueryScoresInput) ([]*PlayerHistory, error)
 }
 
 type RecordScoresInput struct {
 	Scores []*PlayerScore
+	// Time is when the scores were earned, now if it's zero
+	Time time.Time
+	// Once records each player's score at Time at most once, so a retried or
+	// duplicated write doesn't count twice. Time is required.
+	Once bool
 }
 
+// PlayerScore is minutes played
 type PlayerScore struct {
 	PlayerId string
 	Score    int64
 }
 
-func (score *PlayerScore) metricDatum() types.MetricDatum {
-	return types.MetricDatum{
-		Dimensions: []types.Dimension{
-			{
-				Name:  aws.String("PlayerId"),
-				Value: aws.String(score.PlayerId),
-			},
-		},
-		MetricName: aws.String("PlayerScore"),
-		Value:      aws.Float64(float64(score.Score)),
-	}
+type QueryScoresInput struct {
+	Start  time.Time
+	End    time.Time
+	Period time.Duration
+	// PlayerId is optional, only that player's scores are returned if it's set
+	PlayerId string
 }
 
-func (svc *Service) metricsNamespace() string {
-	return filepath.Join(svc.namespacePrefix, "Leaderboard")
+// PlayerHistory is a player's scores summed into buckets, oldest first
+type PlayerHistory struct {
+	PlayerId string
+	Points   []*ScorePoint
 }
 
-func (svc *Service) RecordScores(ctx context.Context, input *RecordScoresInput) error {
-	metricInput := &cloudwatch.PutMetricDataInput{
-		Namespace:  aws.String(svc.metricsNamespace()),
-		MetricData: make([]types.MetricDatum, len(input.Scores)),
+// ScorePoint is the sum of the scores in the bucket starting at Time
+type ScorePoint struct {
+	Time  time.Time
+	Score int64
+}
+
+// Total sums every point in the history
+func (h *PlayerHistory) Total() int64 {
+	var total int64
+	for _, point := range h.Points {
+		total += point.Score
 	}
+	return total
+}
 
-	for i, v := range input.Scores {
+func validateScores(input *RecordScoresInput) error {
+	if input.Once && input.Time.IsZero() {
+		return ErrOnceWithoutTime
+	}
+	for _, v := range input.Scores {
 		if v.PlayerId == "" {
-			return errors.New("leaderboard: got invalid player id")
+			return ErrInvalidPlayerId
 		}
-		metricInput.MetricData[i] = v.metricDatum()
-	}
-
-	_, err := svc.cloudwatch.PutMetricData(ctx, metricInput)
-	if err != nil {
-		return err
 	}
 	return nil
 }
 
+type Service struct {
+	store Store
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+func (svc *Service) RecordScores(ctx context.Context, input *RecordScoresInput) error {
+	return svc.store.RecordScores(ctx, input)
+}
+
 type Standings struct {
 	SortedStandings []*PlayerScore
 	LastUpdated     time.Time
+	// Description says which window the standings cover
+	Description string
 }
 
-func (svc *Service) fetchStandingsFromLastWeek(ctx context.Context, endTime time.Time) ([]types.MetricDataResult, error) {
-	listMetricsInput := &cloudwatch.ListMetricsInput{
-		Namespace:  aws.String(svc.metricsNamespace()),
-		MetricName: aws.String("PlayerScore"),
-	}
-	metrics := []types.Metric{}
-	paginator := cloudwatch.NewListMetricsPaginator(svc.cloudwatch, listMetricsInput)
-	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, err
-		}
-		metrics = append(metrics, output.Metrics...)
-	}
-
-	queries := make([]types.MetricDataQuery, len(metrics))
-	for i, v := range metrics {
-		v := v
-		var playerName string
-		for _, dimension := range v.Dimensions {
-			if *dimension.Name == "PlayerId" {
-				playerName = *dimension.Value
-			}
-		}
-		queries[i] = types.MetricDataQuery{
-			Id:    aws.String(fmt.Sprintf("query%d", i)),
-			Label: &playerName,
-			MetricStat: &types.MetricStat{
-				Metric: &v,
-				Period: aws.Int32(21600),
-				Stat:   aws.String("Sum"),
-			},
-		}
+// GetStandings sums every player's scores over the query's window, highest
+// first. the window starts on the hour before query.Start, see
+// standingsResolution.
+func (svc *Service) GetStandings(ctx context.Context, query *StandingsQuery) (*Standings, error) {
+	start := query.Start.Truncate(standingsResolution)
+	histories, err := svc.store.QueryScores(ctx, &QueryScoresInput{
+		Start:  start,
+		End:    query.End,
+		Period: query.End.Sub(start),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	getMetricDataInput := &cloudwatch.GetMetricDataInput{
-		EndTime:           aws.Time(endTime),
-		StartTime:         aws.Time(endTime.Add(-1 * 7 * 24 * time.Hour)),
-		MetricDataQueries: queries,
+	lastUpdated := query.End
+	if now := time.Now(); lastUpdated.After(now) {
+		lastUpdated = now
 	}
-	results := []types.MetricDataResult{}
-	getPaginator := cloudwatch.NewGetMetricDataPaginator(svc.cloudwatch, getMetricDataInput)
-	for getPaginator.HasMorePages() {
-		output, err := getPaginator.NextPage(ctx)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, output.MetricDataResults...)
-	}
-	return results, nil
-}
-
-func transformCloudwatchResultsToStandings(results []types.MetricDataResult) *Standings {
 	standings := &Standings{
-		SortedStandings: make([]*PlayerScore, 0, len(results)),
+		SortedStandings: make([]*PlayerScore, 0, len(histories)),
+		LastUpdated:     lastUpdated,
+		Description:     query.Description,
 	}
-
-	for _, v := range results {
-		score := &PlayerScore{
-			PlayerId: *v.Label,
-		}
-		for _, value := range v.Values {
-			score.Score += int64(value)
-		}
-
-		if score.Score > 0 {
-			standings.SortedStandings = append(standings.SortedStandings, score)
+	for _, history := range histories {
+		if total := history.Total(); total > 0 {
+			standings.SortedStandings = append(standings.SortedStandings, &PlayerScore{
+				PlayerId: history.PlayerId,
+				Score:    total,
+			})
 		}
 	}
-
 	sort.SliceStable(standings.SortedStandings, func(i, j int) bool {
 		return standings.SortedStandings[i].Score > standings.SortedStandings[j].Score
 	})
-
-	return standings
-}
-
-func (svc *Service) GetStandings(ctx context.Context) (*Standings, error) {
-	endTime := time.Now().Round(5 * time.Minute)
-	results, err := svc.fetchStandingsFromLastWeek(ctx, endTime)
-	if err != nil {
-		return nil, err
-	}
-	standings := transformCloudwatchResultsToStandings(results)
-	standings.LastUpdated = endTime
 	return standings, nil
 }
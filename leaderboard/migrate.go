@@ -0,0 +1,44 @@
+package leaderboard
+
+import (
+	"context"
+	"time"
+)
+
+// Copy records every score in from between start and end into to, summed
+// into buckets of period, and returns how many points it copied
+func Copy(ctx context.Context, from, to Store, start, end time.Time, period time.Duration) (int, error) {
+	histories, err := from.QueryScores(ctx, &QueryScoresInput{
+		Start:  start,
+		End:    end,
+		Period: period,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// group by time so each bucket is one write
+	buckets := make(map[time.Time][]*PlayerScore)
+	var times []time.Time
+	copied := 0
+	for _, history := range histories {
+		for _, point := range history.Points {
+			if _, ok := buckets[point.Time]; !ok {
+				times = append(times, point.Time)
+			}
+			buckets[point.Time] = append(buckets[point.Time], &PlayerScore{
+				PlayerId: history.PlayerId,
+				Score:    point.Score,
+			})
+			copied++
+		}
+	}
+
+	for _, at := range times {
+		err = to.RecordScores(ctx, &RecordScoresInput{Scores: buckets[at], Time: at})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,98 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateLayout is how custom ranges are written
+const DateLayout = "2006-01-02"
+
+// all time starts before the leaderboard existed, stores don't have
+// anything older
+var allTimeStart = time.Unix(0, 0)
+
+var ErrInvalidRange = errors.New("leaderboard: since has to be before until")
+
+// Period names a window of standings relative to now
+type Period string
+
+const (
+	PeriodLastWeek  Period = "last_7_days"
+	PeriodToday     Period = "today"
+	PeriodThisWeek  Period = "this_week"
+	PeriodThisMonth Period = "this_month"
+	PeriodLastMonth Period = "last_month"
+	PeriodAllTime   Period = "all_time"
+)
+
+// Periods lists every period in the order they're offered
+var Periods = []Period{PeriodLastWeek, PeriodToday, PeriodThisWeek, PeriodThisMonth, PeriodLastMonth, PeriodAllTime}
+
+// StandingsQuery is the window standings are summed over
+type StandingsQuery struct {
+	Start time.Time
+	End   time.Time
+	// Description finishes "biggest nerds on the server", e.g. "in the last 7 days"
+	Description string
+}
+
+// Name is how the period is shown as a choice
+func (p Period) Name() string {
+	switch p {
+	case PeriodLastWeek:
+		return "last 7 days"
+	case PeriodToday:
+		return "today"
+	case PeriodThisWeek:
+		return "this week"
+	case PeriodThisMonth:
+		return "this month"
+	case PeriodLastMonth:
+		return "last month"
+	case PeriodAllTime:
+		return "all time"
+	}
+	return string(p)
+}
+
+// Query is the window the period covers at now, calendar periods start at
+// midnight in now's location and weeks start on monday
+func (p Period) Query(now time.Time) (*StandingsQuery, error) {
+	end := now.Round(5 * time.Minute)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	switch p {
+	case PeriodLastWeek:
+		return &StandingsQuery{Start: end.Add(-7 * 24 * time.Hour), End: end, Description: "in the last 7 days"}, nil
+	case PeriodToday:
+		return &StandingsQuery{Start: midnight, End: end, Description: "today"}, nil
+	case PeriodThisWeek:
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		return &StandingsQuery{Start: midnight.AddDate(0, 0, -daysSinceMonday), End: end, Description: "this week"}, nil
+	case PeriodThisMonth:
+		return &StandingsQuery{Start: firstOfMonth, End: end, Description: "this month"}, nil
+	case PeriodLastMonth:
+		start := firstOfMonth.AddDate(0, -1, 0)
+		return &StandingsQuery{Start: start, End: firstOfMonth, Description: "in " + start.Format("January 2006")}, nil
+	case PeriodAllTime:
+		return &StandingsQuery{Start: allTimeStart, End: end, Description: "of all time"}, nil
+	}
+	return nil, fmt.Errorf("leaderboard: unknown period '%s'", p)
+}
+
+// RangeQuery covers whole days from since through until
+func RangeQuery(since, until time.Time) (*StandingsQuery, error) {
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	end := time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, until.Location()).AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return nil, ErrInvalidRange
+	}
+	return &StandingsQuery{
+		Start:       start,
+		End:         end,
+		Description: fmt.Sprintf("from %s to %s", start.Format(DateLayout), until.Format(DateLayout)),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package leaderboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPeriodQuery(t *testing.T) {
+	// a sunday afternoon
+	now := time.Date(2026, 10, 18, 15, 2, 0, 0, time.UTC)
+
+	tests := []struct {
+		period      Period
+		start       time.Time
+		end         time.Time
+		description string
+	}{
+		{PeriodLastWeek, time.Date(2026, 10, 11, 15, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), "in the last 7 days"},
+		{PeriodToday, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), "today"},
+		{PeriodThisWeek, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), "this week"},
+		{PeriodThisMonth, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), "this month"},
+		{PeriodLastMonth, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), "in September 2026"},
+		{PeriodAllTime, allTimeStart, time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), "of all time"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.period), func(t *testing.T) {
+			query, err := test.period.Query(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !query.Start.Equal(test.start) || !query.End.Equal(test.end) {
+				t.Errorf("got %s to %s, want %s to %s", query.Start, query.End, test.start, test.end)
+			}
+			if query.Description != test.description {
+				t.Errorf("got description %q, want %q", query.Description, test.description)
+			}
+		})
+	}
+
+	_, err := Period("fortnight").Query(now)
+	if err == nil {
+		t.Error("expected an error for an unknown period")
+	}
+}
+
+func TestRangeQuery(t *testing.T) {
+	since := time.Date(2026, 9, 20, 13, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 9, 22, 0, 0, 0, 0, time.UTC)
+
+	query, err := RangeQuery(since, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStart := time.Date(2026, 9, 20, 0, 0, 0, 0, time.UTC)
+	wantEnd := time.Date(2026, 9, 23, 0, 0, 0, 0, time.UTC)
+	if !query.Start.Equal(wantStart) || !query.End.Equal(wantEnd) {
+		t.Errorf("got %s to %s, want %s to %s", query.Start, query.End, wantStart, wantEnd)
+	}
+	if query.Description != "from 2026-09-20 to 2026-09-22" {
+		t.Errorf("got description %q", query.Description)
+	}
+
+	_, err = RangeQuery(until, since.AddDate(0, 0, -1))
+	if !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidRange)
+	}
+}
+
+func TestFitQuery(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 2, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		input  QueryScoresInput
+		start  time.Time
+		period time.Duration
+	}{
+		{
+			name:   "recent",
+			input:  QueryScoresInput{Start: now.Add(-time.Hour), End: now, Period: 10 * time.Second},
+			start:  now.Add(-time.Hour),
+			period: time.Minute,
+		},
+		{
+			name:   "month old",
+			input:  QueryScoresInput{Start: now.AddDate(0, -1, 0), End: now, Period: 7 * time.Minute},
+			start:  now.AddDate(0, -1, 0),
+			period: 10 * time.Minute,
+		},
+		{
+			name:   "year old",
+			input:  QueryScoresInput{Start: now.AddDate(-1, 0, 0), End: now, Period: 90 * time.Minute},
+			start:  now.AddDate(-1, 0, 0),
+			period: 2 * time.Hour,
+		},
+		{
+			name:   "past retention",
+			input:  QueryScoresInput{Start: allTimeStart, End: now, Period: now.Sub(allTimeStart)},
+			start:  now.Add(-CloudwatchRetention).Truncate(time.Hour),
+			period: CloudwatchRetention + time.Hour,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fitted := fitQuery(&test.input, now)
+			if !fitted.Start.Equal(test.start) {
+				t.Errorf("got start %s, want %s", fitted.Start, test.start)
+			}
+			if fitted.Period != test.period {
+				t.Errorf("got period %s, want %s", fitted.Period, test.period)
+			}
+		})
+	}
+}
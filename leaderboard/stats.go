@@ -0,0 +1,108 @@
+package leaderboard
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// stats look back as far as cloudwatch keeps scores
+	statsHistoryDays = 454
+	// and chart this many days
+	ChartDays = 30
+)
+
+// PlayerStats summarizes a player's scores, days are 24 hour buckets ending
+// at the next midnight in now's location
+type PlayerStats struct {
+	PlayerId string
+	// Total and Rank are over all time, Rank is 1 for the top scorer and 0 if
+	// the player has no score
+	Total   int64
+	Rank    int
+	Players int
+
+	// Days are the last ChartDays days, oldest first, today included
+	Days []*ScorePoint
+	// DailyAverage is the average minutes played per day since FirstSeen
+	DailyAverage float64
+	// Streak is how many days in a row up to today the player has scored,
+	// today doesn't break it until it's over
+	Streak int
+	// FirstSeen and LastSeen are the start of the first and last days with a
+	// score, zero if there are none
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+func (svc *Service) GetPlayerStats(ctx context.Context, playerId string, now time.Time) (*PlayerStats, error) {
+	allTime, err := PeriodAllTime.Query(now)
+	if err != nil {
+		return nil, err
+	}
+	standings, err := svc.GetStandings(ctx, allTime)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &PlayerStats{
+		PlayerId: playerId,
+		Players:  len(standings.SortedStandings),
+	}
+	for i, v := range standings.SortedStandings {
+		if v.PlayerId == playerId {
+			stats.Total = v.Score
+			stats.Rank = i + 1
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	histories, err := svc.store.QueryScores(ctx, &QueryScoresInput{
+		Start:    today.Add(-statsHistoryDays * 24 * time.Hour),
+		End:      today.Add(24 * time.Hour),
+		Period:   24 * time.Hour,
+		PlayerId: playerId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	daily := make(map[int64]int64)
+	for _, history := range histories {
+		for _, point := range history.Points {
+			if point.Score <= 0 {
+				continue
+			}
+			daily[point.Time.Unix()] += point.Score
+			if stats.FirstSeen.IsZero() || point.Time.Before(stats.FirstSeen) {
+				stats.FirstSeen = point.Time
+			}
+			if point.Time.After(stats.LastSeen) {
+				stats.LastSeen = point.Time
+			}
+		}
+	}
+
+	stats.Days = make([]*ScorePoint, ChartDays)
+	for i := range stats.Days {
+		day := today.Add(time.Duration(i-ChartDays+1) * 24 * time.Hour)
+		stats.Days[i] = &ScorePoint{Time: day, Score: daily[day.Unix()]}
+	}
+
+	day := today
+	if daily[day.Unix()] == 0 {
+		day = day.Add(-24 * time.Hour)
+	}
+	for ; daily[day.Unix()] > 0; day = day.Add(-24 * time.Hour) {
+		stats.Streak++
+	}
+
+	if !stats.FirstSeen.IsZero() {
+		days := int(today.Sub(stats.FirstSeen)/(24*time.Hour)) + 1
+		var sum int64
+		for _, score := range daily {
+			sum += score
+		}
+		stats.DailyAverage = float64(sum) / float64(days)
+	}
+	return stats, nil
+}
@@ -0,0 +1,111 @@
+package leaderboard
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/mcuser"
+)
+
+const chartAttachmentName = "playtime.png"
+
+type PrepareStatsEmbedRequest struct {
+	Stats      *PlayerStats
+	PlayerName string
+	Now        time.Time
+
+	// DiscordUserId is optional, a linked member is mentioned
+	DiscordUserId string
+}
+
+type PrepareStatsEmbedResponse struct {
+	Embed *discordgo.MessageEmbed
+	Files []*discordgo.File
+}
+
+func PrepareStatsEmbed(params *PrepareStatsEmbedRequest) (*PrepareStatsEmbedResponse, error) {
+	stats := params.Stats
+	playtime := func(minutes int64) time.Duration {
+		return time.Duration(minutes) * time.Minute
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:     params.PlayerName,
+		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: mcuser.FaceUrl(params.PlayerName)},
+	}
+	if params.DiscordUserId != "" {
+		embed.Description = "<@" + params.DiscordUserId + ">"
+	}
+
+	if stats.FirstSeen.IsZero() {
+		if embed.Description != "" {
+			embed.Description += "\n"
+		}
+		embed.Description += "hasn't played yet"
+		return &PrepareStatsEmbedResponse{Embed: embed}, nil
+	}
+
+	rank := "unranked"
+	if stats.Rank > 0 {
+		rank = fmt.Sprintf("#%d of %d", stats.Rank, stats.Players)
+	}
+	streak := "none"
+	if stats.Streak > 0 {
+		streak = fmt.Sprintf("%d days", stats.Streak)
+		if stats.Streak == 1 {
+			streak = "1 day"
+		}
+	}
+
+	embed.Fields = []*discordgo.MessageEmbedField{
+		{Name: "playtime", Value: formatPlaytime(playtime(stats.Total)), Inline: true},
+		{Name: "rank", Value: rank, Inline: true},
+		{Name: "daily average", Value: formatPlaytime(time.Duration(stats.DailyAverage * float64(time.Minute))), Inline: true},
+		{Name: "streak", Value: streak, Inline: true},
+		{Name: "last seen", Value: formatDay(stats.LastSeen, params.Now), Inline: true},
+		{Name: "first seen", Value: formatDay(stats.FirstSeen, params.Now), Inline: true},
+	}
+
+	chart, err := RenderChart(stats.Days)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering playtime chart: %s", err)
+	}
+	embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + chartAttachmentName}
+	embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("playtime per day, last %d days", len(stats.Days))}
+
+	return &PrepareStatsEmbedResponse{
+		Embed: embed,
+		Files: []*discordgo.File{
+			{
+				Name:        chartAttachmentName,
+				ContentType: "image/png",
+				Reader:      bytes.NewReader(chart),
+			},
+		},
+	}, nil
+}
+
+// formatPlaytime rounds d to the minute, keeping long playtimes in hours
+func formatPlaytime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := d / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+// formatDay shows the day starting at day relative to now's day
+func formatDay(day, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch {
+	case !day.Before(today):
+		return "today"
+	case !day.Before(today.Add(-24 * time.Hour)):
+		return "yesterday"
+	}
+	return fmt.Sprintf("<t:%d:D>", day.Unix())
+}
@@ -0,0 +1,90 @@
+package leaderboard
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetPlayerStats(t *testing.T) {
+	store := NewBoltStore(filepath.Join(t.TempDir(), "leaderboard.db"))
+	now := time.Date(2026, 10, 18, 15, 2, 0, 0, time.UTC)
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	record := func(at time.Time, scores ...*PlayerScore) {
+		t.Helper()
+		err := store.RecordScores(context.Background(), &RecordScoresInput{Scores: scores, Time: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// played 40 days ago, then yesterday and the two days before, not yet today
+	record(today.AddDate(0, 0, -40).Add(20*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 6})
+	record(today.AddDate(0, 0, -3).Add(10*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 2})
+	record(today.AddDate(0, 0, -2).Add(10*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 12})
+	record(today.AddDate(0, 0, -1).Add(23*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 1}, &PlayerScore{PlayerId: jcmp, Score: 100})
+
+	stats, err := NewService(store).GetPlayerStats(context.Background(), bsdlp, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Total != 21 || stats.Rank != 2 || stats.Players != 2 {
+		t.Errorf("got total %d rank %d of %d, want 21 rank 2 of 2", stats.Total, stats.Rank, stats.Players)
+	}
+	if stats.Streak != 3 {
+		t.Errorf("got streak %d, want 3", stats.Streak)
+	}
+	if want := today.AddDate(0, 0, -40); !stats.FirstSeen.Equal(want) {
+		t.Errorf("got first seen %s, want %s", stats.FirstSeen, want)
+	}
+	if want := today.AddDate(0, 0, -1); !stats.LastSeen.Equal(want) {
+		t.Errorf("got last seen %s, want %s", stats.LastSeen, want)
+	}
+	if want := 21.0 / 41; stats.DailyAverage != want {
+		t.Errorf("got daily average %f, want %f", stats.DailyAverage, want)
+	}
+
+	if len(stats.Days) != ChartDays {
+		t.Fatalf("got %d days, want %d", len(stats.Days), ChartDays)
+	}
+	if last := stats.Days[ChartDays-1]; !last.Time.Equal(today) || last.Score != 0 {
+		t.Errorf("got last day %s=%d, want today with nothing", last.Time, last.Score)
+	}
+	if day := stats.Days[ChartDays-3]; day.Score != 12 {
+		t.Errorf("got %d for two days ago, want 12", day.Score)
+	}
+
+	stats, err = NewService(store).GetPlayerStats(context.Background(), "somebody else", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rank != 0 || !stats.FirstSeen.IsZero() || stats.Streak != 0 {
+		t.Errorf("got %+v for a player with no scores", stats)
+	}
+}
+
+func TestRenderChart(t *testing.T) {
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	days := make([]*ScorePoint, ChartDays)
+	for i := range days {
+		days[i] = &ScorePoint{Time: today.AddDate(0, 0, i-ChartDays+1), Score: int64(i * 7)}
+	}
+
+	for _, days := range [][]*ScorePoint{days, nil} {
+		chart, err := RenderChart(days)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(chart))
+		if err != nil {
+			t.Fatalf("error decoding chart: %s", err)
+		}
+		if size := img.Bounds().Size(); size.X != chartWidth || size.Y != chartHeight {
+			t.Errorf("got a %dx%d chart, want %dx%d", size.X, size.Y, chartWidth, chartHeight)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	bsdlp = "a7ec7d80-a1f4-4d2c-9b6b-4ffb0e1f2f8d"
+	jcmp  = "3c2a3c71-53f4-4a4c-8fc5-2b2cbd6b6d3e"
+)
+
+// recent enough that cloudwatch still has it at full resolution
+var day = time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+
+// testStore is the conformance suite every Store has to pass
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	ctx := context.Background()
+
+	record := func(t *testing.T, store Store, at time.Time, scores ...*PlayerScore) {
+		t.Helper()
+		err := store.RecordScores(ctx, &RecordScoresInput{Scores: scores, Time: at})
+		if err != nil {
+			t.Fatalf("error recording scores: %s", err)
+		}
+	}
+	query := func(t *testing.T, store Store, input *QueryScoresInput) []*PlayerHistory {
+		t.Helper()
+		histories, err := store.QueryScores(ctx, input)
+		if err != nil {
+			t.Fatalf("error querying scores: %s", err)
+		}
+		return histories
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		store := newStore(t)
+		histories := query(t, store, &QueryScoresInput{Start: day, End: day.Add(24 * time.Hour), Period: time.Hour})
+		if len(histories) != 0 {
+			t.Fatalf("got %d histories from an empty store, want none", len(histories))
+		}
+	})
+
+	t.Run("buckets", func(t *testing.T) {
+		store := newStore(t)
+		record(t, store, day.Add(1*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 1}, &PlayerScore{PlayerId: jcmp, Score: 1})
+		record(t, store, day.Add(1*time.Hour+5*time.Minute), &PlayerScore{PlayerId: bsdlp, Score: 1})
+		record(t, store, day.Add(7*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 3})
+		// outside the range on either side
+		record(t, store, day.Add(-time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 100})
+		record(t, store, day.Add(24*time.Hour), &PlayerScore{PlayerId: jcmp, Score: 100})
+
+		got := query(t, store, &QueryScoresInput{Start: day, End: day.Add(24 * time.Hour), Period: 6 * time.Hour})
+		// sorted by player id
+		want := []*PlayerHistory{
+			{PlayerId: jcmp, Points: []*ScorePoint{
+				{Time: day, Score: 1},
+			}},
+			{PlayerId: bsdlp, Points: []*ScorePoint{
+				{Time: day, Score: 2},
+				{Time: day.Add(6 * time.Hour), Score: 3},
+			}},
+		}
+		assertHistories(t, got, want)
+	})
+
+	t.Run("player", func(t *testing.T) {
+		store := newStore(t)
+		record(t, store, day, &PlayerScore{PlayerId: bsdlp, Score: 1}, &PlayerScore{PlayerId: jcmp, Score: 2})
+
+		got := query(t, store, &QueryScoresInput{Start: day, End: day.Add(time.Hour), Period: time.Hour, PlayerId: jcmp})
+		assertHistories(t, got, []*PlayerHistory{
+			{PlayerId: jcmp, Points: []*ScorePoint{{Time: day, Score: 2}}},
+		})
+	})
+
+	t.Run("invalid player", func(t *testing.T) {
+		store := newStore(t)
+		err := store.RecordScores(ctx, &RecordScoresInput{Scores: []*PlayerScore{{Score: 1}}, Time: day})
+		if !errors.Is(err, ErrInvalidPlayerId) {
+			t.Fatalf("got error %v, want %v", err, ErrInvalidPlayerId)
+		}
+	})
+
+	t.Run("once", func(t *testing.T) {
+		store := newStore(t)
+		at := day.Add(90 * time.Minute)
+		for i := 0; i < 2; i++ {
+			err := store.RecordScores(ctx, &RecordScoresInput{
+				Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 5}},
+				Time:   at,
+				Once:   true,
+			})
+			if err != nil {
+				t.Fatalf("error recording scores: %s", err)
+			}
+		}
+		// a retry that picks up someone new only counts them
+		err := store.RecordScores(ctx, &RecordScoresInput{
+			Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 5}, {PlayerId: jcmp, Score: 5}},
+			Time:   at,
+			Once:   true,
+		})
+		if err != nil {
+			t.Fatalf("error recording scores: %s", err)
+		}
+		record(t, store, at.Add(5*time.Minute), &PlayerScore{PlayerId: bsdlp, Score: 5})
+
+		got := query(t, store, &QueryScoresInput{Start: day, End: day.Add(24 * time.Hour), Period: 24 * time.Hour})
+		assertHistories(t, got, []*PlayerHistory{
+			{PlayerId: jcmp, Points: []*ScorePoint{{Time: day, Score: 5}}},
+			{PlayerId: bsdlp, Points: []*ScorePoint{{Time: day, Score: 10}}},
+		})
+
+		err = store.RecordScores(ctx, &RecordScoresInput{Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 5}}, Once: true})
+		if !errors.Is(err, ErrOnceWithoutTime) {
+			t.Fatalf("got error %v, want %v", err, ErrOnceWithoutTime)
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		from, to := newStore(t), newStore(t)
+		record(t, from, day, &PlayerScore{PlayerId: bsdlp, Score: 1})
+		record(t, from, day.Add(2*time.Hour), &PlayerScore{PlayerId: bsdlp, Score: 2}, &PlayerScore{PlayerId: jcmp, Score: 4})
+
+		copied, err := Copy(ctx, from, to, day, day.Add(24*time.Hour), time.Hour)
+		if err != nil {
+			t.Fatalf("error copying: %s", err)
+		}
+		if copied != 3 {
+			t.Fatalf("copied %d points, want 3", copied)
+		}
+
+		input := &QueryScoresInput{Start: day, End: day.Add(24 * time.Hour), Period: time.Hour}
+		assertHistories(t, query(t, to, input), query(t, from, input))
+	})
+}
+
+func assertHistories(t *testing.T, got, want []*PlayerHistory) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %s, want %s", formatHistories(got), formatHistories(want))
+	}
+}
+
+func formatHistories(histories []*PlayerHistory) string {
+	var builder strings.Builder
+	for _, history := range histories {
+		builder.WriteString(history.PlayerId + ":")
+		for _, point := range history.Points {
+			builder.WriteString(" " + point.Time.UTC().Format(time.RFC3339) + "=" + time.Duration(point.Score).String())
+		}
+		builder.WriteString("; ")
+	}
+	return builder.String()
+}
+
+func TestBoltStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewBoltStore(filepath.Join(t.TempDir(), "leaderboard.db"))
+	})
+}
+
+func TestCloudwatchStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return &CloudwatchStore{cloudwatch: &fakeCloudwatch{}, namespacePrefix: "froggyland"}
+	})
+}
+
+func TestBoltStoreRetention(t *testing.T) {
+	store := NewBoltStore(filepath.Join(t.TempDir(), "leaderboard.db"))
+	old := day.Add(-boltStoreRetention)
+	for _, at := range []time.Time{old.Add(-time.Hour), old, day} {
+		err := store.RecordScores(context.Background(), &RecordScoresInput{
+			Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 1}},
+			Time:   at,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	histories, err := store.QueryScores(context.Background(), &QueryScoresInput{Start: old.Add(-2 * time.Hour), End: day.Add(time.Hour), Period: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the oldest hour aged out once day was recorded
+	assertHistories(t, histories, []*PlayerHistory{
+		{PlayerId: bsdlp, Points: []*ScorePoint{{Time: old, Score: 1}, {Time: day, Score: 1}}},
+	})
+}
+
+func TestCloudwatchStoreLegacy(t *testing.T) {
+	fake := &fakeCloudwatch{}
+	store := &CloudwatchStore{cloudwatch: fake, namespacePrefix: "froggyland"}
+	legacy := (&PlayerScore{PlayerId: bsdlp, Score: 2}).metricDatum(aws.Time(day))
+	legacy.MetricName = aws.String(legacyScoreMetric)
+	_, err := fake.PutMetricData(context.Background(), &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(store.metricsNamespace()),
+		MetricData: []types.MetricDatum{legacy},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.RecordScores(context.Background(), &RecordScoresInput{
+		Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 1}},
+		Time:   day,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histories, err := store.QueryScores(context.Background(), &QueryScoresInput{Start: day, End: day.Add(time.Hour), Period: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertHistories(t, histories, []*PlayerHistory{
+		{PlayerId: bsdlp, Points: []*ScorePoint{{Time: day, Score: 2*legacyScoreMinutes + 1}}},
+	})
+}
+
+func TestGetStandings(t *testing.T) {
+	store := NewBoltStore(filepath.Join(t.TempDir(), "leaderboard.db"))
+	now := time.Now()
+	err := store.RecordScores(context.Background(), &RecordScoresInput{
+		Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 1}, {PlayerId: jcmp, Score: 3}},
+		Time:   now.Add(-24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// too long ago to count
+	err = store.RecordScores(context.Background(), &RecordScoresInput{
+		Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 10}},
+		Time:   now.Add(-8 * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := PeriodLastWeek.Query(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standings, err := NewService(store).GetStandings(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*PlayerScore{{PlayerId: jcmp, Score: 3}, {PlayerId: bsdlp, Score: 1}}
+	if !reflect.DeepEqual(standings.SortedStandings, want) {
+		t.Fatalf("got standings %+v, want %+v", standings.SortedStandings, want)
+	}
+}
+
+func TestGetStandingsBoundaries(t *testing.T) {
+	ctx := context.Background()
+	hour := time.Now().Truncate(time.Hour).Add(-48 * time.Hour)
+	// like the last 7 days, starting within an hour
+	query := &StandingsQuery{Start: hour.Add(30 * time.Minute), End: hour.Add(24 * time.Hour)}
+
+	stores := map[string]Store{
+		"bolt":       NewBoltStore(filepath.Join(t.TempDir(), "leaderboard.db")),
+		"cloudwatch": &CloudwatchStore{cloudwatch: &fakeCloudwatch{}, namespacePrefix: "froggyland"},
+	}
+	for name, store := range stores {
+		for _, at := range []time.Duration{-10 * time.Minute, 10 * time.Minute, 40 * time.Minute, 2 * time.Hour} {
+			err := store.RecordScores(ctx, &RecordScoresInput{
+				Scores: []*PlayerScore{{PlayerId: bsdlp, Score: 1}},
+				Time:   hour.Add(at),
+			})
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+	}
+
+	// the hour the window starts in counts in full for both
+	want := []*PlayerScore{{PlayerId: bsdlp, Score: 3}}
+	for name, store := range stores {
+		standings, err := NewService(store).GetStandings(ctx, query)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !reflect.DeepEqual(standings.SortedStandings, want) {
+			t.Errorf("%s: got standings %+v, want %+v", name, standings.SortedStandings, want)
+		}
+	}
+}
+
+// fakeCloudwatch keeps metric data in memory and sums it like cloudwatch
+type fakeCloudwatch struct {
+	mu     sync.Mutex
+	datums []fakeDatum
+}
+
+type fakeDatum struct {
+	namespace string
+	metric    types.Metric
+	timestamp time.Time
+	value     float64
+}
+
+func (f *fakeCloudwatch) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, datum := range params.MetricData {
+		timestamp := time.Now()
+		if datum.Timestamp != nil {
+			timestamp = *datum.Timestamp
+		}
+		f.datums = append(f.datums, fakeDatum{
+			namespace: *params.Namespace,
+			metric: types.Metric{
+				Namespace:  params.Namespace,
+				MetricName: datum.MetricName,
+				Dimensions: datum.Dimensions,
+			},
+			timestamp: timestamp,
+			value:     *datum.Value,
+		})
+	}
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func (f *fakeCloudwatch) ListMetrics(ctx context.Context, params *cloudwatch.ListMetricsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.ListMetricsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	seen := make(map[string]bool)
+	output := &cloudwatch.ListMetricsOutput{}
+	for _, datum := range f.datums {
+		if datum.namespace != *params.Namespace || *datum.metric.MetricName != *params.MetricName {
+			continue
+		}
+		if !matchesDimensions(datum.metric.Dimensions, params.Dimensions) {
+			continue
+		}
+		key := metricKey(datum.metric)
+		if !seen[key] {
+			seen[key] = true
+			output.Metrics = append(output.Metrics, datum.metric)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeCloudwatch) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	output := &cloudwatch.GetMetricDataOutput{}
+	for _, query := range params.MetricDataQueries {
+		period := time.Duration(*query.MetricStat.Period) * time.Second
+		sums := make(map[time.Time]float64)
+		for _, datum := range f.datums {
+			if metricKey(datum.metric) != metricKey(*query.MetricStat.Metric) {
+				continue
+			}
+			if datum.timestamp.Before(*params.StartTime) || !datum.timestamp.Before(*params.EndTime) {
+				continue
+			}
+			bucket := params.StartTime.Add(datum.timestamp.Sub(*params.StartTime) / period * period)
+			if aws.ToString(query.MetricStat.Stat) == "SampleCount" {
+				sums[bucket]++
+			} else {
+				sums[bucket] += datum.value
+			}
+		}
+
+		result := types.MetricDataResult{Id: query.Id, Label: query.Label}
+		for bucket := range sums {
+			result.Timestamps = append(result.Timestamps, bucket)
+		}
+		sort.Slice(result.Timestamps, func(i, j int) bool {
+			if params.ScanBy == types.ScanByTimestampAscending {
+				return result.Timestamps[i].Before(result.Timestamps[j])
+			}
+			return result.Timestamps[i].After(result.Timestamps[j])
+		})
+		for _, timestamp := range result.Timestamps {
+			result.Values = append(result.Values, sums[timestamp])
+		}
+		output.MetricDataResults = append(output.MetricDataResults, result)
+	}
+	return output, nil
+}
+
+func matchesDimensions(dimensions []types.Dimension, filters []types.DimensionFilter) bool {
+	for _, filter := range filters {
+		matched := false
+		for _, dimension := range dimensions {
+			if *dimension.Name == *filter.Name && (filter.Value == nil || *dimension.Value == *filter.Value) {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func metricKey(metric types.Metric) string {
+	key := aws.ToString(metric.Namespace) + "/" + aws.ToString(metric.MetricName)
+	for _, dimension := range metric.Dimensions {
+		key += "/" + *dimension.Name + "=" + *dimension.Value
+	}
+	return key
+}
@@ -0,0 +1,52 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/mcquery"
+	"github.com/tonkat-su/bot/mcuser"
+)
+
+// GiveCatTreats credits everyone online with interval's worth of minutes for
+// the slot of interval it runs in, running it again in the same slot doesn't
+// count anyone twice. queryPort is optional, see mcquery.Players. it's how
+// aws deployments without a session store score (see sessions.Tracker).
+func (svc *Service) GiveCatTreats(ctx context.Context, host string, queryPort int, interval time.Duration) error {
+	server, pong, err := mclookup.Ping(ctx, host)
+	if errors.Is(err, mclookup.ErrNoServers) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if pong.Players.Online == 0 {
+		return nil
+	}
+
+	players, _ := mcquery.Players(ctx, mcquery.QueryHostport(server.Host, queryPort), pong)
+	input := &RecordScoresInput{
+		Scores: make([]*PlayerScore, 0, len(players)),
+		Time:   time.Now().Truncate(interval),
+		Once:   true,
+	}
+	for _, p := range players {
+		// query only lists names
+		if p.Uuid == "" {
+			p.Uuid, err = mcuser.GetUuid(p.Name)
+			if err != nil {
+				log.Printf("error looking up uuid for '%s': %s", p.Name, err.Error())
+				continue
+			}
+		}
+		input.Scores = append(input.Scores, &PlayerScore{
+			PlayerId: p.Uuid,
+			Score:    int64(interval / time.Minute),
+		})
+	}
+	return svc.RecordScores(ctx, input)
+}
@@ -0,0 +1,173 @@
+package mcbedrock
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	packetUnconnectedPing byte = 0x01
+	packetUnconnectedPong byte = 0x1c
+
+	// pings without a deadline give up after this long
+	defaultTimeout = 5 * time.Second
+	// udp drops packets, so pings are resent if nothing comes back in time
+	resendInterval = time.Second
+
+	maxResponseSize = 1500
+)
+
+// raknet's "offline message" magic, sent in every unconnected packet
+var offlineMessageId = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+
+var ErrInvalidResponse = errors.New("mcbedrock: invalid response")
+
+// Status is what a bedrock server advertises in its unconnected pong
+type Status struct {
+	// MCPE for bedrock, MCEE for education edition
+	Edition    string
+	Motd       string
+	Protocol   int
+	Version    string
+	Players    int
+	MaxPlayers int
+	ServerId   string
+	// the second line of the motd, usually the world name
+	SubMotd    string
+	GameMode   string
+	GameModeId int
+	PortV4     int
+	PortV6     int
+}
+
+// Ping asks the bedrock server at hostport for its status
+func Ping(ctx context.Context, hostport string) (*Status, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := make([]byte, 0, 33)
+	request = append(request, packetUnconnectedPing)
+	request = binary.BigEndian.AppendUint64(request, uint64(time.Now().UnixMilli()))
+	request = append(request, offlineMessageId...)
+	clientGuid := make([]byte, 8)
+	_, err = rand.Read(clientGuid)
+	if err != nil {
+		return nil, err
+	}
+	request = append(request, clientGuid...)
+
+	response, err := exchange(ctx, conn, request)
+	if err != nil {
+		return nil, err
+	}
+	return parsePong(response)
+}
+
+// exchange sends request until a pong comes back
+func exchange(ctx context.Context, conn net.Conn, request []byte) ([]byte, error) {
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, maxResponseSize)
+	for {
+		_, err := conn.Write(request)
+		if err != nil {
+			return nil, err
+		}
+
+		attemptDeadline := time.Now().Add(resendInterval)
+		if attemptDeadline.After(deadline) {
+			attemptDeadline = deadline
+		}
+		err = conn.SetReadDeadline(attemptDeadline)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := conn.Read(buf)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 || buf[0] != packetUnconnectedPong {
+				continue
+			}
+			return append([]byte(nil), buf[:n]...), nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !time.Now().Before(deadline) {
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// parsePong reads an unconnected pong: id, time, server guid, magic and the
+// server id string, e.g.
+//
+//	MCPE;Dedicated Server;589;1.20.0;2;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;
+func parsePong(pong []byte) (*Status, error) {
+	const header = 1 + 8 + 8
+	if len(pong) < header+len(offlineMessageId)+2 {
+		return nil, fmt.Errorf("%w: pong is too short", ErrInvalidResponse)
+	}
+	if !bytes.Equal(pong[header:header+len(offlineMessageId)], offlineMessageId) {
+		return nil, fmt.Errorf("%w: missing offline message id", ErrInvalidResponse)
+	}
+	rest := pong[header+len(offlineMessageId):]
+	length := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+length {
+		return nil, fmt.Errorf("%w: server id is truncated", ErrInvalidResponse)
+	}
+
+	fields := strings.Split(string(rest[2:2+length]), ";")
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("%w: server id %q has too few fields", ErrInvalidResponse, rest[2:2+length])
+	}
+	field := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	number := func(i int) int {
+		n, _ := strconv.Atoi(field(i))
+		return n
+	}
+
+	return &Status{
+		Edition:    field(0),
+		Motd:       field(1),
+		Protocol:   number(2),
+		Version:    field(3),
+		Players:    number(4),
+		MaxPlayers: number(5),
+		ServerId:   field(6),
+		SubMotd:    field(7),
+		GameMode:   field(8),
+		GameModeId: number(9),
+		PortV4:     number(10),
+		PortV6:     number(11),
+	}, nil
+}
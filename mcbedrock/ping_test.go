@@ -0,0 +1,91 @@
+package mcbedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeServer answers unconnected pings like a geyser server
+func fakeServer(t *testing.T, serverId string) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if n != 33 || buf[0] != packetUnconnectedPing || !bytes.Equal(buf[9:25], offlineMessageId) {
+				continue
+			}
+			pong := []byte{packetUnconnectedPong}
+			pong = append(pong, buf[1:9]...)
+			pong = binary.BigEndian.AppendUint64(pong, 13253860892328930865)
+			pong = append(pong, offlineMessageId...)
+			pong = binary.BigEndian.AppendUint16(pong, uint16(len(serverId)))
+			pong = append(pong, serverId...)
+			conn.WriteToUDP(pong, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestPing(t *testing.T) {
+	addr := fakeServer(t, "MCPE;froggy fren's server;589;1.20.0;2;10;13253860892328930865;Geyser;Survival;1;19132;19133;")
+
+	status, err := Ping(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("error pinging: %s", err)
+	}
+
+	want := &Status{
+		Edition:    "MCPE",
+		Motd:       "froggy fren's server",
+		Protocol:   589,
+		Version:    "1.20.0",
+		Players:    2,
+		MaxPlayers: 10,
+		ServerId:   "13253860892328930865",
+		SubMotd:    "Geyser",
+		GameMode:   "Survival",
+		GameModeId: 1,
+		PortV4:     19132,
+		PortV6:     19133,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("got %+v, want %+v", status, want)
+	}
+}
+
+func TestPingShortServerId(t *testing.T) {
+	// older servers stop after the player counts
+	addr := fakeServer(t, "MCPE;old server;291;1.7.0;0;20")
+
+	status, err := Ping(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("error pinging: %s", err)
+	}
+	if status.Version != "1.7.0" || status.MaxPlayers != 20 || status.GameMode != "" {
+		t.Fatalf("got %+v, want version 1.7.0 with 20 slots and no game mode", status)
+	}
+}
+
+func TestPingInvalidResponse(t *testing.T) {
+	addr := fakeServer(t, "MCPE;not enough")
+
+	_, err := Ping(context.Background(), addr)
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidResponse)
+	}
+}
@@ -0,0 +1,45 @@
+package mclookup
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultJavaPort    = 25565
+	DefaultBedrockPort = 19132
+
+	// geyser's floodgate prefixes bedrock players' names with this by default
+	// so they can't clash with java players
+	DefaultFloodgatePrefix = "."
+)
+
+// ResolveBedrockHostPort resolves a bedrock server address, "host" or
+// "host:port". bedrock has no srv records, so the port defaults to 19132.
+func ResolveBedrockHostPort(ctx context.Context, resolver *net.Resolver, server string) ([]Server, error) {
+	host, port := server, uint16(DefaultBedrockPort)
+	if h, p, err := net.SplitHostPort(server); err == nil {
+		parsed, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		host, port = h, uint16(parsed)
+	}
+
+	_, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		if e, ok := err.(*net.DNSError); ok && e.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []Server{{Host: host, Port: port}}, nil
+}
+
+// IsFloodgatePlayer reports whether name is a bedrock player joined through
+// floodgate, e.g. ".Steve"
+func IsFloodgatePlayer(name, prefix string) bool {
+	return prefix != "" && strings.HasPrefix(name, prefix)
+}
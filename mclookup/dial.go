@@ -0,0 +1,58 @@
+package mclookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	mcpinger "github.com/Raqbit/mc-pinger"
+)
+
+// how long each of a server's targets gets to answer before the next is tried
+const TargetTimeout = 3 * time.Second
+
+var ErrNoServers = errors.New("mclookup: no servers found")
+
+// DialFirstReachable calls dial on each server in turn, giving each
+// TargetTimeout, and returns the first one it succeeds for
+func DialFirstReachable(ctx context.Context, servers []Server, dial func(context.Context, Server) error) (*Server, error) {
+	if len(servers) == 0 {
+		return nil, ErrNoServers
+	}
+
+	var errs []error
+	for _, server := range servers {
+		targetCtx, cancel := context.WithTimeout(ctx, TargetTimeout)
+		err := dial(targetCtx, server)
+		cancel()
+		if err == nil {
+			return &server, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server.String(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Ping pings the java server at host, failing over between its srv targets,
+// and returns the target that answered along with its response
+func Ping(ctx context.Context, host string) (*Server, *mcpinger.ServerInfo, error) {
+	servers, err := ResolveMinecraftHostPort(ctx, nil, host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving server host '%s': %s", host, err.Error())
+	}
+
+	var pong *mcpinger.ServerInfo
+	server, err := DialFirstReachable(ctx, servers, func(ctx context.Context, server Server) error {
+		var err error
+		pong, err = mcpinger.New(server.Host, server.Port, mcpinger.WithContext(ctx)).Ping()
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return server, pong, nil
+}
@@ -0,0 +1,74 @@
+package mclookup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDialFirstReachable(t *testing.T) {
+	servers := []Server{
+		{Host: "a.example", Port: 25565},
+		{Host: "b.example", Port: 25565},
+		{Host: "c.example", Port: 25565},
+	}
+
+	t.Run("no servers", func(t *testing.T) {
+		_, err := DialFirstReachable(context.Background(), nil, func(context.Context, Server) error {
+			t.Fatal("dialed without any servers")
+			return nil
+		})
+		if !errors.Is(err, ErrNoServers) {
+			t.Fatalf("got error %v, want %v", err, ErrNoServers)
+		}
+	})
+
+	t.Run("fails over", func(t *testing.T) {
+		var dialed []string
+		got, err := DialFirstReachable(context.Background(), servers, func(ctx context.Context, server Server) error {
+			dialed = append(dialed, server.Host)
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("%s was dialed without a timeout", server.Host)
+			}
+			if server.Host == "a.example" {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Host != "b.example" || strings.Join(dialed, ",") != "a.example,b.example" {
+			t.Fatalf("got %s after dialing %v, want b.example after a and b", got.Host, dialed)
+		}
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		_, err := DialFirstReachable(context.Background(), servers, func(ctx context.Context, server Server) error {
+			return errors.New("connection refused")
+		})
+		for _, server := range servers {
+			if err == nil || !strings.Contains(err.Error(), server.String()) {
+				t.Fatalf("got error %v, want one mentioning %s", err, server.String())
+			}
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var dialed []string
+		_, err := DialFirstReachable(ctx, servers, func(ctx context.Context, server Server) error {
+			dialed = append(dialed, server.Host)
+			cancel()
+			return ctx.Err()
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+		if len(dialed) != 1 {
+			t.Fatalf("dialed %v, want to stop after the first", dialed)
+		}
+	})
+}
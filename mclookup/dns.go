@@ -0,0 +1,177 @@
+package mclookup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// how long each nameserver gets to answer
+const dnsTimeout = 2 * time.Second
+
+var errTruncated = errors.New("mclookup: dns response truncated")
+
+// swapped out in tests
+var (
+	exchange    = exchangeDns
+	nameservers = resolvConfNameservers
+)
+
+// lookupSrvTTL asks the nameservers for name's srv records itself, since
+// net.Resolver doesn't hand back the ttls. found is false if name has none.
+func lookupSrvTTL(ctx context.Context, resolver *net.Resolver, name string) (records []*net.SRV, ttl time.Duration, found bool, err error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	question, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	msg, err := exchange(ctx, resolver, dnsmessage.Question{
+		Name:  question,
+		Type:  dnsmessage.TypeSRV,
+		Class: dnsmessage.ClassINET,
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if msg.RCode != dnsmessage.RCodeSuccess && msg.RCode != dnsmessage.RCodeNameError {
+		return nil, 0, false, fmt.Errorf("mclookup: looking up %s: %s", name, msg.RCode.String())
+	}
+	records, ttl = srvAnswers(msg)
+	return records, ttl, len(records) > 0, nil
+}
+
+// srvAnswers returns the srv records in msg's answers, and the lowest ttl of
+// any answer, cnames leading to them included
+func srvAnswers(msg *dnsmessage.Message) ([]*net.SRV, time.Duration) {
+	var records []*net.SRV
+	var ttl time.Duration
+	for i, answer := range msg.Answers {
+		answerTTL := time.Duration(answer.Header.TTL) * time.Second
+		if i == 0 || answerTTL < ttl {
+			ttl = answerTTL
+		}
+		if srv, ok := answer.Body.(*dnsmessage.SRVResource); ok {
+			records = append(records, &net.SRV{
+				Target:   srv.Target.String(),
+				Port:     srv.Port,
+				Priority: srv.Priority,
+				Weight:   srv.Weight,
+			})
+		}
+	}
+	return records, ttl
+}
+
+// exchangeDns sends question to each nameserver over udp in turn, through
+// resolver's Dial if it has one, and returns the first answer
+func exchangeDns(ctx context.Context, resolver *net.Resolver, question dnsmessage.Question) (*dnsmessage.Message, error) {
+	servers, err := nameservers()
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("mclookup: no nameservers configured")
+	}
+
+	dial := (&net.Dialer{}).DialContext
+	if resolver != nil && resolver.Dial != nil {
+		dial = resolver.Dial
+	}
+
+	var errs []error
+	for _, server := range servers {
+		serverCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+		msg, err := exchangeWith(serverCtx, dial, net.JoinHostPort(server, "53"), question)
+		cancel()
+		if err == nil {
+			return msg, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func exchangeWith(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), address string, question dnsmessage.Question) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{question},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dial(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.Write(query)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var msg dnsmessage.Message
+		err = msg.Unpack(buf[:n])
+		// anything that isn't the answer to this query is dropped, like the
+		// resolver does
+		if err != nil || !msg.Header.Response || msg.Header.ID != id || !answers(&msg, question) {
+			continue
+		}
+		if msg.Header.Truncated {
+			return nil, errTruncated
+		}
+		return &msg, nil
+	}
+}
+
+// answers reports whether msg asked question. names are compared ignoring
+// case, as some resolvers randomize it.
+func answers(msg *dnsmessage.Message, question dnsmessage.Question) bool {
+	return len(msg.Questions) == 1 &&
+		msg.Questions[0].Type == question.Type &&
+		msg.Questions[0].Class == question.Class &&
+		strings.EqualFold(msg.Questions[0].Name.String(), question.Name.String())
+}
+
+// resolvConfNameservers reads the nameservers from /etc/resolv.conf
+func resolvConfNameservers() ([]string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers, scanner.Err()
+}
@@ -0,0 +1,270 @@
+package mclookup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func srvResource(t *testing.T, name string, ttl uint32, target string, port uint16) dnsmessage.Resource {
+	t.Helper()
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.SRVResource{Priority: 10, Weight: 5, Port: port, Target: dnsmessage.MustNewName(target)},
+	}
+}
+
+func TestSrvAnswers(t *testing.T) {
+	const name = "_minecraft._tcp.mc.example."
+	tests := []struct {
+		name    string
+		answers []dnsmessage.Resource
+		want    []string
+		wantTTL time.Duration
+	}{
+		{
+			name:    "none",
+			answers: nil,
+			want:    nil,
+			wantTTL: 0,
+		},
+		{
+			name: "lowest ttl",
+			answers: []dnsmessage.Resource{
+				srvResource(t, name, 300, "a.example.", 25565),
+				srvResource(t, name, 60, "b.example.", 25566),
+			},
+			want:    []string{"a.example.", "b.example."},
+			wantTTL: time.Minute,
+		},
+		{
+			// the cname expiring expires the records it led to
+			name: "cname",
+			answers: []dnsmessage.Resource{
+				{
+					Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 30},
+					Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("_minecraft._tcp.proxy.example.")},
+				},
+				srvResource(t, "_minecraft._tcp.proxy.example.", 3600, "proxy.example.", 25565),
+			},
+			want:    []string{"proxy.example."},
+			wantTTL: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, ttl := srvAnswers(&dnsmessage.Message{Answers: tt.answers})
+			var got []string
+			for _, record := range records {
+				got = append(got, record.Target)
+			}
+			if !reflect.DeepEqual(got, tt.want) || ttl != tt.wantTTL {
+				t.Fatalf("got %v for %s, want %v for %s", got, ttl, tt.want, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestLookupMinecraftCachesForTTL(t *testing.T) {
+	clock := time.Date(2026, time.October, 18, 18, 5, 0, 0, time.UTC)
+	originalNow := now
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = originalNow })
+
+	queries := 0
+	originalExchange := exchange
+	exchange = func(ctx context.Context, resolver *net.Resolver, question dnsmessage.Question) (*dnsmessage.Message, error) {
+		queries++
+		if question.Name.String() != "_minecraft._tcp.ttl.example." || question.Type != dnsmessage.TypeSRV {
+			t.Fatalf("got question %s, want the srv records of ttl.example", question.GoString())
+		}
+		return &dnsmessage.Message{
+			Header:  dnsmessage.Header{Response: true},
+			Answers: []dnsmessage.Resource{srvResource(t, question.Name.String(), 90, "proxy.example.", 25565)},
+		}, nil
+	}
+	t.Cleanup(func() { exchange = originalExchange })
+
+	for _, step := range []struct {
+		after time.Duration
+		want  int
+	}{
+		{0, 1},
+		{89 * time.Second, 1},
+		{time.Second, 2},
+	} {
+		clock = clock.Add(step.after)
+		servers, err := ResolveMinecraftHostPort(context.Background(), nil, "ttl.example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(servers) != 1 || servers[0].String() != "proxy.example:25565" {
+			t.Fatalf("got servers %v, want proxy.example:25565", servers)
+		}
+		if queries != step.want {
+			t.Fatalf("got %d queries after %s, want %d", queries, step.after, step.want)
+		}
+	}
+}
+
+func TestExchangeDns(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	question := dnsmessage.Question{
+		Name:  dnsmessage.MustNewName("_minecraft._tcp.mc.example."),
+		Type:  dnsmessage.TypeSRV,
+		Class: dnsmessage.ClassINET,
+	}
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if query.Unpack(buf[:n]) != nil {
+			return
+		}
+		// a stray reply to some other query comes first
+		for _, id := range []uint16{query.ID + 1, query.ID} {
+			reply, _ := (&dnsmessage.Message{
+				Header: dnsmessage.Header{ID: id, Response: true},
+				// case randomized by the resolver
+				Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("_MineCraft._tcp.MC.example."), Type: question.Type, Class: question.Class}},
+				Answers:   []dnsmessage.Resource{srvResource(t, question.Name.String(), uint32(id), "mc.example.", 25565)},
+			}).Pack()
+			conn.WriteTo(reply, addr)
+		}
+	}()
+
+	originalNameservers := nameservers
+	nameservers = func() ([]string, error) { return []string{"127.0.0.1"}, nil }
+	t.Cleanup(func() { nameservers = originalNameservers })
+
+	resolver := &net.Resolver{Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != "127.0.0.1:53" {
+			t.Errorf("dialed %s, want the nameserver on port 53", address)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, conn.LocalAddr().String())
+	}}
+
+	msg, err := exchangeDns(context.Background(), resolver, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, ttl := srvAnswers(msg)
+	if len(records) != 1 || records[0].Target != "mc.example." || ttl != time.Duration(msg.ID)*time.Second {
+		t.Fatalf("got %v for %s, want the reply to the query", records, ttl)
+	}
+}
+
+// fakeNameserver answers a queries for any name with 127.0.0.1 and says
+// there's nothing else, counting the srv queries it gets
+func fakeNameserver(t *testing.T) (resolver *net.Resolver, srvQueries func() int) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var mu sync.Mutex
+	srv := 0
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) != 1 {
+				continue
+			}
+			question := query.Questions[0]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+				Questions: query.Questions,
+			}
+			switch question.Type {
+			case dnsmessage.TypeA:
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			case dnsmessage.TypeSRV:
+				mu.Lock()
+				srv++
+				mu.Unlock()
+				reply.RCode = dnsmessage.RCodeNameError
+			}
+			packed, err := reply.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+	return resolver, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return srv
+	}
+}
+
+func TestLookupMinecraftWithoutSrv(t *testing.T) {
+	originalExchange := exchange
+	t.Cleanup(func() { exchange = originalExchange })
+
+	for _, tt := range []struct {
+		name string
+		// what asking the nameservers directly comes back with
+		rcode       dnsmessage.RCode
+		err         error
+		wantQueries int
+	}{
+		{name: "nxdomain", rcode: dnsmessage.RCodeNameError},
+		{name: "no answers", rcode: dnsmessage.RCodeSuccess},
+		// the resolver might get through where the direct query didn't
+		{name: "transport error", err: errors.New("i/o timeout"), wantQueries: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange = func(ctx context.Context, resolver *net.Resolver, question dnsmessage.Question) (*dnsmessage.Message, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &dnsmessage.Message{Header: dnsmessage.Header{Response: true, RCode: tt.rcode}}, nil
+			}
+			resolver, srvQueries := fakeNameserver(t)
+
+			records, _, err := lookupMinecraftUncached(context.Background(), resolver, "a-only.example")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 1 || records[0].Target != "a-only.example" || records[0].Port != DefaultJavaPort {
+				t.Fatalf("got %v, want a-only.example on the default port", records)
+			}
+			if got := srvQueries(); got != tt.wantQueries {
+				t.Fatalf("the resolver asked for srv records %d times, want %d", got, tt.wantQueries)
+			}
+		})
+	}
+}
@@ -2,11 +2,21 @@ package mclookup
 
 import (
 	"context"
+	"log"
+	"math/rand"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// how long lookups are cached for when the records' ttls aren't known, e.g.
+// when a server has no srv records or the nameservers couldn't be asked
+// directly and net.Resolver was used instead
+const CacheTTL = 5 * time.Minute
+
 // Server is host port
 type Server struct {
 	Host string
@@ -17,32 +27,157 @@ func (srv *Server) String() string {
 	return net.JoinHostPort(srv.Host, strconv.FormatUint(uint64(srv.Port), 10))
 }
 
+type cachedLookup struct {
+	records []*net.SRV
+	expires time.Time
+}
+
+var lookupCache = struct {
+	sync.Mutex
+	entries map[string]*cachedLookup
+}{entries: make(map[string]*cachedLookup)}
+
+// ResolveMinecraftHostPort returns the servers behind a java server address,
+// in the order they should be tried
 func ResolveMinecraftHostPort(ctx context.Context, resolver *net.Resolver, server string) (servers []Server, err error) {
-	_, addrs, err := resolver.LookupSRV(ctx, "minecraft", "tcp", server)
+	records, err := lookupMinecraft(ctx, resolver, server)
 	if err != nil {
-		if e, ok := err.(*net.DNSError); ok {
-			if e.IsNotFound {
-				_, err = resolver.LookupHost(ctx, server)
-				if err != nil {
-					if e, ok := err.(*net.DNSError); ok {
-						if e.IsNotFound {
-							return nil, nil
-						}
-					}
-					return nil, err
-				}
-				return []Server{{Host: server, Port: 25565}}, nil
-			}
-		}
 		return nil, err
 	}
 
-	servers = make([]Server, len(addrs))
+	records = orderSrv(records)
+	servers = make([]Server, len(records))
 	for i := range servers {
 		servers[i] = Server{
-			Host: strings.TrimRight(addrs[i].Target, "."),
-			Port: addrs[i].Port,
+			Host: strings.TrimRight(records[i].Target, "."),
+			Port: records[i].Port,
 		}
 	}
 	return servers, nil
 }
+
+// swapped out in tests
+var now = time.Now
+
+// lookupMinecraft returns server's srv records, or a record for server on the
+// default port if it has none, caching them for the records' ttl
+func lookupMinecraft(ctx context.Context, resolver *net.Resolver, server string) ([]*net.SRV, error) {
+	lookupCache.Lock()
+	cached, ok := lookupCache.entries[server]
+	lookupCache.Unlock()
+	if ok && now().Before(cached.expires) {
+		return cached.records, nil
+	}
+
+	records, ttl, err := lookupMinecraftUncached(ctx, resolver, server)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupCache.Lock()
+	lookupCache.entries[server] = &cachedLookup{records: records, expires: now().Add(ttl)}
+	lookupCache.Unlock()
+	return records, nil
+}
+
+func lookupMinecraftUncached(ctx context.Context, resolver *net.Resolver, server string) ([]*net.SRV, time.Duration, error) {
+	records, ttl, found, err := lookupSrvTTL(ctx, resolver, "_minecraft._tcp."+server)
+	if err != nil {
+		// the nameservers couldn't be asked, the resolver might still manage
+		log.Printf("error looking up srv records for %s directly, falling back to the resolver: %s", server, err.Error())
+		records, err = lookupMinecraftResolver(ctx, resolver, server)
+		return records, CacheTTL, err
+	}
+	if found {
+		return records, ttl, nil
+	}
+
+	// the nameservers said there are none, asking again won't change that
+	records, err = lookupDefaultPort(ctx, resolver, server)
+	return records, CacheTTL, err
+}
+
+func lookupMinecraftResolver(ctx context.Context, resolver *net.Resolver, server string) ([]*net.SRV, error) {
+	_, addrs, err := resolver.LookupSRV(ctx, "minecraft", "tcp", server)
+	if err != nil {
+		if e, ok := err.(*net.DNSError); ok && e.IsNotFound {
+			return lookupDefaultPort(ctx, resolver, server)
+		}
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// lookupDefaultPort returns a record for server on the default port if it
+// resolves, for servers without srv records
+func lookupDefaultPort(ctx context.Context, resolver *net.Resolver, server string) ([]*net.SRV, error) {
+	_, err := resolver.LookupHost(ctx, server)
+	if err != nil {
+		if e, ok := err.(*net.DNSError); ok && e.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []*net.SRV{{Target: server, Port: DefaultJavaPort}}, nil
+}
+
+// orderSrv orders records the way rfc 2782 says to try them: by priority,
+// then picking at random weighted by weight within each priority
+func orderSrv(records []*net.SRV) []*net.SRV {
+	sorted := make([]*net.SRV, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	ordered := make([]*net.SRV, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start
+		for end < len(sorted) && sorted[end].Priority == sorted[start].Priority {
+			end++
+		}
+		ordered = append(ordered, orderByWeight(sorted[start:end])...)
+		start = end
+	}
+	return ordered
+}
+
+// swapped out in tests
+var intn = rand.Intn
+
+// orderByWeight repeatedly picks a record with probability proportional to
+// its weight. zero weight records go first so they still have a small chance
+// of being picked when the sum is 0, as the rfc describes.
+func orderByWeight(records []*net.SRV) []*net.SRV {
+	remaining := make([]*net.SRV, 0, len(records))
+	for _, record := range records {
+		if record.Weight == 0 {
+			remaining = append(remaining, record)
+		}
+	}
+	for _, record := range records {
+		if record.Weight != 0 {
+			remaining = append(remaining, record)
+		}
+	}
+
+	ordered := make([]*net.SRV, 0, len(records))
+	for len(remaining) > 0 {
+		total := 0
+		for _, record := range remaining {
+			total += int(record.Weight)
+		}
+		pick := intn(total + 1)
+
+		i, sum := 0, 0
+		for ; i < len(remaining)-1; i++ {
+			sum += int(remaining[i].Weight)
+			if sum >= pick {
+				break
+			}
+		}
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
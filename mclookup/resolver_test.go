@@ -0,0 +1,126 @@
+package mclookup
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// withIntn swaps intn out for the rest of the test
+func withIntn(t *testing.T, fn func(n int) int) {
+	t.Helper()
+	original := intn
+	intn = fn
+	t.Cleanup(func() { intn = original })
+}
+
+func targets(records []*net.SRV) []string {
+	names := make([]string, len(records))
+	for i, record := range records {
+		names[i] = record.Target
+	}
+	return names
+}
+
+func TestOrderSrv(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []*net.SRV
+		// what intn returns, in order
+		picks []int
+		want  []string
+	}{
+		{
+			name: "priority",
+			records: []*net.SRV{
+				{Target: "backup", Priority: 20},
+				{Target: "primary", Priority: 10},
+				{Target: "secondary", Priority: 10},
+			},
+			picks: []int{0, 0, 0},
+			want:  []string{"primary", "secondary", "backup"},
+		},
+		{
+			// every pick is out of 1, so the order is kept
+			name: "zero weights only",
+			records: []*net.SRV{
+				{Target: "a"},
+				{Target: "b"},
+				{Target: "c"},
+			},
+			picks: []int{0, 0, 0},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name: "zero weight goes first on a zero pick",
+			records: []*net.SRV{
+				{Target: "weighted", Weight: 5},
+				{Target: "zero"},
+			},
+			picks: []int{0, 0},
+			want:  []string{"zero", "weighted"},
+		},
+		{
+			// sums are 1 and 4, the pick lands on the first sum it doesn't exceed
+			name: "weighted",
+			records: []*net.SRV{
+				{Target: "light", Weight: 1},
+				{Target: "heavy", Weight: 3},
+			},
+			picks: []int{2, 0},
+			want:  []string{"heavy", "light"},
+		},
+		{
+			name: "weighted light",
+			records: []*net.SRV{
+				{Target: "light", Weight: 1},
+				{Target: "heavy", Weight: 3},
+			},
+			picks: []int{1, 3},
+			want:  []string{"light", "heavy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picks := tt.picks
+			withIntn(t, func(n int) int {
+				if len(picks) == 0 {
+					t.Fatal("picked more times than expected")
+				}
+				pick := picks[0]
+				picks = picks[1:]
+				if pick >= n {
+					t.Fatalf("pick %d is out of range for intn(%d)", pick, n)
+				}
+				return pick
+			})
+
+			got := targets(orderSrv(tt.records))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderByWeightDistribution(t *testing.T) {
+	withIntn(t, rand.New(rand.NewSource(1)).Intn)
+
+	records := []*net.SRV{
+		{Target: "light", Weight: 1},
+		{Target: "heavy", Weight: 9},
+	}
+	const runs = 10000
+	heavyFirst := 0
+	for i := 0; i < runs; i++ {
+		if orderByWeight(records)[0].Target == "heavy" {
+			heavyFirst++
+		}
+	}
+	// heavy wins 9 of the 11 picks in 0 through 10
+	if heavyFirst < runs*75/100 || heavyFirst > runs*88/100 {
+		t.Fatalf("heavy went first %d times out of %d, want about 82%%", heavyFirst, runs)
+	}
+}
@@ -0,0 +1,74 @@
+package mcquery
+
+import (
+	"context"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mcpinger "github.com/Raqbit/mc-pinger"
+	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/mcuser"
+)
+
+var playerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+
+// Player is someone online, Uuid is only known for players in the ping sample
+type Player struct {
+	Name string
+	Uuid string
+}
+
+// IsPlayerName reports whether name is a player's rather than text some
+// servers fill the sample with, like "...and 5 more". bedrock players joined
+// through floodgate have floodgatePrefix in front of their names.
+func IsPlayerName(name, floodgatePrefix string) bool {
+	if mclookup.IsFloodgatePlayer(name, floodgatePrefix) {
+		name = strings.TrimPrefix(name, floodgatePrefix)
+	}
+	return playerNamePattern.MatchString(name)
+}
+
+// QueryHostport is where to query host, or empty if port is 0 because the
+// server doesn't have query enabled
+func QueryHostport(host string, port int) string {
+	if port == 0 {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// Players returns who's online. the server is queried if queryHostport is
+// set, since the ping only samples up to 12 players and some servers hide
+// them, falling back to the sample in pong if that fails. complete reports
+// whether everyone online was listed.
+func Players(ctx context.Context, queryHostport string, pong *mcpinger.ServerInfo) ([]*Player, bool) {
+	uuids := make(map[string]string, len(pong.Players.Sample))
+	for _, p := range pong.Players.Sample {
+		uuids[strings.ToLower(p.Name)] = p.ID
+		// spares looking them up to show the leaderboard, and suggests them
+		// in autocomplete
+		mcuser.Observe(p.ID, p.Name)
+	}
+
+	if queryHostport != "" {
+		stat, err := Query(ctx, queryHostport)
+		if err == nil {
+			players := make([]*Player, len(stat.Players))
+			for i, name := range stat.Players {
+				players[i] = &Player{Name: name, Uuid: uuids[strings.ToLower(name)]}
+				mcuser.Seen(name)
+			}
+			return players, true
+		}
+		log.Printf("error querying server '%s', falling back to the ping sample: %s", queryHostport, err.Error())
+	}
+
+	players := make([]*Player, len(pong.Players.Sample))
+	for i, p := range pong.Players.Sample {
+		players[i] = &Player{Name: p.Name, Uuid: p.ID}
+	}
+	return players, int(pong.Players.Online) <= len(players)
+}
@@ -0,0 +1,23 @@
+package mcquery
+
+import "testing"
+
+func TestIsPlayerName(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		prefix string
+		want   bool
+	}{
+		{"bsdlp", ".", true},
+		{".jcmp", ".", true},
+		{"*jcmp", "*", true},
+		{".jcmp", "", false},
+		{"...and 5 more", ".", false},
+		{"", ".", false},
+		{"seventeen_letters", ".", false},
+	} {
+		if got := IsPlayerName(tt.name, tt.prefix); got != tt.want {
+			t.Errorf("IsPlayerName(%q, %q) = %t, want %t", tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
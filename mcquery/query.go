@@ -0,0 +1,225 @@
+package mcquery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	packetTypeStat      byte = 0
+	packetTypeHandshake byte = 9
+
+	// queries without a deadline give up after this long
+	defaultTimeout = 5 * time.Second
+	// udp drops packets, so requests are resent if nothing comes back in time
+	resendInterval = time.Second
+
+	// big enough for the player list of any server that answers queries
+	maxResponseSize = 64 * 1024
+)
+
+var (
+	requestMagic = []byte{0xfe, 0xfd}
+
+	// full stat responses pad the key values and player list with these
+	statPadding   = []byte("splitnum\x00\x80\x00")
+	playerPadding = []byte("\x01player_\x00\x00")
+)
+
+var ErrInvalidResponse = errors.New("mcquery: invalid response")
+
+// FullStat is everything a server reports about itself over query
+type FullStat struct {
+	Motd     string
+	GameType string
+	GameId   string
+	Version  string
+	// Software is the server implementation from the plugins field, e.g.
+	// "Paper on Bukkit 1.20.1-R0.1-SNAPSHOT", empty for vanilla
+	Software   string
+	Plugins    []string
+	Map        string
+	NumPlayers int
+	MaxPlayers int
+	HostPort   int
+	HostIp     string
+	// every player online, not just a sample
+	Players []string
+}
+
+// Query asks the server at hostport for its full stat. the server needs
+// enable-query=true in server.properties, hostport is its query.port.
+func Query(ctx context.Context, hostport string) (*FullStat, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sessionId, err := newSessionId()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := exchange(ctx, conn, sessionId, request(packetTypeHandshake, sessionId, nil))
+	if err != nil {
+		return nil, err
+	}
+	token, err := parseChallengeToken(response)
+	if err != nil {
+		return nil, err
+	}
+
+	// the trailing padding asks for the full stat rather than the basic one
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint32(payload, uint32(token))
+	response, err = exchange(ctx, conn, sessionId, request(packetTypeStat, sessionId, payload))
+	if err != nil {
+		return nil, err
+	}
+	return parseFullStat(response)
+}
+
+func newSessionId() (int32, error) {
+	buf := make([]byte, 4)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	// the server only looks at the lower 4 bits of each byte
+	return int32(binary.BigEndian.Uint32(buf) & 0x0f0f0f0f), nil
+}
+
+func request(packetType byte, sessionId int32, payload []byte) []byte {
+	buf := make([]byte, 0, 7+len(payload))
+	buf = append(buf, requestMagic...)
+	buf = append(buf, packetType)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(sessionId))
+	return append(buf, payload...)
+}
+
+// exchange sends req until a response for the session comes back and
+// returns the response without its header
+func exchange(ctx context.Context, conn net.Conn, sessionId int32, req []byte) ([]byte, error) {
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, maxResponseSize)
+	for {
+		_, err := conn.Write(req)
+		if err != nil {
+			return nil, err
+		}
+
+		attemptDeadline := time.Now().Add(resendInterval)
+		if attemptDeadline.After(deadline) {
+			attemptDeadline = deadline
+		}
+		err = conn.SetReadDeadline(attemptDeadline)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := conn.Read(buf)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			// a late response to an earlier attempt is just as good
+			if n < 5 || buf[0] != req[2] || int32(binary.BigEndian.Uint32(buf[1:5])) != sessionId {
+				continue
+			}
+			return append([]byte(nil), buf[5:n]...), nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !time.Now().Before(deadline) {
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+func parseChallengeToken(response []byte) (int32, error) {
+	token, err := strconv.ParseInt(string(bytes.TrimRight(response, "\x00")), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: challenge token %q", ErrInvalidResponse, response)
+	}
+	return int32(token), nil
+}
+
+func parseFullStat(response []byte) (*FullStat, error) {
+	if !bytes.HasPrefix(response, statPadding) {
+		return nil, fmt.Errorf("%w: missing full stat padding", ErrInvalidResponse)
+	}
+	response = response[len(statPadding):]
+
+	kv, players, found := bytes.Cut(response, append([]byte{0}, playerPadding...))
+	if !found {
+		return nil, fmt.Errorf("%w: missing player list", ErrInvalidResponse)
+	}
+
+	values := make(map[string]string)
+	fields := strings.Split(string(kv), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "" {
+			break
+		}
+		values[fields[i]] = fields[i+1]
+	}
+
+	stat := &FullStat{
+		Motd:     values["hostname"],
+		GameType: values["gametype"],
+		GameId:   values["game_id"],
+		Version:  values["version"],
+		Map:      values["map"],
+		HostIp:   values["hostip"],
+		Players:  []string{},
+	}
+	stat.Software, stat.Plugins = parsePlugins(values["plugins"])
+	stat.NumPlayers, _ = strconv.Atoi(values["numplayers"])
+	stat.MaxPlayers, _ = strconv.Atoi(values["maxplayers"])
+	stat.HostPort, _ = strconv.Atoi(values["hostport"])
+
+	for _, name := range strings.Split(string(players), "\x00") {
+		if name == "" {
+			break
+		}
+		stat.Players = append(stat.Players, name)
+	}
+	return stat, nil
+}
+
+// parsePlugins splits e.g. "Paper on Bukkit 1.20.1: WorldEdit 7.2.15; Essentials 2.20.0"
+// into the server software and its plugins
+func parsePlugins(plugins string) (string, []string) {
+	software, list, _ := strings.Cut(plugins, ": ")
+	if list == "" {
+		return strings.TrimSpace(software), []string{}
+	}
+	parsed := []string{}
+	for _, plugin := range strings.Split(list, "; ") {
+		if plugin = strings.TrimSpace(plugin); plugin != "" {
+			parsed = append(parsed, plugin)
+		}
+	}
+	return strings.TrimSpace(software), parsed
+}
@@ -0,0 +1,189 @@
+package mcquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers queries like a paper server with query enabled
+type fakeServer struct {
+	conn  *net.UDPConn
+	token int32
+	stat  []byte
+
+	mu sync.Mutex
+	// drop is how many more requests to ignore, to exercise resending
+	drop int
+}
+
+func newFakeServer(t *testing.T, stat []byte) *fakeServer {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	server := &fakeServer{conn: conn, token: 9513307, stat: stat}
+	go server.serve()
+	t.Cleanup(func() { conn.Close() })
+	return server
+}
+
+func (f *fakeServer) dropRequests(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drop = n
+}
+
+func (f *fakeServer) addr() string {
+	return f.conn.LocalAddr().String()
+}
+
+func (f *fakeServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := f.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		request := buf[:n]
+		if n < 7 || !bytes.Equal(request[:2], requestMagic) {
+			continue
+		}
+
+		f.mu.Lock()
+		drop := f.drop > 0
+		if drop {
+			f.drop--
+		}
+		f.mu.Unlock()
+		if drop {
+			continue
+		}
+
+		response := append([]byte{request[2]}, request[3:7]...)
+		switch request[2] {
+		case packetTypeHandshake:
+			response = append(response, strconv.Itoa(int(f.token))+"\x00"...)
+		case packetTypeStat:
+			if n != 15 || int32(binary.BigEndian.Uint32(request[7:11])) != f.token {
+				continue
+			}
+			response = append(response, f.stat...)
+		}
+		f.conn.WriteToUDP(response, addr)
+	}
+}
+
+func fullStat(values [][2]string, players ...string) []byte {
+	var buf bytes.Buffer
+	buf.Write(statPadding)
+	for _, kv := range values {
+		buf.WriteString(kv[0] + "\x00" + kv[1] + "\x00")
+	}
+	buf.WriteString("\x00")
+	buf.Write(playerPadding)
+	for _, player := range players {
+		buf.WriteString(player + "\x00")
+	}
+	buf.WriteString("\x00")
+	return buf.Bytes()
+}
+
+var paperStat = fullStat([][2]string{
+	{"hostname", "froggy fren's server"},
+	{"gametype", "SMP"},
+	{"game_id", "MINECRAFT"},
+	{"version", "1.20.1"},
+	{"plugins", "Paper on Bukkit 1.20.1-R0.1-SNAPSHOT: WorldEdit 7.2.15; Essentials 2.20.0"},
+	{"map", "world"},
+	{"numplayers", "14"},
+	{"maxplayers", "20"},
+	{"hostport", "25565"},
+	{"hostip", "0.0.0.0"},
+}, "bsdlp", "jcmp", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l")
+
+func TestQuery(t *testing.T) {
+	server := newFakeServer(t, paperStat)
+
+	stat, err := Query(context.Background(), server.addr())
+	if err != nil {
+		t.Fatalf("error querying: %s", err)
+	}
+
+	want := &FullStat{
+		Motd:       "froggy fren's server",
+		GameType:   "SMP",
+		GameId:     "MINECRAFT",
+		Version:    "1.20.1",
+		Software:   "Paper on Bukkit 1.20.1-R0.1-SNAPSHOT",
+		Plugins:    []string{"WorldEdit 7.2.15", "Essentials 2.20.0"},
+		Map:        "world",
+		NumPlayers: 14,
+		MaxPlayers: 20,
+		HostPort:   25565,
+		HostIp:     "0.0.0.0",
+		Players:    []string{"bsdlp", "jcmp", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"},
+	}
+	if !reflect.DeepEqual(stat, want) {
+		t.Fatalf("got %+v, want %+v", stat, want)
+	}
+}
+
+func TestQueryVanillaEmpty(t *testing.T) {
+	server := newFakeServer(t, fullStat([][2]string{
+		{"hostname", "A Minecraft Server"},
+		{"plugins", ""},
+		{"numplayers", "0"},
+		{"maxplayers", "20"},
+	}))
+
+	stat, err := Query(context.Background(), server.addr())
+	if err != nil {
+		t.Fatalf("error querying: %s", err)
+	}
+	if stat.Software != "" || len(stat.Plugins) != 0 || len(stat.Players) != 0 {
+		t.Fatalf("got %+v, want no software, plugins or players", stat)
+	}
+}
+
+func TestQueryResends(t *testing.T) {
+	server := newFakeServer(t, paperStat)
+	server.dropRequests(1)
+
+	stat, err := Query(context.Background(), server.addr())
+	if err != nil {
+		t.Fatalf("error querying: %s", err)
+	}
+	if len(stat.Players) != 14 {
+		t.Fatalf("got %d players, want 14", len(stat.Players))
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	server := newFakeServer(t, paperStat)
+	server.dropRequests(1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err := Query(ctx, server.addr())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueryInvalidResponse(t *testing.T) {
+	server := newFakeServer(t, []byte("not a full stat"))
+
+	_, err := Query(context.Background(), server.addr())
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidResponse)
+	}
+}
@@ -10,18 +10,23 @@ import (
 
 var ErrAvatarServiceDown = errors.New("minotar.net is down")
 
-func GetFace(name string) ([]byte, error) {
-	if name == "" {
-		return nil, errors.New("name is required")
-	}
-
+// FaceUrl is where GetFace fetches a player's face from, for places that
+// want a url rather than the image like webhook avatars
+func FaceUrl(name string) string {
 	u := &url.URL{
 		Scheme: "https",
 		Host:   "minotar.net",
 		Path:   path.Join("helm", name, "128.png"),
 	}
+	return u.String()
+}
+
+func GetFace(name string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
 
-	response, err := http.Get(u.String())
+	response, err := http.Get(FaceUrl(name))
 	if err != nil {
 		return nil, err
 	}
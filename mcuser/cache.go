@@ -0,0 +1,326 @@
+package mcuser
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonkat-su/bot/filestore"
+)
+
+const (
+	// names can only change every 30 days, a day old name is close enough
+	DefaultCacheTtl = 24 * time.Hour
+	// nobody found is remembered for less time, the name could be taken
+	notFoundTtl = time.Hour
+	// how many players Usernames looks up at once
+	maxConcurrentLookups = 4
+	// how long GetUuid and GetUsername wait for playerdb
+	lookupTimeout = 10 * time.Second
+)
+
+// servers fill the ping sample with placeholders like "...and 5 more" under
+// the nil uuid
+const nilUuid = "00000000-0000-0000-0000-000000000000"
+
+// Names is the cache GetUuid, GetUsername, GetUsernames and Observe go through.
+// it's only kept in memory until it's replaced with one that has a path.
+var Names = NewCache(DefaultCacheTtl, "")
+
+type cacheEntry struct {
+	Uuid    string
+	Name    string
+	Updated time.Time
+	// Seen is when the player was last online, zero if they were only looked up
+	Seen time.Time `json:",omitempty"`
+}
+
+// Cache remembers which names go with which uuids for ttl, from playerdb or
+// from players seen online. stale names are still used if playerdb is down.
+type Cache struct {
+	ttl time.Duration
+	// file is nil if the cache is only kept in memory
+	file   *filestore.File
+	saveMu sync.Mutex
+
+	mu       sync.Mutex
+	byUuid   map[string]*cacheEntry
+	byName   map[string]*cacheEntry
+	notFound map[string]time.Time
+
+	// swapped out in tests
+	lookup func(ctx context.Context, identifier string) (*playerDBResponse, error)
+	now    func() time.Time
+}
+
+// NewCache keeps names for ttl, in path too if it's set so they survive
+// restarts
+func NewCache(ttl time.Duration, path string) *Cache {
+	c := &Cache{
+		ttl:      ttl,
+		byUuid:   make(map[string]*cacheEntry),
+		byName:   make(map[string]*cacheEntry),
+		notFound: make(map[string]time.Time),
+		lookup:   queryPlayerDb,
+		now:      time.Now,
+	}
+	if path == "" {
+		return c
+	}
+
+	c.file = filestore.New(path)
+	var entries []*cacheEntry
+	err := c.file.Load(&entries)
+	if err != nil {
+		log.Printf("error loading username cache, starting over: %s", err.Error())
+	}
+	for _, entry := range entries {
+		c.put(entry)
+	}
+	return c
+}
+
+func uuidKey(uuid string) string {
+	return strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// ShortUuid is the first 8 hex digits of uuid, for showing players whose name
+// couldn't be looked up
+func ShortUuid(uuid string) string {
+	key := uuidKey(uuid)
+	if len(key) > 8 {
+		key = key[:8]
+	}
+	return key
+}
+
+func (c *Cache) fresh(entry *cacheEntry) bool {
+	return c.now().Sub(entry.Updated) < c.ttl
+}
+
+// put must be called with mu held, or before the cache is shared
+func (c *Cache) put(entry *cacheEntry) {
+	if old, ok := c.byUuid[uuidKey(entry.Uuid)]; ok && c.byName[nameKey(old.Name)] == old {
+		delete(c.byName, nameKey(old.Name))
+	}
+	c.byUuid[uuidKey(entry.Uuid)] = entry
+	c.byName[nameKey(entry.Name)] = entry
+	delete(c.notFound, uuidKey(entry.Uuid))
+	delete(c.notFound, nameKey(entry.Name))
+}
+
+// Observe records a name and uuid seen together, such as in a server's ping
+// sample, so they don't have to be looked up
+func Observe(uuid, name string) {
+	Names.Observe(uuid, name)
+}
+
+func (c *Cache) Observe(uuid, name string) {
+	if uuid == "" || name == "" || uuid == nilUuid {
+		return
+	}
+
+	c.mu.Lock()
+	old, ok := c.byUuid[uuidKey(uuid)]
+	// only new names are worth writing out, not every ping
+	changed := !ok || old.Name != name || !c.fresh(old)
+	c.put(&cacheEntry{Uuid: uuid, Name: name, Updated: c.now(), Seen: c.now()})
+	c.mu.Unlock()
+
+	if changed {
+		c.save()
+	}
+}
+
+// Seen records that a player was online without their uuid, such as in a
+// query's player list. it's only remembered if their uuid is already known.
+func Seen(name string) {
+	Names.Seen(name)
+}
+
+func (c *Cache) Seen(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.byName[nameKey(name)]
+	if !ok {
+		return
+	}
+	seen := *entry
+	seen.Seen = c.now()
+	c.put(&seen)
+}
+
+// RecentlySeen returns the names of players online within the last within,
+// see Observe and Seen
+func RecentlySeen(within time.Duration) []string {
+	return Names.RecentlySeen(within)
+}
+
+func (c *Cache) RecentlySeen(within time.Duration) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := []string{}
+	for _, entry := range c.byUuid {
+		if !entry.Seen.IsZero() && c.now().Sub(entry.Seen) < within {
+			names = append(names, entry.Name)
+		}
+	}
+	return names
+}
+
+func GetUuid(name string) (string, error) {
+	return Names.Uuid(name)
+}
+
+func (c *Cache) Uuid(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	entry, err := c.get(ctx, c.byName, nameKey(name), name)
+	if err != nil {
+		return "", err
+	}
+	return entry.Uuid, nil
+}
+
+func GetUsername(id string) (string, error) {
+	return Names.Username(id)
+}
+
+func (c *Cache) Username(uuid string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	return c.username(ctx, uuid)
+}
+
+func (c *Cache) username(ctx context.Context, uuid string) (string, error) {
+	entry, err := c.get(ctx, c.byUuid, uuidKey(uuid), uuid)
+	if err != nil {
+		return "", err
+	}
+	return entry.Name, nil
+}
+
+// GetUsernames looks up the names of uuids, see Cache.Usernames
+func GetUsernames(ctx context.Context, uuids []string) map[string]string {
+	return Names.Usernames(ctx, uuids)
+}
+
+// Usernames looks up the names of uuids, at most maxConcurrentLookups at a
+// time. uuids that couldn't be looked up, or weren't before ctx is done, are
+// left out.
+func (c *Cache) Usernames(ctx context.Context, uuids []string) map[string]string {
+	names := make(map[string]string, len(uuids))
+	var missing []string
+	queued := make(map[string]bool)
+	c.mu.Lock()
+	for _, uuid := range uuids {
+		if entry, ok := c.byUuid[uuidKey(uuid)]; ok && c.fresh(entry) {
+			names[uuid] = entry.Name
+		} else if !queued[uuid] {
+			queued[uuid] = true
+			missing = append(missing, uuid)
+		}
+	}
+	c.mu.Unlock()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxConcurrentLookups)
+	)
+queue:
+	for _, uuid := range missing {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// the lookups already running give up with ctx too
+			break queue
+		}
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			name, err := c.username(ctx, uuid)
+			if err != nil {
+				log.Printf("error looking up username for '%s': %s", uuid, err.Error())
+				return
+			}
+			mu.Lock()
+			names[uuid] = name
+			mu.Unlock()
+		}(uuid)
+	}
+	wg.Wait()
+	return names
+}
+
+// get returns the entry under key in index, asking playerdb for identifier if
+// it's missing or stale
+func (c *Cache) get(ctx context.Context, index map[string]*cacheEntry, key, identifier string) (*cacheEntry, error) {
+	c.mu.Lock()
+	entry, ok := index[key]
+	if ok && c.fresh(entry) {
+		c.mu.Unlock()
+		return entry, nil
+	}
+	if at, missing := c.notFound[key]; missing && c.now().Sub(at) < notFoundTtl {
+		c.mu.Unlock()
+		return nil, ErrPlayerNotFound
+	}
+	c.mu.Unlock()
+
+	data, err := c.lookup(ctx, identifier)
+	if err != nil {
+		if ok {
+			log.Printf("error refreshing '%s', using the cached name: %s", identifier, err.Error())
+			return entry, nil
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if data == nil {
+		c.notFound[key] = c.now()
+		c.mu.Unlock()
+		return nil, ErrPlayerNotFound
+	}
+	entry = &cacheEntry{
+		Uuid:    data.Data.Player.ID,
+		Name:    data.Data.Player.Username,
+		Updated: c.now(),
+	}
+	c.put(entry)
+	c.mu.Unlock()
+
+	c.save()
+	return entry, nil
+}
+
+func (c *Cache) save() {
+	if c.file == nil {
+		return
+	}
+
+	// so an older snapshot is never written over a newer one
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	entries := make([]*cacheEntry, 0, len(c.byUuid))
+	for _, entry := range c.byUuid {
+		entries = append(entries, entry)
+	}
+	c.mu.Unlock()
+
+	err := c.file.Save(entries)
+	if err != nil {
+		log.Printf("error saving username cache: %s", err.Error())
+	}
+}
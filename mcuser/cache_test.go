@@ -0,0 +1,220 @@
+package mcuser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	bsdlp = "a7ec7d80-a1f4-4d2c-9b6b-4ffb0e1f2f8d"
+	jcmp  = "3c2a3c71-53f4-4a4c-8fc5-2b2cbd6b6d3e"
+)
+
+// fakePlayerDb answers for bsdlp and jcmp, by name or uuid
+type fakePlayerDb struct {
+	mu      sync.Mutex
+	err     error
+	delay   time.Duration
+	lookups int
+	running int32
+	most    int32
+}
+
+func (db *fakePlayerDb) lookup(ctx context.Context, identifier string) (*playerDBResponse, error) {
+	running := atomic.AddInt32(&db.running, 1)
+	defer atomic.AddInt32(&db.running, -1)
+	select {
+	case <-time.After(db.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.lookups++
+	if running > db.most {
+		db.most = running
+	}
+	if db.err != nil {
+		return nil, db.err
+	}
+
+	players := map[string][2]string{
+		"bsdlp": {bsdlp, "bsdlp"},
+		bsdlp:   {bsdlp, "bsdlp"},
+		"jcmp":  {jcmp, "jcmp"},
+		jcmp:    {jcmp, "jcmp"},
+	}
+	player, ok := players[identifier]
+	if !ok {
+		return nil, nil
+	}
+	data := &playerDBResponse{}
+	data.Data.Player.ID = player[0]
+	data.Data.Player.Username = player[1]
+	return data, nil
+}
+
+func newTestCache(path string) (*Cache, *fakePlayerDb, *time.Time) {
+	db := &fakePlayerDb{delay: 10 * time.Millisecond}
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCache(time.Hour, path)
+	c.lookup = db.lookup
+	c.now = func() time.Time { return now }
+	return c, db, &now
+}
+
+func TestCacheLookups(t *testing.T) {
+	c, db, now := newTestCache("")
+
+	for i := 0; i < 2; i++ {
+		name, err := c.Username(bsdlp)
+		if err != nil || name != "bsdlp" {
+			t.Fatalf("got %q, %v, want bsdlp", name, err)
+		}
+	}
+	// the name was learned along with the uuid
+	uuid, err := c.Uuid("BSDLP")
+	if err != nil || uuid != bsdlp {
+		t.Fatalf("got %q, %v, want %s", uuid, err, bsdlp)
+	}
+	if db.lookups != 1 {
+		t.Fatalf("got %d lookups, want 1", db.lookups)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Uuid("nobody")
+		if !errors.Is(err, ErrPlayerNotFound) {
+			t.Fatalf("got %v, want ErrPlayerNotFound", err)
+		}
+	}
+	if db.lookups != 2 {
+		t.Fatalf("got %d lookups, want not found to be remembered", db.lookups)
+	}
+
+	// playerdb being down doesn't lose names we already had
+	*now = now.Add(2 * time.Hour)
+	db.err = errors.New("down")
+	name, err := c.Username(bsdlp)
+	if err != nil || name != "bsdlp" {
+		t.Fatalf("got %q, %v, want the stale name", name, err)
+	}
+}
+
+func TestCacheObserve(t *testing.T) {
+	c, db, _ := newTestCache("")
+
+	c.Observe(bsdlp, "bsdlp")
+	c.Observe(nilUuid, "...and 5 more")
+	names := c.Usernames(context.Background(), []string{bsdlp})
+	if names[bsdlp] != "bsdlp" || db.lookups != 0 {
+		t.Fatalf("got %v after %d lookups, want bsdlp without any", names, db.lookups)
+	}
+
+	// renamed
+	c.Observe(bsdlp, "bsdlp2")
+	if _, err := c.Uuid("bsdlp2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Uuid("bsdlp"); err != nil {
+		t.Fatal(err)
+	}
+	if db.lookups != 1 {
+		t.Fatalf("got %d lookups, want the old name looked up again", db.lookups)
+	}
+}
+
+func TestCacheRecentlySeen(t *testing.T) {
+	c, _, now := newTestCache("")
+
+	c.Observe(bsdlp, "bsdlp")
+	// looked up but never online
+	if _, err := c.Uuid("jcmp"); err != nil {
+		t.Fatal(err)
+	}
+	// online without a uuid, only known once looked up
+	c.Seen("nobody")
+	*now = now.Add(2 * time.Hour)
+	c.Seen("jcmp")
+
+	got := c.RecentlySeen(time.Hour)
+	if len(got) != 1 || got[0] != "jcmp" {
+		t.Fatalf("got %v, want jcmp", got)
+	}
+	got = c.RecentlySeen(3 * time.Hour)
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "bsdlp" || got[1] != "jcmp" {
+		t.Fatalf("got %v, want bsdlp and jcmp", got)
+	}
+}
+
+func TestCacheUsernames(t *testing.T) {
+	c, db, _ := newTestCache("")
+
+	uuids := []string{bsdlp, jcmp, "00000000-0000-0000-0000-00000000000a"}
+	for i := 0; i < 8; i++ {
+		uuids = append(uuids, bsdlp)
+	}
+	names := c.Usernames(context.Background(), uuids)
+	if len(names) != 2 || names[bsdlp] != "bsdlp" || names[jcmp] != "jcmp" {
+		t.Fatalf("got %v, want bsdlp and jcmp", names)
+	}
+	if db.lookups != 3 {
+		t.Fatalf("got %d lookups, want one per uuid", db.lookups)
+	}
+	if db.most > maxConcurrentLookups {
+		t.Fatalf("got %d lookups at once, want at most %d", db.most, maxConcurrentLookups)
+	}
+}
+
+func TestCacheUsernamesCancelled(t *testing.T) {
+	c, db, _ := newTestCache("")
+	c.Observe(jcmp, "jcmp")
+	// playerdb never answers
+	db.delay = time.Hour
+
+	uuids := []string{jcmp}
+	for i := 0; i < 2*maxConcurrentLookups; i++ {
+		uuids = append(uuids, fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	names := c.Usernames(ctx, uuids)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("took %s, want to give up with ctx", elapsed)
+	}
+	if len(names) != 1 || names[jcmp] != "jcmp" {
+		t.Fatalf("got %v, want only the cached jcmp", names)
+	}
+	// nothing still running writes to what was returned
+	if running := atomic.LoadInt32(&db.running); running != 0 {
+		t.Fatalf("%d lookups still running", running)
+	}
+}
+
+func TestCachePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usernames.json")
+	c, _, _ := newTestCache(path)
+	c.Observe(jcmp, "jcmp")
+
+	c, db, _ := newTestCache(path)
+	name, err := c.Username(jcmp)
+	if err != nil || name != "jcmp" || db.lookups != 0 {
+		t.Fatalf("got %q, %v after %d lookups, want jcmp without any", name, err, db.lookups)
+	}
+}
+
+func TestShortUuid(t *testing.T) {
+	if got := ShortUuid(bsdlp); got != "a7ec7d80" {
+		t.Fatalf("got %q, want a7ec7d80", got)
+	}
+}
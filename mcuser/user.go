@@ -1,12 +1,16 @@
 package mcuser
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
 	"path"
 )
 
+var ErrPlayerNotFound = errors.New("minecraft player not found")
+
 type playerDBResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
@@ -26,13 +30,17 @@ type playerDBResponse struct {
 	Success bool `json:"success"`
 }
 
-func queryPlayerDb(identifier string) (response *playerDBResponse, err error) {
+func queryPlayerDb(ctx context.Context, identifier string) (response *playerDBResponse, err error) {
 	u := &url.URL{
 		Scheme: "https",
 		Host:   "playerdb.co",
 		Path:   path.Join("api", "player", "minecraft", identifier),
 	}
-	resp, err := http.Get(u.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -49,19 +57,3 @@ func queryPlayerDb(identifier string) (response *playerDBResponse, err error) {
 	}
 	return &data, nil
 }
-
-func GetUuid(name string) (string, error) {
-	data, err := queryPlayerDb(name)
-	if err != nil {
-		return "", err
-	}
-	return data.Data.Player.ID, nil
-}
-
-func GetUsername(id string) (string, error) {
-	data, err := queryPlayerDb(id)
-	if err != nil {
-		return "", err
-	}
-	return data.Data.Player.Username, nil
-}
@@ -0,0 +1,315 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/emoji"
+	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+// discord allows at most this many embeds per message
+const maxEmbedsPerMessage = 10
+
+// discord's limits on an embed's description, and on the text of all of a
+// message's embeds together
+const (
+	maxEmbedDescription = 4096
+	maxEmbedsText       = 6000
+)
+
+const (
+	colorJoin        = 0x43b581
+	colorLeave       = 0x747f8d
+	colorDeath       = 0xf04747
+	colorAdvancement = 0xfaa61a
+)
+
+type Config struct {
+	ChannelId string
+	GuildId   string
+
+	Joins        bool
+	Leaves       bool
+	Deaths       bool
+	Advancements bool
+
+	// FloodgatePrefix marks bedrock players joined through floodgate, who
+	// have no java skin to show a face for
+	FloodgatePrefix string
+
+	// events that happen within this long of each other are posted together,
+	// and summarised if there are too many to post one by one
+	BatchWindow time.Duration
+}
+
+// Notifier posts what players get up to in game to a discord channel
+type Notifier struct {
+	session *discordgo.Session
+	cfg     *Config
+
+	// swapped out in tests
+	post func(batch []serverlog.Event)
+}
+
+func New(session *discordgo.Session, cfg *Config) *Notifier {
+	n := &Notifier{
+		session: session,
+		cfg:     cfg,
+	}
+	n.post = n.postBatch
+	return n
+}
+
+// Run posts events in batches until events is closed
+func (n *Notifier) Run(events <-chan serverlog.Event) {
+	var batch []serverlog.Event
+	var flush <-chan time.Time
+	// everyone leaves when the server stops, which isn't worth announcing
+	stopping := false
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				n.post(batch)
+				return
+			}
+
+			switch event.(type) {
+			case *serverlog.ServerStopping:
+				stopping = true
+			case *serverlog.ServerStarting, *serverlog.ServerStarted:
+				stopping = false
+			case *serverlog.Leave:
+				if stopping {
+					continue
+				}
+			}
+			if !n.enabled(event) {
+				continue
+			}
+
+			batch = append(batch, event)
+			if flush == nil {
+				flush = time.After(n.cfg.BatchWindow)
+			}
+		case <-flush:
+			n.post(batch)
+			batch = nil
+			flush = nil
+		}
+	}
+}
+
+func (n *Notifier) enabled(event serverlog.Event) bool {
+	switch event.(type) {
+	case *serverlog.Join:
+		return n.cfg.Joins
+	case *serverlog.Leave:
+		return n.cfg.Leaves
+	case *serverlog.Death:
+		return n.cfg.Deaths
+	case *serverlog.Advancement:
+		return n.cfg.Advancements
+	}
+	return false
+}
+
+func (n *Notifier) postBatch(batch []serverlog.Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	players, javaPlayers := batchPlayers(batch, n.cfg.FloodgatePrefix)
+	err := emoji.HydrateEmojiIds(n.session, n.cfg.GuildId, javaPlayers)
+	if err != nil {
+		log.Printf("error fetching player emojis: %s", err.Error())
+	}
+
+	_, err = n.session.ChannelMessageSendComplex(n.cfg.ChannelId, &discordgo.MessageSend{
+		Embeds: batchEmbeds(batch, playerFace(players, n.cfg.FloodgatePrefix)),
+	})
+	if err != nil {
+		log.Printf("error posting %d player notifications: %s", len(batch), err.Error())
+	}
+}
+
+// batchPlayers returns everyone in batch keyed by lowercased name, and the
+// java players among them whose faces can be shown as emojis
+func batchPlayers(batch []serverlog.Event, floodgatePrefix string) (map[string]*emoji.Player, []*emoji.Player) {
+	players := make(map[string]*emoji.Player)
+	javaPlayers := []*emoji.Player{}
+	for _, event := range batch {
+		name := eventPlayer(event)
+		if _, ok := players[strings.ToLower(name)]; ok {
+			continue
+		}
+		player := &emoji.Player{Name: name}
+		players[strings.ToLower(name)] = player
+		if !mclookup.IsFloodgatePlayer(name, floodgatePrefix) {
+			javaPlayers = append(javaPlayers, player)
+		}
+	}
+	return players, javaPlayers
+}
+
+// playerFace shows a player's face emoji, or the name of a bedrock player
+// who has none
+func playerFace(players map[string]*emoji.Player, floodgatePrefix string) func(string) string {
+	return func(name string) string {
+		if mclookup.IsFloodgatePlayer(name, floodgatePrefix) {
+			return "`" + name + "`"
+		}
+		return players[strings.ToLower(name)].EmojiTextCode()
+	}
+}
+
+// batchEmbeds posts each event as its own embed, or summarises them if there
+// are too many for one message
+func batchEmbeds(batch []serverlog.Event, face func(string) string) []*discordgo.MessageEmbed {
+	if len(batch) > maxEmbedsPerMessage {
+		return summaryEmbeds(batch, face)
+	}
+	embeds := make([]*discordgo.MessageEmbed, 0, len(batch))
+	for _, event := range batch {
+		embeds = append(embeds, eventEmbed(event, face))
+	}
+	return embeds
+}
+
+func eventPlayer(event serverlog.Event) string {
+	switch e := event.(type) {
+	case *serverlog.Join:
+		return e.Player
+	case *serverlog.Leave:
+		return e.Player
+	case *serverlog.Death:
+		return e.Player
+	case *serverlog.Advancement:
+		return e.Player
+	}
+	return ""
+}
+
+func eventEmbed(event serverlog.Event, face func(string) string) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Timestamp: event.At().Format(time.RFC3339),
+	}
+	switch e := event.(type) {
+	case *serverlog.Join:
+		embed.Color = colorJoin
+		embed.Description = fmt.Sprintf("%s **%s** joined the game", face(e.Player), e.Player)
+	case *serverlog.Leave:
+		embed.Color = colorLeave
+		embed.Description = fmt.Sprintf("%s **%s** left the game", face(e.Player), e.Player)
+	case *serverlog.Death:
+		embed.Color = colorDeath
+		message := strings.Replace(e.Message, e.Player, "**"+e.Player+"**", 1)
+		embed.Description = fmt.Sprintf("%s %s", face(e.Player), message)
+	case *serverlog.Advancement:
+		embed.Color = colorAdvancement
+		embed.Description = fmt.Sprintf("%s **%s** %s **[%s]**", face(e.Player), e.Player, advancementVerb(e.Kind), e.Advancement)
+	}
+	return embed
+}
+
+func advancementVerb(kind serverlog.AdvancementKind) string {
+	switch kind {
+	case serverlog.AdvancementGoal:
+		return "has reached the goal"
+	case serverlog.AdvancementChallenge:
+		return "has completed the challenge"
+	case serverlog.AdvancementAchievement:
+		return "has just earned the achievement"
+	}
+	return "has made the advancement"
+}
+
+// summaryEmbeds lists who did what in one embed per kind of event, for
+// batches too big to post one by one
+func summaryEmbeds(batch []serverlog.Event, face func(string) string) []*discordgo.MessageEmbed {
+	type summary struct {
+		title string
+		color int
+		lines []string
+	}
+	joins := &summary{title: "joined the game", color: colorJoin}
+	leaves := &summary{title: "left the game", color: colorLeave}
+	deaths := &summary{title: "deaths", color: colorDeath}
+	advancements := &summary{title: "advancements", color: colorAdvancement}
+
+	for _, event := range batch {
+		switch e := event.(type) {
+		case *serverlog.Join:
+			joins.lines = append(joins.lines, fmt.Sprintf("%s %s", face(e.Player), e.Player))
+		case *serverlog.Leave:
+			leaves.lines = append(leaves.lines, fmt.Sprintf("%s %s", face(e.Player), e.Player))
+		case *serverlog.Death:
+			deaths.lines = append(deaths.lines, fmt.Sprintf("%s %s", face(e.Player), e.Message))
+		case *serverlog.Advancement:
+			advancements.lines = append(advancements.lines, fmt.Sprintf("%s %s [%s]", face(e.Player), e.Player, e.Advancement))
+		}
+	}
+
+	var summaries []*summary
+	for _, s := range []*summary{joins, leaves, deaths, advancements} {
+		if len(s.lines) > 0 {
+			summaries = append(summaries, s)
+		}
+	}
+
+	// each embed gets an even share of what's left of the message's text,
+	// so whatever one doesn't use goes to the ones after it
+	embeds := []*discordgo.MessageEmbed{}
+	remaining := maxEmbedsText
+	for i, s := range summaries {
+		title := fmt.Sprintf("%s (%d)", s.title, len(s.lines))
+		limit := remaining/(len(summaries)-i) - len(title)
+		if limit > maxEmbedDescription {
+			limit = maxEmbedDescription
+		}
+		description := truncateLines(s.lines, limit)
+		remaining -= len(title) + len(description)
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:       title,
+			Color:       s.color,
+			Description: description,
+		})
+	}
+	return embeds
+}
+
+// truncateLines joins lines, leaving off whatever doesn't fit in limit along
+// with a note of how many were left off
+func truncateLines(lines []string, limit int) string {
+	joined := strings.Join(lines, "\n")
+	if len(joined) <= limit {
+		return joined
+	}
+
+	var kept []string
+	size := 0
+	for i, line := range lines {
+		next := size + len(line)
+		if i > 0 {
+			next++
+		}
+		// room has to be left for the note about the rest
+		if next+len(fmt.Sprintf("\n…and %d more", len(lines)-i-1)) > limit {
+			break
+		}
+		kept = append(kept, line)
+		size = next
+	}
+
+	note := fmt.Sprintf("…and %d more", len(lines)-len(kept))
+	if len(kept) == 0 {
+		return note
+	}
+	return strings.Join(kept, "\n") + "\n" + note
+}
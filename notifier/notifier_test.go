@@ -0,0 +1,239 @@
+package notifier
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var at = time.Date(2026, time.October, 18, 18, 5, 0, 0, time.UTC)
+
+func face(name string) string {
+	return ":" + name + ":"
+}
+
+// describeEmbeds lays out embeds as text, one per paragraph
+func describeEmbeds(embeds []*discordgo.MessageEmbed) string {
+	var builder strings.Builder
+	for _, embed := range embeds {
+		fmt.Fprintf(&builder, "#%06x %s %s\n%s\n\n", embed.Color, embed.Timestamp, embed.Title, embed.Description)
+	}
+	return builder.String()
+}
+
+func TestBatchEmbedsGolden(t *testing.T) {
+	batch := []serverlog.Event{
+		&serverlog.Join{Time: at, Player: "bsdlp"},
+		&serverlog.Leave{Time: at, Player: "jcmp"},
+		&serverlog.Death{Time: at, Player: "bsdlp", Message: "bsdlp was slain by Zombie"},
+		&serverlog.Advancement{Time: at, Player: "bsdlp", Kind: serverlog.AdvancementTask, Advancement: "Stone Age"},
+		&serverlog.Advancement{Time: at, Player: "jcmp", Kind: serverlog.AdvancementGoal, Advancement: "The End?"},
+		&serverlog.Advancement{Time: at, Player: "jcmp", Kind: serverlog.AdvancementChallenge, Advancement: "Monsters Hunted"},
+		&serverlog.Advancement{Time: at, Player: "jcmp", Kind: serverlog.AdvancementAchievement, Advancement: "Getting Wood"},
+	}
+	// too many to post one by one
+	var crowd []serverlog.Event
+	for i := 0; i < maxEmbedsPerMessage; i++ {
+		crowd = append(crowd, &serverlog.Join{Time: at, Player: fmt.Sprintf("player%d", i)})
+	}
+	crowd = append(crowd,
+		&serverlog.Leave{Time: at, Player: "jcmp"},
+		&serverlog.Death{Time: at, Player: "bsdlp", Message: "bsdlp fell from a high place"},
+		&serverlog.Advancement{Time: at, Player: "bsdlp", Kind: serverlog.AdvancementTask, Advancement: "Stone Age"},
+	)
+
+	for name, batch := range map[string][]serverlog.Event{"batch": batch, "summary": crowd} {
+		t.Run(name, func(t *testing.T) {
+			got := describeEmbeds(batchEmbeds(batch, face))
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				err := os.WriteFile(golden, []byte(got), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("error reading golden file, run go test with -update to create it: %s", err)
+			}
+			if got != string(want) {
+				t.Errorf("embeds don't match %s, run go test with -update if this is expected\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestBatchFloodgatePlayers(t *testing.T) {
+	batch := []serverlog.Event{
+		&serverlog.Join{Time: at, Player: "bsdlp"},
+		&serverlog.Join{Time: at, Player: ".Steve"},
+		&serverlog.Death{Time: at, Player: ".Steve", Message: ".Steve drowned"},
+	}
+	players, javaPlayers := batchPlayers(batch, ".")
+	if len(javaPlayers) != 1 || javaPlayers[0].Name != "bsdlp" {
+		t.Fatalf("got %d java players, want only bsdlp to have a face looked up", len(javaPlayers))
+	}
+
+	embeds := batchEmbeds(batch, playerFace(players, "."))
+	for i, want := range []string{
+		"`.Steve` **.Steve** joined the game",
+		"`.Steve` **.Steve** drowned",
+	} {
+		if got := embeds[i+1].Description; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSummaryEmbedsFitOneMessage(t *testing.T) {
+	var batch []serverlog.Event
+	for i := 0; i < 500; i++ {
+		player := fmt.Sprintf("player%d", i)
+		batch = append(batch,
+			&serverlog.Join{Time: at, Player: player},
+			&serverlog.Death{Time: at, Player: player, Message: player + " was shot by a Skeleton while trying to escape Zombie"},
+		)
+	}
+	batch = append(batch, &serverlog.Leave{Time: at, Player: "jcmp"})
+
+	embeds := batchEmbeds(batch, face)
+	if len(embeds) != 3 {
+		t.Fatalf("got %d embeds, want 3", len(embeds))
+	}
+	total := 0
+	for _, embed := range embeds {
+		if len(embed.Description) > maxEmbedDescription {
+			t.Errorf("%s has a %d byte description, want at most %d", embed.Title, len(embed.Description), maxEmbedDescription)
+		}
+		total += len(embed.Title) + len(embed.Description)
+	}
+	if total > maxEmbedsText {
+		t.Errorf("got %d bytes of text, want at most %d", total, maxEmbedsText)
+	}
+	// the one leave isn't crowded out
+	if embeds[1].Description != ":jcmp: jcmp" {
+		t.Errorf("got leaves %q, want jcmp", embeds[1].Description)
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		limit int
+		want  string
+	}{
+		{
+			name:  "fits",
+			lines: []string{"bsdlp", "jcmp"},
+			limit: 100,
+			want:  "bsdlp\njcmp",
+		},
+		{
+			name:  "exactly fits",
+			lines: []string{"bsdlp", "jcmp"},
+			limit: len("bsdlp\njcmp") + 1,
+			want:  "bsdlp\njcmp",
+		},
+		{
+			// room for the note about the rest has to be left
+			name:  "cut short",
+			lines: []string{"bsdlp", "jcmp", "ouroboronn", "MuchJokes"},
+			limit: 30,
+			want:  "bsdlp\njcmp\n…and 2 more",
+		},
+		{
+			name:  "nothing fits",
+			lines: []string{"ouroboronn", "bsdlp"},
+			limit: 15,
+			want:  "…and 2 more",
+		},
+		{
+			name:  "empty",
+			lines: nil,
+			limit: 10,
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateLines(tt.lines, tt.limit)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if len(got) > tt.limit {
+				t.Errorf("got %d bytes, want at most %d", len(got), tt.limit)
+			}
+		})
+	}
+}
+
+func TestRunBatches(t *testing.T) {
+	n := New(nil, &Config{Joins: true, Leaves: true, Deaths: true, BatchWindow: 100 * time.Millisecond})
+	batches := make(chan []serverlog.Event, 10)
+	n.post = func(batch []serverlog.Event) {
+		if len(batch) > 0 {
+			batches <- batch
+		}
+	}
+
+	events := make(chan serverlog.Event)
+	done := make(chan struct{})
+	go func() {
+		n.Run(events)
+		close(done)
+	}()
+
+	next := func() []serverlog.Event {
+		t.Helper()
+		select {
+		case batch := <-batches:
+			return batch
+		case <-time.After(time.Second):
+			t.Fatal("no batch was posted")
+			return nil
+		}
+	}
+
+	joinA := &serverlog.Join{Time: at, Player: "bsdlp"}
+	joinB := &serverlog.Join{Time: at, Player: "jcmp"}
+	death := &serverlog.Death{Time: at, Player: "bsdlp", Message: "bsdlp drowned"}
+	leave := &serverlog.Leave{Time: at, Player: "jcmp"}
+
+	// leaves while the server stops aren't posted, nor are turned off events
+	events <- &serverlog.ServerStopping{Time: at}
+	events <- &serverlog.Leave{Time: at, Player: "bsdlp"}
+	events <- &serverlog.ServerStarted{Time: at}
+	events <- joinA
+	events <- &serverlog.Advancement{Time: at, Player: "bsdlp", Advancement: "Stone Age"}
+	events <- &serverlog.Chat{Time: at, Player: "bsdlp", Message: "hi"}
+	events <- joinB
+	if got, want := next(), []serverlog.Event{joinA, joinB}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got batch %v, want %v", got, want)
+	}
+
+	events <- death
+	if got, want := next(), []serverlog.Event{death}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got batch %v, want %v", got, want)
+	}
+
+	// whatever is waiting is posted when the events run out
+	events <- leave
+	close(events)
+	<-done
+	if got, want := next(), []serverlog.Event{leave}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got batch %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/mcquery"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+// Poll pings the server every interval and turns changes in who's online
+// into join and leave events, for servers whose log can't be followed. the
+// channel is closed once ctx is done.
+func Poll(ctx context.Context, host string, queryPort int, floodgatePrefix string, interval time.Duration) <-chan serverlog.Event {
+	events := make(chan serverlog.Event)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// nil until the first complete sample, which everything is compared to
+		var previous map[string]string
+		for {
+			current, err := samplePlayers(ctx, host, queryPort, floodgatePrefix)
+			if err != nil {
+				log.Printf("error sampling players for notifications: %s", err.Error())
+			} else if current != nil {
+				if previous != nil {
+					for _, event := range diffSamples(previous, current, time.Now()) {
+						select {
+						case events <- event:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+				previous = current
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// samplePlayers returns who's online keyed by lowercased name, or nil if
+// the server only sent part of the list
+func samplePlayers(ctx context.Context, host string, queryPort int, floodgatePrefix string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	server, pong, err := mclookup.Ping(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// vanilla only samples 12 players, so without query anyone could have
+	// come or gone
+	online, complete := mcquery.Players(ctx, mcquery.QueryHostport(server.Host, queryPort), pong)
+	if !complete {
+		return nil, nil
+	}
+
+	players := make(map[string]string, len(online))
+	for _, p := range online {
+		if mcquery.IsPlayerName(p.Name, floodgatePrefix) {
+			players[strings.ToLower(p.Name)] = p.Name
+		}
+	}
+	return players, nil
+}
+
+// diffSamples returns a leave for everyone in previous missing from current
+// and a join for everyone new, in name order
+func diffSamples(previous, current map[string]string, at time.Time) []serverlog.Event {
+	events := []serverlog.Event{}
+	for _, key := range sortedKeys(previous) {
+		if _, ok := current[key]; !ok {
+			events = append(events, &serverlog.Leave{Time: at, Player: previous[key]})
+		}
+	}
+	for _, key := range sortedKeys(current) {
+		if _, ok := previous[key]; !ok {
+			events = append(events, &serverlog.Join{Time: at, Player: current[key]})
+		}
+	}
+	return events
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+func TestDiffSamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous map[string]string
+		current  map[string]string
+		want     []serverlog.Event
+	}{
+		{
+			name:     "nobody",
+			previous: map[string]string{},
+			current:  map[string]string{},
+			want:     []serverlog.Event{},
+		},
+		{
+			name:     "no change",
+			previous: map[string]string{"bsdlp": "bsdlp"},
+			current:  map[string]string{"bsdlp": "bsdlp"},
+			want:     []serverlog.Event{},
+		},
+		{
+			// leaves come first, each in name order
+			name:     "joins and leaves",
+			previous: map[string]string{"jcmp": "jcmp", "bsdlp": "bsdlp"},
+			current:  map[string]string{"muchjokes": "MuchJokes", "ouroboronn": "ouroboronn", "bsdlp": "bsdlp"},
+			want: []serverlog.Event{
+				&serverlog.Leave{Time: at, Player: "jcmp"},
+				&serverlog.Join{Time: at, Player: "MuchJokes"},
+				&serverlog.Join{Time: at, Player: "ouroboronn"},
+			},
+		},
+		{
+			// names are keyed lowercased, so a change in case isn't a rejoin
+			name:     "case",
+			previous: map[string]string{"bsdlp": "BSDLP"},
+			current:  map[string]string{"bsdlp": "bsdlp"},
+			want:     []serverlog.Event{},
+		},
+		{
+			name:     "everyone left",
+			previous: map[string]string{"bsdlp": "bsdlp", ".jcmp": ".jcmp"},
+			current:  map[string]string{},
+			want: []serverlog.Event{
+				&serverlog.Leave{Time: at, Player: ".jcmp"},
+				&serverlog.Leave{Time: at, Player: "bsdlp"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffSamples(tt.previous, tt.current, at)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
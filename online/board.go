@@ -0,0 +1,218 @@
+package online
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/accounts"
+	"github.com/tonkat-su/bot/filestore"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+// joins and leaves often come in bunches, and the ping sample takes a moment
+// to catch up, so refreshing waits this long after one
+const boardEventDelay = 5 * time.Second
+
+type BoardConfig struct {
+	ChannelId      string
+	GuildId        string
+	ServerHostname string
+	ServerName     string
+	QueryPort      int
+
+	BedrockHostname string
+	FloodgatePrefix string
+
+	// Links is optional, called on every refresh so new links show up
+	Links func() *accounts.Directory
+
+	// the message is refreshed this often, as well as on joins and leaves
+	Interval time.Duration
+	// where the status message's id is kept between restarts
+	StorePath string
+}
+
+// Board keeps one status message in a channel up to date, so people can
+// glance at it instead of running /online
+type Board struct {
+	session *discordgo.Session
+	cfg     *BoardConfig
+	file    *filestore.File
+
+	message *boardMessage
+	// the last embeds rendered, to skip edits that wouldn't change anything
+	rendered []byte
+}
+
+type boardMessage struct {
+	ChannelId string
+	MessageId string
+}
+
+func NewBoard(session *discordgo.Session, cfg *BoardConfig) *Board {
+	return &Board{
+		session: session,
+		cfg:     cfg,
+		file:    filestore.New(cfg.StorePath),
+	}
+}
+
+// Run refreshes the message until ctx is done. events is optional, joins and
+// leaves on it trigger a refresh.
+func (b *Board) Run(ctx context.Context, events <-chan serverlog.Event) {
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	var soon <-chan time.Time
+
+	for {
+		err := b.refresh()
+		if err != nil {
+			log.Printf("error refreshing status board: %s", err.Error())
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.checkDeleted()
+				waiting = false
+			case <-soon:
+				soon = nil
+				waiting = false
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				switch event.(type) {
+				case *serverlog.Join, *serverlog.Leave:
+					if soon == nil {
+						soon = time.After(boardEventDelay)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (b *Board) refresh() error {
+	var links *accounts.Directory
+	if b.cfg.Links != nil {
+		links = b.cfg.Links()
+	}
+	status, err := PrepareStatus(&PrepareStatusRequest{
+		Session:         b.session,
+		GuildId:         b.cfg.GuildId,
+		ServerHostname:  b.cfg.ServerHostname,
+		ServerName:      b.cfg.ServerName,
+		Links:           links,
+		QueryPort:       b.cfg.QueryPort,
+		BedrockHostname: b.cfg.BedrockHostname,
+		FloodgatePrefix: b.cfg.FloodgatePrefix,
+	})
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		return nil
+	}
+
+	rendered, err := json.Marshal(status.MessageEmbeds)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(rendered, b.rendered) {
+		return nil
+	}
+
+	err = b.update(status)
+	if err != nil {
+		return err
+	}
+	b.rendered = rendered
+	return nil
+}
+
+// checkDeleted looks the status message up, forgetting it if it was deleted
+// so the next refresh posts it again even if nothing changed
+func (b *Board) checkDeleted() {
+	if b.message == nil {
+		return
+	}
+	_, err := b.session.ChannelMessage(b.message.ChannelId, b.message.MessageId)
+	if isUnknownMessage(err) {
+		log.Printf("status board message %s was deleted, posting a new one", b.message.MessageId)
+		b.message = nil
+		b.rendered = nil
+		return
+	}
+	if err != nil {
+		log.Printf("error checking status board message: %s", err.Error())
+	}
+}
+
+// update edits the status message, posting a new one if there isn't one yet
+// or it was deleted
+func (b *Board) update(status *PrepareStatusResponse) error {
+	if b.message == nil {
+		message := &boardMessage{}
+		err := b.file.Load(message)
+		if err != nil {
+			return err
+		}
+		if message.ChannelId == b.cfg.ChannelId && message.MessageId != "" {
+			b.message = message
+		}
+	}
+
+	if b.message != nil {
+		// the favicon uploaded with the message is kept, so only the embeds change
+		_, err := b.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:      b.message.MessageId,
+			Channel: b.message.ChannelId,
+			Embeds:  status.MessageEmbeds,
+		})
+		if !isUnknownMessage(err) {
+			return err
+		}
+		log.Printf("status board message %s was deleted, posting a new one", b.message.MessageId)
+		b.message = nil
+	}
+
+	posted, err := b.session.ChannelMessageSendComplex(b.cfg.ChannelId, &discordgo.MessageSend{
+		Embeds: status.MessageEmbeds,
+		Files:  status.Files,
+	})
+	if err != nil {
+		return err
+	}
+	b.message = &boardMessage{ChannelId: posted.ChannelID, MessageId: posted.ID}
+	err = b.file.Save(b.message)
+	if err != nil {
+		log.Printf("error saving status board message id: %s", err.Error())
+	}
+
+	err = b.session.ChannelMessagePin(posted.ChannelID, posted.ID)
+	if err != nil {
+		log.Printf("error pinning status board message: %s", err.Error())
+	}
+	return nil
+}
+
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	if restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage {
+		return true
+	}
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
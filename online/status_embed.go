@@ -3,15 +3,18 @@ package online
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"mime"
 	"strings"
 
-	mcpinger "github.com/Raqbit/mc-pinger"
 	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/accounts"
 	"github.com/tonkat-su/bot/emoji"
+	"github.com/tonkat-su/bot/mcbedrock"
 	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/mcquery"
 	"github.com/vincent-petithory/dataurl"
 )
 
@@ -21,6 +24,20 @@ type PrepareStatusRequest struct {
 	GuildId        string
 	ServerHostname string
 	ServerName     string
+
+	// Links is optional, linked players are shown with a mention
+	Links *accounts.Directory
+
+	// QueryPort is optional, set it if the server has enable-query on so that
+	// everyone online is listed rather than a sample
+	QueryPort int
+
+	// BedrockHostname is optional, a bedrock server such as geyser's shown
+	// alongside, "host" or "host:port"
+	BedrockHostname string
+	// FloodgatePrefix marks bedrock players joined through floodgate, they're
+	// listed by name since there's no java skin to make a face from
+	FloodgatePrefix string
 }
 
 type PrepareStatusResponse struct {
@@ -28,13 +45,11 @@ type PrepareStatusResponse struct {
 	Files         []*discordgo.File
 }
 
+// PrepareStatus returns nil if the host has no servers to ping
 func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error) {
 	ctx := context.Background()
-	hostports, err := mclookup.ResolveMinecraftHostPort(ctx, nil, params.ServerHostname)
-	if err != nil {
-		return nil, fmt.Errorf("error resolving server host '%s': %s", params.ServerHostname, err.Error())
-	}
-	if len(hostports) == 0 {
+	server, pong, err := mclookup.Ping(ctx, params.ServerHostname)
+	if errors.Is(err, mclookup.ErrNoServers) {
 		return nil, nil
 	}
 
@@ -42,10 +57,8 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 		Title: params.ServerName,
 	}
 
-	serverUrl := hostports[0].String()
-	pong, err := mcpinger.New(hostports[0].Host, hostports[0].Port).Ping()
 	if err != nil {
-		log.Printf("error pinging server '%s': %s", serverUrl, err.Error())
+		log.Printf("error pinging server '%s': %s", params.ServerHostname, err.Error())
 		embed.Fields = []*discordgo.MessageEmbedField{
 			{
 				Name:  "error",
@@ -53,11 +66,15 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 			},
 		}
 		embed.Color = 0xf04747
+		if params.BedrockHostname != "" {
+			embed.Fields = append(embed.Fields, bedrockField(ctx, params.BedrockHostname))
+		}
 		return &PrepareStatusResponse{
 			MessageEmbeds: []*discordgo.MessageEmbed{embed},
 		}, nil
 	}
 
+	serverUrl := server.String()
 	embed.Fields = []*discordgo.MessageEmbedField{
 		{
 			Name:  "host",
@@ -65,12 +82,15 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 		},
 	}
 
-	players := make([]*emoji.Player, len(pong.Players.Sample))
-	for i, p := range pong.Players.Sample {
+	online, _ := mcquery.Players(ctx, mcquery.QueryHostport(server.Host, params.QueryPort), pong)
+	players := make([]*emoji.Player, len(online))
+	for i, p := range online {
 		players[i] = &emoji.Player{
 			Name: p.Name,
+			Uuid: p.Uuid,
 		}
 	}
+	params.Links.Annotate(players)
 
 	var playersEmbedField *discordgo.MessageEmbedField
 	if len(players) == 0 {
@@ -79,8 +99,15 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 			Value: "https://www.youtube.com/watch?v=ypVpv-fEevk",
 		}
 	} else {
+		javaPlayers := []*emoji.Player{}
+		for _, p := range players {
+			if !mclookup.IsFloodgatePlayer(p.Name, params.FloodgatePrefix) {
+				javaPlayers = append(javaPlayers, p)
+			}
+		}
+
 		// fill emoji ids for players
-		err = emoji.HydrateEmojiIds(params.Session, params.GuildId, players)
+		err = emoji.HydrateEmojiIds(params.Session, params.GuildId, javaPlayers)
 		if err != nil {
 			log.Printf("error syncing avatars to emoji: %s", err)
 		}
@@ -88,7 +115,14 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 		// format into list of face emojis of online players
 		emojis := make([]string, len(players))
 		for i, p := range players {
-			emojis[i] = p.EmojiTextCode()
+			if mclookup.IsFloodgatePlayer(p.Name, params.FloodgatePrefix) {
+				emojis[i] = "`" + p.Name + "`"
+			} else {
+				emojis[i] = p.EmojiTextCode()
+			}
+			if mention := p.Mention(); mention != "" {
+				emojis[i] += " " + mention
+			}
 		}
 		emojiString := strings.Join(emojis, " ")
 
@@ -99,6 +133,10 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 	}
 	embed.Fields = append(embed.Fields, playersEmbedField)
 
+	if params.BedrockHostname != "" {
+		embed.Fields = append(embed.Fields, bedrockField(ctx, params.BedrockHostname))
+	}
+
 	embed.Color = 0x43b581
 
 	embed.Description = pong.Description.Text
@@ -128,6 +166,33 @@ func PrepareStatus(params *PrepareStatusRequest) (*PrepareStatusResponse, error)
 	}, nil
 }
 
+// bedrockField shows how the bedrock server is doing
+func bedrockField(ctx context.Context, hostname string) *discordgo.MessageEmbedField {
+	field := &discordgo.MessageEmbedField{Name: "bedrock"}
+
+	hostports, err := mclookup.ResolveBedrockHostPort(ctx, nil, hostname)
+	if err == nil && len(hostports) == 0 {
+		err = fmt.Errorf("no servers found for '%s'", hostname)
+	}
+	if err != nil {
+		log.Printf("error resolving bedrock server host '%s': %s", hostname, err.Error())
+		field.Value = err.Error()
+		return field
+	}
+
+	serverUrl := hostports[0].String()
+	status, err := mcbedrock.Ping(ctx, serverUrl)
+	if err != nil {
+		log.Printf("error pinging bedrock server '%s': %s", serverUrl, err.Error())
+		field.Value = err.Error()
+		return field
+	}
+
+	field.Name = fmt.Sprintf("bedrock (%d/%d)", status.Players, status.MaxPlayers)
+	field.Value = fmt.Sprintf("%s\nversion %s, %s", serverUrl, status.Version, strings.ToLower(status.GameMode))
+	return field
+}
+
 // eat any errors and assume it is .png
 func getAttachmentName(filename, contentType string) string {
 	var extension string
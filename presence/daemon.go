@@ -0,0 +1,113 @@
+package presence
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/mclookup"
+)
+
+// pings back off to at most this many intervals apart while they fail
+const maxBackoffIntervals = 8
+
+type Config struct {
+	Host     string
+	Style    Style
+	Interval time.Duration
+	// DownStatus is shown while the server can't be reached, idle or dnd
+	DownStatus discordgo.Status
+}
+
+// Daemon keeps the bot's presence in step with the server
+type Daemon struct {
+	session *discordgo.Session
+	cfg     *Config
+	refresh chan struct{}
+}
+
+func NewDaemon(session *discordgo.Session, cfg *Config) *Daemon {
+	return &Daemon{
+		session: session,
+		cfg:     cfg,
+		refresh: make(chan struct{}, 1),
+	}
+}
+
+// Refresh asks for the presence to be set again right away, discord forgets
+// it whenever the gateway reconnects
+func (d *Daemon) Refresh() {
+	select {
+	case d.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Run updates the presence every interval until ctx is done
+func (d *Daemon) Run(ctx context.Context) {
+	var last *discordgo.UpdateStatusData
+	failures := 0
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		force := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-d.refresh:
+			force = true
+			timer.Stop()
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		status, err := d.status(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			failures++
+			log.Printf("error pinging server '%s' for presence: %s", d.cfg.Host, err.Error())
+		} else {
+			failures = 0
+		}
+
+		// presence updates are rate limited, so only send ones that change something
+		if force || last == nil || !reflect.DeepEqual(*last, status) {
+			err = d.session.UpdateStatusComplex(status)
+			if err != nil {
+				log.Printf("error updating presence: %s", err.Error())
+				last = nil
+			} else {
+				last = &status
+			}
+		}
+
+		timer.Reset(d.backoff(failures))
+	}
+}
+
+func (d *Daemon) status(ctx context.Context) (discordgo.UpdateStatusData, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, pong, err := mclookup.Ping(ctx, d.cfg.Host)
+	if err != nil {
+		return Status(nil, d.cfg.Style, d.cfg.DownStatus), err
+	}
+	return Status(pong, d.cfg.Style, d.cfg.DownStatus), nil
+}
+
+// backoff doubles the wait for every failed ping in a row
+func (d *Daemon) backoff(failures int) time.Duration {
+	intervals := 1
+	for i := 0; i < failures && intervals < maxBackoffIntervals; i++ {
+		intervals *= 2
+	}
+	return time.Duration(intervals) * d.cfg.Interval
+}
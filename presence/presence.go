@@ -3,28 +3,66 @@ package presence
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	mcpinger "github.com/Raqbit/mc-pinger"
 	"github.com/bwmarrin/discordgo"
 	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/rcon"
+)
+
+// discord cuts activity names off around here
+const maxActivityLength = 128
+
+// Style is what the bot's activity shows while the server is up
+type Style string
+
+const (
+	// "Watching 3/20 players"
+	StylePlayers Style = "players"
+	// "Playing <motd>"
+	StyleMotd Style = "motd"
 )
 
 func Update(ctx context.Context, host string, s *discordgo.Session) error {
-	hostports, err := mclookup.ResolveMinecraftHostPort(ctx, nil, host)
+	_, pong, err := mclookup.Ping(ctx, host)
 	if err != nil {
-		return fmt.Errorf("error resolving server host '%s': %s", host, err.Error())
+		return err
 	}
-	if len(hostports) == 0 {
-		return s.UpdateGameStatus(0, "")
+	return s.UpdateStatusComplex(Status(pong, StylePlayers, discordgo.StatusOnline))
+}
+
+// Status is the bot's presence for the server as pinged, or while it's down
+// if pong is nil
+func Status(pong *mcpinger.ServerInfo, style Style, downStatus discordgo.Status) discordgo.UpdateStatusData {
+	if pong == nil {
+		return discordgo.UpdateStatusData{
+			Status: string(downStatus),
+			Activities: []*discordgo.Activity{
+				{Type: discordgo.ActivityTypeWatching, Name: "the server come back up"},
+			},
+		}
 	}
 
-	pong, err := mcpinger.New(hostports[0].Host, hostports[0].Port).Ping()
-	if err != nil {
-		return err
+	activity := &discordgo.Activity{
+		Type: discordgo.ActivityTypeWatching,
+		Name: fmt.Sprintf("%d/%d players", pong.Players.Online, pong.Players.Max),
 	}
+	if motd := cleanMotd(pong.Description.Text); style == StyleMotd && motd != "" {
+		activity = &discordgo.Activity{Type: discordgo.ActivityTypeGame, Name: motd}
+	}
+	return discordgo.UpdateStatusData{
+		Status:     string(discordgo.StatusOnline),
+		Activities: []*discordgo.Activity{activity},
+	}
+}
 
-	if pong.Players.Online > 0 {
-		return s.UpdateGameStatus(0, fmt.Sprintf("currently online: (%d/%d)", pong.Players.Online, pong.Players.Max))
+// cleanMotd flattens a motd, which is often two lines of formatted text,
+// into something that fits an activity
+func cleanMotd(motd string) string {
+	motd = strings.Join(strings.Fields(rcon.StripFormatting(motd)), " ")
+	if runes := []rune(motd); len(runes) > maxActivityLength {
+		motd = string(runes[:maxActivityLength-1]) + "…"
 	}
-	return s.UpdateGameStatus(0, "")
+	return motd
 }
@@ -0,0 +1,183 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Commander sends a raw command to the server and returns its output
+type Commander interface {
+	Send(ctx context.Context, command string) (string, error)
+}
+
+// Client sends typed commands to a minecraft server, parsing the output of
+// vanilla, paper/spigot and forge servers
+type Client struct {
+	Commander
+}
+
+// NewClient keeps a connection to hostport open across commands, see Conn
+func NewClient(hostport, password string) *Client {
+	return &Client{
+		Commander: NewConn(hostport, password),
+	}
+}
+
+// Close hangs up the underlying connection if it has one
+func (c *Client) Close() error {
+	if closer, ok := c.Commander.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, command string) (string, error) {
+	log.Printf("sending rcon command: %s", command)
+	output, err := c.Send(ctx, command)
+	if err != nil {
+		return "", fmt.Errorf("error sending rcon command '%s': %w", command, err)
+	}
+	return output, nil
+}
+
+// WhitelistList returns the names of every whitelisted player
+func (c *Client) WhitelistList(ctx context.Context) ([]string, error) {
+	output, err := c.send(ctx, "whitelist list")
+	if err != nil {
+		return nil, err
+	}
+	return parseWhitelistList(output)
+}
+
+func (c *Client) WhitelistAdd(ctx context.Context, player string) error {
+	output, err := c.send(ctx, "whitelist add "+player)
+	if err != nil {
+		return err
+	}
+	return parseWhitelistAdd(output)
+}
+
+func (c *Client) WhitelistRemove(ctx context.Context, player string) error {
+	output, err := c.send(ctx, "whitelist remove "+player)
+	if err != nil {
+		return err
+	}
+	return parseWhitelistRemove(output)
+}
+
+// ListPlayers returns who is online. unlike a server list ping this isn't
+// limited to a sample of players.
+func (c *Client) ListPlayers(ctx context.Context) (*Players, error) {
+	output, err := c.send(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+	return parseListPlayers(output)
+}
+
+func (c *Client) Ban(ctx context.Context, player, reason string) error {
+	command := "ban " + player
+	if reason != "" {
+		command += " " + reason
+	}
+	output, err := c.send(ctx, command)
+	if err != nil {
+		return err
+	}
+	return parseBan(output)
+}
+
+func (c *Client) Pardon(ctx context.Context, player string) error {
+	output, err := c.send(ctx, "pardon "+player)
+	if err != nil {
+		return err
+	}
+	return parsePardon(output)
+}
+
+func (c *Client) Kick(ctx context.Context, player, reason string) error {
+	command := "kick " + player
+	if reason != "" {
+		command += " " + reason
+	}
+	output, err := c.send(ctx, command)
+	if err != nil {
+		return err
+	}
+	return parseKick(output)
+}
+
+func (c *Client) Op(ctx context.Context, player string) error {
+	output, err := c.send(ctx, "op "+player)
+	if err != nil {
+		return err
+	}
+	return parseOp(output)
+}
+
+// TPS tries paper's tps command before falling back to forge's. vanilla
+// servers don't report tps and return ErrUnknownCommand.
+func (c *Client) TPS(ctx context.Context) (*TPS, error) {
+	output, err := c.send(ctx, "tps")
+	if err != nil {
+		return nil, err
+	}
+	tps, err := parseTPS(output)
+	if !errors.Is(err, ErrUnknownCommand) {
+		return tps, err
+	}
+
+	output, err = c.send(ctx, "forge tps")
+	if err != nil {
+		return nil, err
+	}
+	return parseTPS(output)
+}
+
+// Say broadcasts a message to everyone in game
+func (c *Client) Say(ctx context.Context, message string) error {
+	output, err := c.send(ctx, "say "+message)
+	if err != nil {
+		return err
+	}
+	if err := commonErrors(cleanOutput(output)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TextComponent is minecraft's json chat format, see Tellraw
+type TextComponent struct {
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"`
+}
+
+// Tellraw shows message to the players matched by target, e.g. @a. it fails
+// with ErrPlayerNotFound if nobody matches, like when nobody is online.
+func (c *Client) Tellraw(ctx context.Context, target string, message []TextComponent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// keeps <, > and & short so more of the message fits in a command
+	encoder.SetEscapeHTML(false)
+	// the leading empty string stops the first component's style carrying over to the rest
+	components := []interface{}{""}
+	for _, component := range message {
+		components = append(components, component)
+	}
+	err := encoder.Encode(components)
+	if err != nil {
+		return err
+	}
+
+	output, err := c.send(ctx, "tellraw "+target+" "+strings.TrimSpace(buf.String()))
+	if err != nil {
+		return err
+	}
+	return commonErrors(cleanOutput(output))
+}
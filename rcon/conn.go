@@ -0,0 +1,290 @@
+package rcon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultPort = "25575"
+
+	// used when the context passed to Send has no deadline
+	defaultTimeout = 10 * time.Second
+
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+var (
+	ErrAuthFailed  = errors.New("rcon: authentication failed")
+	ErrUnavailable = errors.New("rcon: server unavailable")
+)
+
+// Conn is a long lived rcon connection that is shared between commands and
+// reconnects with backoff when the server goes away. minecraft handles one
+// command at a time, so concurrent commands are sent one after another.
+type Conn struct {
+	hostport string
+	password string
+
+	// held while a command is in flight, a channel so waiting respects contexts
+	lock chan struct{}
+
+	conn      net.Conn
+	reader    *bufio.Reader
+	requestId int32
+
+	// consecutive failed connection attempts and when to try again
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+func NewConn(hostport, password string) *Conn {
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		hostport = net.JoinHostPort(hostport, defaultPort)
+	}
+	return &Conn{
+		hostport: hostport,
+		password: password,
+		lock:     make(chan struct{}, 1),
+	}
+}
+
+// Send runs command and returns its output, reassembled from however many
+// packets the server split it into
+func (c *Conn) Send(ctx context.Context, command string) (string, error) {
+	if len(command) > MaxCommandLength {
+		return "", ErrCommandTooLong
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	select {
+	case c.lock <- struct{}{}:
+	case <-ctx.Done():
+		return "", fmt.Errorf("rcon: waiting for previous command: %w", ctx.Err())
+	}
+	defer func() { <-c.lock }()
+
+	if c.conn != nil {
+		output, err := c.exchange(ctx, command)
+		if err == nil {
+			return output, nil
+		}
+		c.disconnect()
+		// a connection that went away while idle, e.g. because the server
+		// restarted, never ran the command so it's safe to try again
+		if !errors.Is(err, errStaleConnection) {
+			return "", err
+		}
+		log.Printf("rcon connection to %s went away, reconnecting", c.hostport)
+	}
+
+	err := c.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	output, err := c.exchange(ctx, command)
+	if err != nil {
+		c.disconnect()
+		return "", err
+	}
+	return output, nil
+}
+
+// Close hangs up, commands sent afterwards reconnect
+func (c *Conn) Close() error {
+	c.lock <- struct{}{}
+	defer func() { <-c.lock }()
+	c.disconnect()
+	return nil
+}
+
+func (c *Conn) disconnect() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = nil
+	c.reader = nil
+}
+
+// connect dials and authenticates, waiting out the backoff from previous
+// failures if the context allows for it
+func (c *Conn) connect(ctx context.Context) error {
+	if wait := time.Until(c.retryAt); wait > 0 {
+		if deadline, _ := ctx.Deadline(); deadline.Before(c.retryAt) {
+			return fmt.Errorf("%w, retrying in %s: %s", ErrUnavailable, wait.Round(time.Second), c.lastErr)
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fmt.Errorf("rcon: waiting to reconnect: %w", ctx.Err())
+		}
+	}
+
+	err := c.dial(ctx)
+	if err != nil {
+		c.disconnect()
+		c.failures++
+		c.lastErr = err
+		c.retryAt = time.Now().Add(reconnectBackoff(c.failures))
+		return err
+	}
+	c.failures = 0
+	c.lastErr = nil
+	c.retryAt = time.Time{}
+	return nil
+}
+
+func reconnectBackoff(failures int) time.Duration {
+	backoff := minReconnectBackoff
+	for i := 1; i < failures && backoff < maxReconnectBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxReconnectBackoff {
+		backoff = maxReconnectBackoff
+	}
+	return backoff
+}
+
+func (c *Conn) dial(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.hostport)
+	if err != nil {
+		return fmt.Errorf("rcon: error connecting to %s: %w", c.hostport, err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	stop := c.watch(ctx)
+	defer stop()
+
+	id := c.nextRequestId()
+	err = writePacket(c.conn, packet{Id: id, Type: packetTypeLogin, Body: c.password})
+	if err != nil {
+		return fmt.Errorf("rcon: error authenticating: %w", contextError(ctx, err))
+	}
+	for {
+		response, err := readPacket(c.reader)
+		if err != nil {
+			return fmt.Errorf("rcon: error authenticating: %w", contextError(ctx, err))
+		}
+		// some servers send an empty response value before the auth response
+		if response.Type != packetTypeAuthResponse {
+			continue
+		}
+		if response.Id == -1 {
+			return ErrAuthFailed
+		}
+		if response.Id == id {
+			return nil
+		}
+	}
+}
+
+// errStaleConnection marks failures where the server hung up before
+// answering, see Send
+var errStaleConnection = errors.New("rcon: connection closed by server")
+
+// exchange sends command followed by a packet the server doesn't understand.
+// responses come back in order, so everything up to the reply to the second
+// packet is the command's output.
+func (c *Conn) exchange(ctx context.Context, command string) (string, error) {
+	stop := c.watch(ctx)
+	defer stop()
+
+	id := c.nextRequestId()
+	sentinel := c.nextRequestId()
+	err := writePacket(c.conn, packet{Id: id, Type: packetTypeCommand, Body: command})
+	if err == nil {
+		err = writePacket(c.conn, packet{Id: sentinel, Type: packetTypeResponse})
+	}
+	if err != nil {
+		return "", fmt.Errorf("rcon: error sending command: %w", staleError(ctx, err))
+	}
+
+	var output strings.Builder
+	received := false
+	for {
+		response, err := readPacket(c.reader)
+		if err != nil {
+			if received {
+				return "", fmt.Errorf("rcon: error reading response: %w", contextError(ctx, err))
+			}
+			return "", fmt.Errorf("rcon: error reading response: %w", staleError(ctx, err))
+		}
+		received = true
+
+		switch response.Id {
+		case id:
+			output.WriteString(response.Body)
+		case sentinel:
+			return output.String(), nil
+		}
+		// anything else is left over from a previous command and ignored
+	}
+}
+
+func (c *Conn) nextRequestId() int32 {
+	c.requestId++
+	// -1 means authentication failed, so stay positive
+	if c.requestId <= 0 {
+		c.requestId = 1
+	}
+	return c.requestId
+}
+
+// watch applies the context's deadline to the connection and interrupts
+// blocked reads and writes if it's cancelled
+func (c *Conn) watch(ctx context.Context) (stop func()) {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+
+	conn := c.conn
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// contextError reports the context's error instead of the i/o timeout it
+// caused. the connection deadline can pass a moment before the context's does.
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func staleError(ctx context.Context, err error) error {
+	if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		return contextError(ctx, err)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return fmt.Errorf("%w: %s", errStaleConnection, err)
+	}
+	return err
+}
@@ -0,0 +1,248 @@
+package rcon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks just enough rcon to behave like a vanilla server
+type fakeServer struct {
+	listener net.Listener
+	password string
+	// respond returns the output for a command
+	respond func(command string) string
+
+	mu          sync.Mutex
+	connections int
+	conns       []net.Conn
+}
+
+func newFakeServer(t *testing.T, password string, respond func(string) string) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	server := &fakeServer{listener: listener, password: password, respond: respond}
+	go server.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		server.dropConnections()
+	})
+	return server
+}
+
+func (f *fakeServer) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.connections++
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		request, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		switch {
+		case request.Type == packetTypeLogin:
+			id := request.Id
+			if request.Body != f.password {
+				id = -1
+			} else {
+				authenticated = true
+			}
+			writePacket(conn, packet{Id: id, Type: packetTypeAuthResponse})
+		case !authenticated:
+			return
+		case request.Type == packetTypeCommand:
+			output := f.respond(request.Body)
+			// split like vanilla does
+			for {
+				chunk := output
+				if len(chunk) > maxResponseBodySize {
+					chunk = chunk[:maxResponseBodySize]
+				}
+				writePacket(conn, packet{Id: request.Id, Type: packetTypeResponse, Body: chunk})
+				output = output[len(chunk):]
+				if output == "" {
+					break
+				}
+			}
+		default:
+			writePacket(conn, packet{Id: request.Id, Type: packetTypeResponse, Body: "Unknown request 0"})
+		}
+	}
+}
+
+// dropConnections hangs up on every client, like a server restart
+func (f *fakeServer) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeServer) connectionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connections
+}
+
+func TestConnSend(t *testing.T) {
+	server := newFakeServer(t, "hunter2", func(command string) string {
+		return "ran " + command
+	})
+	conn := NewConn(server.listener.Addr().String(), "hunter2")
+	defer conn.Close()
+
+	for _, command := range []string{"list", "whitelist list"} {
+		output, err := conn.Send(context.Background(), command)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if output != "ran "+command {
+			t.Errorf("got %q, want %q", output, "ran "+command)
+		}
+	}
+	if got := server.connectionCount(); got != 1 {
+		t.Errorf("got %d connections, want the connection to be reused", got)
+	}
+}
+
+func TestConnSendMultiPacketResponse(t *testing.T) {
+	long := strings.Repeat("bsdlp, ", 2000)
+	server := newFakeServer(t, "hunter2", func(string) string { return long })
+	conn := NewConn(server.listener.Addr().String(), "hunter2")
+	defer conn.Close()
+
+	output, err := conn.Send(context.Background(), "whitelist list")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if output != long {
+		t.Errorf("got %d bytes, want %d", len(output), len(long))
+	}
+}
+
+func TestConnSendConcurrent(t *testing.T) {
+	server := newFakeServer(t, "hunter2", func(command string) string {
+		return command
+	})
+	conn := NewConn(server.listener.Addr().String(), "hunter2")
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		command := "say " + strings.Repeat("a", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			output, err := conn.Send(context.Background(), command)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			if output != command {
+				t.Errorf("got %q, want %q", output, command)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConnReconnects(t *testing.T) {
+	server := newFakeServer(t, "hunter2", func(string) string { return "ok" })
+	conn := NewConn(server.listener.Addr().String(), "hunter2")
+	defer conn.Close()
+
+	_, err := conn.Send(context.Background(), "list")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	server.dropConnections()
+
+	output, err := conn.Send(context.Background(), "list")
+	if err != nil {
+		t.Fatalf("unexpected error after server hung up: %s", err)
+	}
+	if output != "ok" {
+		t.Errorf("got %q, want %q", output, "ok")
+	}
+	if got := server.connectionCount(); got != 2 {
+		t.Errorf("got %d connections, want 2", got)
+	}
+}
+
+func TestConnAuthFailed(t *testing.T) {
+	server := newFakeServer(t, "hunter2", func(string) string { return "ok" })
+	conn := NewConn(server.listener.Addr().String(), "wrong")
+	defer conn.Close()
+
+	_, err := conn.Send(context.Background(), "list")
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("got error %v, want %v", err, ErrAuthFailed)
+	}
+
+	// the next attempt is backed off and fails fast instead of waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = conn.Send(ctx, "list")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got error %v, want %v", err, ErrUnavailable)
+	}
+}
+
+func TestConnSendTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	server := newFakeServer(t, "hunter2", func(string) string {
+		<-block
+		return "ok"
+	})
+	conn := NewConn(server.listener.Addr().String(), "hunter2")
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := conn.Send(ctx, "list")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := reconnectBackoff(tt.failures); got != tt.want {
+			t.Errorf("reconnectBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
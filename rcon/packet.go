@@ -0,0 +1,71 @@
+package rcon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	packetTypeResponse     int32 = 0
+	packetTypeCommand      int32 = 2
+	packetTypeAuthResponse int32 = 2
+	packetTypeLogin        int32 = 3
+)
+
+const (
+	// id, type and the two null terminators
+	packetHeaderSize  = 4 + 4
+	packetPaddingSize = 2
+
+	// minecraft splits responses into packets with at most 4096 byte bodies
+	maxResponseBodySize = 4096
+)
+
+// MaxCommandLength is the longest command in bytes, minecraft drops
+// connections sending longer ones
+const MaxCommandLength = 1446
+
+var ErrCommandTooLong = errors.New("rcon: command is too long")
+
+type packet struct {
+	Id   int32
+	Type int32
+	Body string
+}
+
+func writePacket(w io.Writer, p packet) error {
+	length := packetHeaderSize + len(p.Body) + packetPaddingSize
+	buf := make([]byte, 4+length)
+	binary.LittleEndian.PutUint32(buf[0:], uint32(length))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(p.Id))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(p.Type))
+	copy(buf[12:], p.Body)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	var length int32
+	err := binary.Read(r, binary.LittleEndian, &length)
+	if err != nil {
+		return packet{}, err
+	}
+	// leave some room for servers that don't split quite like vanilla
+	if length < packetHeaderSize+packetPaddingSize || length > 4*maxResponseBodySize {
+		return packet{}, fmt.Errorf("rcon: invalid packet length %d", length)
+	}
+
+	buf := make([]byte, length)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return packet{}, err
+	}
+	return packet{
+		Id:   int32(binary.LittleEndian.Uint32(buf[0:])),
+		Type: int32(binary.LittleEndian.Uint32(buf[4:])),
+		Body: string(buf[packetHeaderSize : length-packetPaddingSize]),
+	}, nil
+}
@@ -0,0 +1,310 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrPlayerNotFound     = errors.New("rcon: player does not exist")
+	ErrAlreadyWhitelisted = errors.New("rcon: player is already whitelisted")
+	ErrNotWhitelisted     = errors.New("rcon: player is not whitelisted")
+	ErrAlreadyBanned      = errors.New("rcon: player is already banned")
+	ErrNotBanned          = errors.New("rcon: player is not banned")
+	ErrAlreadyOp          = errors.New("rcon: player is already an operator")
+	ErrUnknownCommand     = errors.New("rcon: unknown command")
+)
+
+// UnexpectedOutputError is returned when the server responds with something
+// none of the parsers recognise
+type UnexpectedOutputError struct {
+	Command string
+	Output  string
+}
+
+func (e *UnexpectedOutputError) Error() string {
+	return fmt.Sprintf("rcon: unexpected output for '%s': %q", e.Command, e.Output)
+}
+
+// formatting codes like §a that paper and plugins sprinkle through output
+var formattingCodePattern = regexp.MustCompile(`§[0-9a-fk-orx]`)
+
+// StripFormatting removes formatting codes from output meant for humans
+func StripFormatting(output string) string {
+	return formattingCodePattern.ReplaceAllString(output, "")
+}
+
+// cleanOutput strips formatting codes and surrounding whitespace
+func cleanOutput(output string) string {
+	return strings.TrimSpace(StripFormatting(output))
+}
+
+// commonErrors maps the failure messages shared by many commands across
+// server implementations to typed errors
+func commonErrors(output string) error {
+	lower := strings.ToLower(output)
+	switch {
+	case strings.HasPrefix(lower, "unknown or incomplete command"),
+		strings.HasPrefix(lower, "unknown command"):
+		return ErrUnknownCommand
+	case strings.Contains(lower, "player does not exist"),
+		strings.Contains(lower, "no player was found"),
+		strings.Contains(lower, "could not find player"),
+		strings.Contains(lower, "player not found"),
+		strings.Contains(lower, "can't find player"):
+		return ErrPlayerNotFound
+	}
+	return nil
+}
+
+// splitNames splits a comma separated list of player names
+func splitNames(names string) []string {
+	result := []string{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// handles both "There are 2 whitelisted players: a, b" and the older
+// "There are 2 (out of 3 seen) whitelisted players:"
+var whitelistListPattern = regexp.MustCompile(`(?is)^there (?:are|is) \d+(?: \(out of \d+ seen\))? whitelisted players?:(.*)$`)
+
+func parseWhitelistList(output string) ([]string, error) {
+	cleaned := cleanOutput(output)
+	if strings.HasPrefix(strings.ToLower(cleaned), "there are no whitelisted players") {
+		return []string{}, nil
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return nil, err
+	}
+
+	match := whitelistListPattern.FindStringSubmatch(cleaned)
+	if match == nil {
+		return nil, &UnexpectedOutputError{Command: "whitelist list", Output: output}
+	}
+	return splitNames(match[1]), nil
+}
+
+func parseWhitelistAdd(output string) error {
+	cleaned := cleanOutput(output)
+	lower := strings.ToLower(cleaned)
+	switch {
+	case strings.HasPrefix(lower, "added "):
+		return nil
+	case strings.Contains(lower, "already whitelisted"):
+		return ErrAlreadyWhitelisted
+	// versions before 1.13 say this when the player can't be found
+	case strings.HasPrefix(lower, "could not add"):
+		return ErrPlayerNotFound
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return err
+	}
+	return &UnexpectedOutputError{Command: "whitelist add", Output: output}
+}
+
+func parseWhitelistRemove(output string) error {
+	cleaned := cleanOutput(output)
+	lower := strings.ToLower(cleaned)
+	switch {
+	case strings.HasPrefix(lower, "removed "):
+		return nil
+	case strings.Contains(lower, "not whitelisted"):
+		return ErrNotWhitelisted
+	case strings.HasPrefix(lower, "could not remove"):
+		return ErrPlayerNotFound
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return err
+	}
+	return &UnexpectedOutputError{Command: "whitelist remove", Output: output}
+}
+
+// Players is who is online according to the list command
+type Players struct {
+	Online int
+	Max    int
+	Names  []string
+}
+
+var (
+	// vanilla and forge since 1.13, also paper
+	listOfAMaxPattern = regexp.MustCompile(`(?is)^there (?:are|is) (\d+) of a max(?:imum)? of (\d+) players? online[:.]?(.*)$`)
+	// vanilla before 1.13 and spigot
+	listSlashPattern = regexp.MustCompile(`(?is)^there (?:are|is) (\d+)/(\d+) players? online[:.]?(.*)$`)
+	// bukkit plugins like essentials, with players listed by group
+	listOutOfPattern = regexp.MustCompile(`(?is)^there (?:are|is) (\d+) out of (?:a )?maximum (?:of )?(\d+) players? online[:.]?(.*)$`)
+	// the group prefix on each line of a grouped list, e.g. "default: bsdlp"
+	listGroupPattern = regexp.MustCompile(`^[^:,]+:\s*`)
+)
+
+func parseListPlayers(output string) (*Players, error) {
+	cleaned := cleanOutput(output)
+	if err := commonErrors(cleaned); err != nil {
+		return nil, err
+	}
+
+	var match []string
+	for _, pattern := range []*regexp.Regexp{listOfAMaxPattern, listSlashPattern, listOutOfPattern} {
+		match = pattern.FindStringSubmatch(cleaned)
+		if match != nil {
+			break
+		}
+	}
+	if match == nil {
+		return nil, &UnexpectedOutputError{Command: "list", Output: output}
+	}
+
+	online, _ := strconv.Atoi(match[1])
+	max, _ := strconv.Atoi(match[2])
+	players := &Players{
+		Online: online,
+		Max:    max,
+		Names:  []string{},
+	}
+
+	for _, line := range strings.Split(match[3], "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		line = listGroupPattern.ReplaceAllString(line, "")
+		for _, name := range splitNames(line) {
+			// essentials marks afk and hidden players
+			name = strings.TrimPrefix(name, "[AFK]")
+			name = strings.TrimPrefix(name, "[HIDDEN]")
+			players.Names = append(players.Names, strings.TrimSpace(name))
+		}
+	}
+	return players, nil
+}
+
+func parseBan(output string) error {
+	cleaned := cleanOutput(output)
+	lower := strings.ToLower(cleaned)
+	switch {
+	case strings.HasPrefix(lower, "banned "), strings.HasPrefix(lower, "banned player"):
+		return nil
+	case strings.Contains(lower, "already banned"):
+		return ErrAlreadyBanned
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return err
+	}
+	return &UnexpectedOutputError{Command: "ban", Output: output}
+}
+
+func parsePardon(output string) error {
+	cleaned := cleanOutput(output)
+	lower := strings.ToLower(cleaned)
+	switch {
+	case strings.HasPrefix(lower, "unbanned "):
+		return nil
+	case strings.Contains(lower, "isn't banned"), strings.Contains(lower, "is not banned"):
+		return ErrNotBanned
+	// versions before 1.13 say this when the player isn't banned
+	case strings.HasPrefix(lower, "could not unban"):
+		return ErrNotBanned
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return err
+	}
+	return &UnexpectedOutputError{Command: "pardon", Output: output}
+}
+
+func parseKick(output string) error {
+	cleaned := cleanOutput(output)
+	lower := strings.ToLower(cleaned)
+	if strings.HasPrefix(lower, "kicked ") {
+		return nil
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return err
+	}
+	// kicking someone who is offline is the same as them not existing
+	if strings.Contains(lower, "not online") || strings.Contains(lower, "that player cannot be found") {
+		return ErrPlayerNotFound
+	}
+	return &UnexpectedOutputError{Command: "kick", Output: output}
+}
+
+func parseOp(output string) error {
+	cleaned := cleanOutput(output)
+	lower := strings.ToLower(cleaned)
+	switch {
+	case strings.HasPrefix(lower, "made ") || strings.HasPrefix(lower, "opped "):
+		return nil
+	case strings.Contains(lower, "already is an operator"), strings.Contains(lower, "already an operator"):
+		return ErrAlreadyOp
+	// versions before 1.13 say this when the player can't be found
+	case strings.HasPrefix(lower, "could not op"):
+		return ErrPlayerNotFound
+	}
+	if err := commonErrors(cleaned); err != nil {
+		return err
+	}
+	return &UnexpectedOutputError{Command: "op", Output: output}
+}
+
+// TPS is the server's ticks per second averaged over the last 1, 5 and 15
+// minutes. forge only reports a single mean, which is used for all three.
+type TPS struct {
+	OneMinute      float64
+	FiveMinutes    float64
+	FifteenMinutes float64
+}
+
+var (
+	// paper and spigot: "TPS from last 1m, 5m, 15m: 20.0, 20.0, 19.98", values can be prefixed with *
+	paperTPSPattern = regexp.MustCompile(`(?i)tps from last 1m, 5m, 15m:\s*\*?([\d.]+),\s*\*?([\d.]+),\s*\*?([\d.]+)`)
+	// forge before 1.16: "Overall : Mean tick time: 4.1 ms. Mean TPS: 20.000"
+	forgeLegacyTPSPattern = regexp.MustCompile(`(?i)overall\s*:.*mean tps:\s*([\d.]+)`)
+	// forge since 1.16: "Overall: 20.000 TPS (3.125 ms/tick)"
+	forgeTPSPattern = regexp.MustCompile(`(?i)overall\s*:\s*([\d.]+) tps`)
+)
+
+func parseTPS(output string) (*TPS, error) {
+	cleaned := cleanOutput(output)
+	if err := commonErrors(cleaned); err != nil {
+		return nil, err
+	}
+
+	if match := paperTPSPattern.FindStringSubmatch(cleaned); match != nil {
+		values := make([]float64, 3)
+		for i := range values {
+			value, err := strconv.ParseFloat(match[i+1], 64)
+			if err != nil {
+				return nil, &UnexpectedOutputError{Command: "tps", Output: output}
+			}
+			values[i] = value
+		}
+		return &TPS{
+			OneMinute:      values[0],
+			FiveMinutes:    values[1],
+			FifteenMinutes: values[2],
+		}, nil
+	}
+
+	for _, pattern := range []*regexp.Regexp{forgeTPSPattern, forgeLegacyTPSPattern} {
+		if match := pattern.FindStringSubmatch(cleaned); match != nil {
+			value, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				return nil, &UnexpectedOutputError{Command: "forge tps", Output: output}
+			}
+			return &TPS{
+				OneMinute:      value,
+				FiveMinutes:    value,
+				FifteenMinutes: value,
+			}, nil
+		}
+	}
+
+	return nil, &UnexpectedOutputError{Command: "tps", Output: output}
+}
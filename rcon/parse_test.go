@@ -0,0 +1,226 @@
+package rcon
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseWhitelistList(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "vanilla",
+			output: "There are 3 whitelisted players: ouroboronn, MuchJokes, bsdlp",
+			want:   []string{"ouroboronn", "MuchJokes", "bsdlp"},
+		},
+		{
+			name:   "vanilla 1.12",
+			output: "There are 2 (out of 5 seen) whitelisted players:\nouroboronn, bsdlp",
+			want:   []string{"ouroboronn", "bsdlp"},
+		},
+		{
+			name:   "paper with formatting codes",
+			output: "§6There are §c2§6 whitelisted players: §fouroboronn, bsdlp",
+			want:   []string{"ouroboronn", "bsdlp"},
+		},
+		{
+			name:   "forge single player",
+			output: "There are 1 whitelisted players: bsdlp\n",
+			want:   []string{"bsdlp"},
+		},
+		{
+			name:   "empty",
+			output: "There are no whitelisted players",
+			want:   []string{},
+		},
+		{
+			name:    "unexpected",
+			output:  "whitelist is turned off",
+			wantErr: &UnexpectedOutputError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWhitelistList(tt.output)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWhitelistChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) error
+		output  string
+		wantErr error
+	}{
+		{"add vanilla", parseWhitelistAdd, "Added bsdlp to the whitelist", nil},
+		{"add already whitelisted", parseWhitelistAdd, "Player is already whitelisted", ErrAlreadyWhitelisted},
+		{"add player does not exist", parseWhitelistAdd, "That player does not exist", ErrPlayerNotFound},
+		{"add vanilla 1.12 not found", parseWhitelistAdd, "Could not add notaplayer to the whitelist", ErrPlayerNotFound},
+		{"add paper formatting", parseWhitelistAdd, "§7Added bsdlp to the whitelist", nil},
+		{"remove vanilla", parseWhitelistRemove, "Removed bsdlp from the whitelist", nil},
+		{"remove not whitelisted", parseWhitelistRemove, "Player is not whitelisted", ErrNotWhitelisted},
+		{"remove player does not exist", parseWhitelistRemove, "That player does not exist", ErrPlayerNotFound},
+		{"add unexpected", parseWhitelistAdd, "something went wrong", &UnexpectedOutputError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, tt.parse(tt.output), tt.wantErr)
+		})
+	}
+}
+
+func TestParseListPlayers(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    *Players
+		wantErr error
+	}{
+		{
+			name:   "vanilla",
+			output: "There are 2 of a max of 20 players online: ouroboronn, bsdlp",
+			want:   &Players{Online: 2, Max: 20, Names: []string{"ouroboronn", "bsdlp"}},
+		},
+		{
+			name:   "vanilla nobody online",
+			output: "There are 0 of a max of 20 players online: ",
+			want:   &Players{Online: 0, Max: 20, Names: []string{}},
+		},
+		{
+			name:   "vanilla 1.12 and spigot",
+			output: "There are 2/20 players online:\nouroboronn, bsdlp",
+			want:   &Players{Online: 2, Max: 20, Names: []string{"ouroboronn", "bsdlp"}},
+		},
+		{
+			name:   "paper",
+			output: "There are 1 of a max of 50 players online: bsdlp",
+			want:   &Players{Online: 1, Max: 50, Names: []string{"bsdlp"}},
+		},
+		{
+			name:   "essentials groups",
+			output: "§6There are §c3§6 out of maximum §c20§6 players online.\n§6admins§r: §f[AFK]bsdlp\n§6default§r: ouroboronn, MuchJokes",
+			want:   &Players{Online: 3, Max: 20, Names: []string{"bsdlp", "ouroboronn", "MuchJokes"}},
+		},
+		{
+			name:   "forge",
+			output: "There are 1 of a max of 10 players online: bsdlp\n",
+			want:   &Players{Online: 1, Max: 10, Names: []string{"bsdlp"}},
+		},
+		{
+			name:    "unknown command",
+			output:  "Unknown or incomplete command, see below for error",
+			wantErr: ErrUnknownCommand,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListPlayers(tt.output)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseModeration(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) error
+		output  string
+		wantErr error
+	}{
+		{"ban vanilla", parseBan, "Banned bsdlp: griefing", nil},
+		{"ban already banned", parseBan, "Nothing changed. The player is already banned", ErrAlreadyBanned},
+		{"ban player does not exist", parseBan, "That player does not exist", ErrPlayerNotFound},
+		{"pardon vanilla", parsePardon, "Unbanned bsdlp", nil},
+		{"pardon not banned", parsePardon, "Nothing changed. The player isn't banned", ErrNotBanned},
+		{"pardon vanilla 1.12 not banned", parsePardon, "Could not unban player bsdlp", ErrNotBanned},
+		{"kick vanilla", parseKick, "Kicked bsdlp: Kicked by an operator", nil},
+		{"kick offline", parseKick, "No player was found", ErrPlayerNotFound},
+		{"kick spigot offline", parseKick, "That player cannot be found", ErrPlayerNotFound},
+		{"op vanilla", parseOp, "Made bsdlp a server operator", nil},
+		{"op already", parseOp, "Nothing changed. The player already is an operator", ErrAlreadyOp},
+		{"op vanilla 1.12 not found", parseOp, "Could not op notaplayer", ErrPlayerNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, tt.parse(tt.output), tt.wantErr)
+		})
+	}
+}
+
+func TestParseTPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    *TPS
+		wantErr error
+	}{
+		{
+			name:   "paper",
+			output: "§6TPS from last 1m, 5m, 15m: §a20.0, §a19.98, §a19.97",
+			want:   &TPS{OneMinute: 20, FiveMinutes: 19.98, FifteenMinutes: 19.97},
+		},
+		{
+			name:   "paper over 20",
+			output: "TPS from last 1m, 5m, 15m: *20.0, *20.0, 19.5",
+			want:   &TPS{OneMinute: 20, FiveMinutes: 20, FifteenMinutes: 19.5},
+		},
+		{
+			name:   "forge",
+			output: "minecraft:overworld: 20.000 TPS (3.125 ms/tick)\nminecraft:the_nether: 20.000 TPS (0.120 ms/tick)\nOverall: 19.500 TPS (5.320 ms/tick)",
+			want:   &TPS{OneMinute: 19.5, FiveMinutes: 19.5, FifteenMinutes: 19.5},
+		},
+		{
+			name:   "forge 1.12",
+			output: "Dim  0 : Mean tick time: 3.214 ms. Mean TPS: 20.000\nOverall : Mean tick time: 4.102 ms. Mean TPS: 18.250",
+			want:   &TPS{OneMinute: 18.25, FiveMinutes: 18.25, FifteenMinutes: 18.25},
+		},
+		{
+			name:    "vanilla",
+			output:  "Unknown or incomplete command, see below for error\ntps<--[HERE]",
+			wantErr: ErrUnknownCommand,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTPS(tt.output)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func checkErr(t *testing.T, err, want error) {
+	t.Helper()
+	var unexpected *UnexpectedOutputError
+	switch {
+	case want == nil && err != nil:
+		t.Fatalf("unexpected error: %s", err)
+	case want == nil:
+	case errors.As(want, &unexpected):
+		if !errors.As(err, &unexpected) {
+			t.Fatalf("got error %v, want an UnexpectedOutputError", err)
+		}
+	case !errors.Is(err, want):
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
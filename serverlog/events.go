@@ -0,0 +1,214 @@
+package serverlog
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Event is something that happened on the server according to its log
+type Event interface {
+	// At is when the line was logged
+	At() time.Time
+}
+
+// Chat is a player talking in game
+type Chat struct {
+	Time    time.Time
+	Player  string
+	Message string
+}
+
+// Join is a player connecting
+type Join struct {
+	Time   time.Time
+	Player string
+}
+
+// Leave is a player disconnecting
+type Leave struct {
+	Time   time.Time
+	Player string
+}
+
+// Death is a player dying, Message is the whole death message, e.g.
+// "bsdlp was slain by Zombie"
+type Death struct {
+	Time    time.Time
+	Player  string
+	Message string
+}
+
+type AdvancementKind string
+
+const (
+	AdvancementTask      AdvancementKind = "advancement"
+	AdvancementGoal      AdvancementKind = "goal"
+	AdvancementChallenge AdvancementKind = "challenge"
+	// achievements were replaced by advancements in 1.12
+	AdvancementAchievement AdvancementKind = "achievement"
+)
+
+// Advancement is a player making an advancement
+type Advancement struct {
+	Time        time.Time
+	Player      string
+	Kind        AdvancementKind
+	Advancement string
+}
+
+// ServerStarting is logged as soon as the server starts loading
+type ServerStarting struct {
+	Time    time.Time
+	Version string
+}
+
+// ServerStarted is logged once the server is ready for players
+type ServerStarted struct {
+	Time time.Time
+	// how long the server took to start
+	Took time.Duration
+}
+
+// ServerStopping is logged when the server starts shutting down
+type ServerStopping struct {
+	Time time.Time
+}
+
+func (e *Chat) At() time.Time           { return e.Time }
+func (e *Join) At() time.Time           { return e.Time }
+func (e *Leave) At() time.Time          { return e.Time }
+func (e *Death) At() time.Time          { return e.Time }
+func (e *Advancement) At() time.Time    { return e.Time }
+func (e *ServerStarting) At() time.Time { return e.Time }
+func (e *ServerStarted) At() time.Time  { return e.Time }
+func (e *ServerStopping) At() time.Time { return e.Time }
+
+var (
+	// vanilla, paper and spigot, and forge before 1.17:
+	//
+	//	[12:34:56] [Server thread/INFO]: message
+	//	[12:34:56] [Server thread/INFO] [minecraft/DedicatedServer]: message
+	//
+	// paper's console:
+	//
+	//	[12:34:56 INFO]: message
+	shortLinePattern = regexp.MustCompile(`^\[(\d{2}:\d{2}:\d{2})(?:\] \[[^\]]*/| )INFO\](?: \[[^\]]*\])?: (.*)$`)
+
+	// forge since 1.17 logs the date too:
+	//
+	//	[18Oct2026 12:34:56.789] [Server thread/INFO] [net.minecraft.server.MinecraftServer/]: message
+	forgeLinePattern = regexp.MustCompile(`^\[(\d{2}[A-Za-z]{3}\d{4} \d{2}:\d{2}:\d{2}\.\d{3})\] \[[^\]]*/INFO\](?: \[[^\]]*\])?: (.*)$`)
+)
+
+// bedrock players joining through floodgate have a "." in front of their names
+const player = `(\.?[A-Za-z0-9_]{1,16})`
+
+var (
+	chatPattern        = regexp.MustCompile(`^(?:\[Not Secure\] )?<` + player + `> (.*)$`)
+	joinPattern        = regexp.MustCompile(`^` + player + `(?: \(formerly known as [A-Za-z0-9_]+\))? joined the game$`)
+	leavePattern       = regexp.MustCompile(`^` + player + ` left the game$`)
+	advancementPattern = regexp.MustCompile(`^` + player + ` has (?:made the advancement|reached the goal|completed the challenge|just earned the achievement) \[(.+)\]$`)
+	startingPattern    = regexp.MustCompile(`^Starting minecraft server version (.+)$`)
+	startedPattern     = regexp.MustCompile(`^Done \((\d+(?:\.\d+)?)s\)! For help, type "help"`)
+	deathPattern       = regexp.MustCompile(`^` + player + ` (.+)$`)
+)
+
+// the start of every vanilla death message after the player's name
+var deathMessages = []string{
+	"was shot by", "was pummeled by", "was pricked to death", "walked into a cactus",
+	"drowned", "died from dehydration", "experienced kinetic energy", "blew up",
+	"was blown up by", "was killed by", "hit the ground too hard", "fell from a high place",
+	"fell off", "fell while", "was doomed to fall", "fell too far", "was impaled",
+	"was squashed by", "was squished", "went off with a bang", "went up in flames",
+	"walked into fire", "burned to death", "was burnt to a crisp", "tried to swim in lava",
+	"discovered the floor was lava", "walked into the danger zone", "was struck by lightning",
+	"was killed", "froze to death", "was frozen to death", "was slain by", "was fireballed by",
+	"was stung to death", "was shot by a skull from", "was obliterated by", "starved to death",
+	"suffocated in a wall", "was squeezed too much", "was poked to death", "left the confines of this world",
+	"fell out of the world", "didn't want to live", "withered away", "was roasted in dragon's breath",
+	"was skewered by", "was speared by", "died because of", "was sniped by", "died",
+}
+
+// ParseLine returns the event logged on line, or nil if it isn't one we know
+// about. most formats only log the time of day, so the date is taken from now.
+func ParseLine(line string, now time.Time) Event {
+	line = strings.TrimRight(line, "\r\n")
+
+	var at time.Time
+	var message string
+	if match := shortLinePattern.FindStringSubmatch(line); match != nil {
+		at = parseTime(match[1], now)
+		message = match[2]
+	} else if match := forgeLinePattern.FindStringSubmatch(line); match != nil {
+		var err error
+		at, err = time.ParseInLocation("02Jan2006 15:04:05.000", match[1], now.Location())
+		if err != nil {
+			return nil
+		}
+		message = match[2]
+	} else {
+		return nil
+	}
+
+	return parseMessage(at, message)
+}
+
+func parseMessage(at time.Time, message string) Event {
+	if match := chatPattern.FindStringSubmatch(message); match != nil {
+		return &Chat{Time: at, Player: match[1], Message: match[2]}
+	}
+	if match := joinPattern.FindStringSubmatch(message); match != nil {
+		return &Join{Time: at, Player: match[1]}
+	}
+	if match := leavePattern.FindStringSubmatch(message); match != nil {
+		return &Leave{Time: at, Player: match[1]}
+	}
+	if match := advancementPattern.FindStringSubmatch(message); match != nil {
+		return &Advancement{Time: at, Player: match[1], Kind: advancementKind(message), Advancement: match[2]}
+	}
+	if match := startingPattern.FindStringSubmatch(message); match != nil {
+		return &ServerStarting{Time: at, Version: match[1]}
+	}
+	if match := startedPattern.FindStringSubmatch(message); match != nil {
+		took, _ := time.ParseDuration(match[1] + "s")
+		return &ServerStarted{Time: at, Took: took}
+	}
+	if message == "Stopping server" {
+		return &ServerStopping{Time: at}
+	}
+	if match := deathPattern.FindStringSubmatch(message); match != nil {
+		for _, death := range deathMessages {
+			if match[2] == death || strings.HasPrefix(match[2], death+" ") {
+				return &Death{Time: at, Player: match[1], Message: message}
+			}
+		}
+	}
+	return nil
+}
+
+func advancementKind(message string) AdvancementKind {
+	switch {
+	case strings.Contains(message, " has reached the goal "):
+		return AdvancementGoal
+	case strings.Contains(message, " has completed the challenge "):
+		return AdvancementChallenge
+	case strings.Contains(message, " has just earned the achievement "):
+		return AdvancementAchievement
+	}
+	return AdvancementTask
+}
+
+// parseTime puts a logged time of day on now's date, stepping back a day if
+// that would be in the future, e.g. a line from before midnight read after it
+func parseTime(clock string, now time.Time) time.Time {
+	t, err := time.ParseInLocation("15:04:05", clock, now.Location())
+	if err != nil {
+		return now
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+	if at.After(now.Add(time.Minute)) {
+		at = at.AddDate(0, 0, -1)
+	}
+	return at
+}
@@ -0,0 +1,95 @@
+package serverlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// the samples end before this, so times of day land on the 18th
+var goldenNow = time.Date(2026, time.October, 18, 23, 59, 0, 0, time.UTC)
+
+func TestParseLineGolden(t *testing.T) {
+	samples, err := filepath.Glob("testdata/*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("no log samples in testdata")
+	}
+
+	for _, sample := range samples {
+		t.Run(filepath.Base(sample), func(t *testing.T) {
+			got := parseSample(t, sample)
+
+			golden := strings.TrimSuffix(sample, ".log") + ".golden"
+			if *update {
+				err := os.WriteFile(golden, []byte(got), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("error reading golden file, run go test with -update to create it: %s", err)
+			}
+			if got != string(want) {
+				t.Errorf("events don't match %s, run go test with -update if this is expected\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// parseSample describes the event parsed from every line of the sample, one
+// per line
+func parseSample(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var builder strings.Builder
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		event := ParseLine(scanner.Text(), goldenNow)
+		if event == nil {
+			continue
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&builder, "%T %s\n", event, data)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return builder.String()
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 0, 0, 30, 0, time.UTC)
+	tests := []struct {
+		clock string
+		want  time.Time
+	}{
+		{"00:00:10", time.Date(2026, time.October, 18, 0, 0, 10, 0, time.UTC)},
+		// logged just before midnight
+		{"23:59:50", time.Date(2026, time.October, 17, 23, 59, 50, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := parseTime(tt.clock, now); !got.Equal(tt.want) {
+			t.Errorf("parseTime(%s) = %s, want %s", tt.clock, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package serverlog
+
+import "sync"
+
+// how many events a subscriber can fall behind before it holds up the rest
+const subscriberBuffer = 64
+
+// Feed fans events from a single tail out to everything that wants them
+type Feed struct {
+	mu          sync.Mutex
+	subscribers []chan Event
+}
+
+// Subscribe returns a channel that gets every event, subscribe before
+// calling Run
+func (f *Feed) Subscribe() <-chan Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subscriber := make(chan Event, subscriberBuffer)
+	f.subscribers = append(f.subscribers, subscriber)
+	return subscriber
+}
+
+// Subscribed reports whether anything has subscribed
+func (f *Feed) Subscribed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subscribers) > 0
+}
+
+// Run sends events to every subscriber, closing their channels once events
+// is closed
+func (f *Feed) Run(events <-chan Event) {
+	f.mu.Lock()
+	subscribers := f.subscribers
+	f.mu.Unlock()
+
+	defer func() {
+		for _, subscriber := range subscribers {
+			close(subscriber)
+		}
+	}()
+	for event := range events {
+		for _, subscriber := range subscribers {
+			subscriber <- event
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package serverlog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Source is somewhere the server's latest.log can be followed from
+type Source interface {
+	// Lines sends each line appended to the log, without its newline, until
+	// ctx is done. lines already in the log are skipped.
+	Lines(ctx context.Context, lines chan<- string) error
+}
+
+const defaultPollInterval = time.Second
+
+// File follows a log on the local filesystem like tail -F, picking the new
+// file up when the server rotates latest.log on start or at midnight
+type File struct {
+	Path string
+	// how often to check for new lines, defaults to a second
+	PollInterval time.Duration
+}
+
+func (f *File) Lines(ctx context.Context, lines chan<- string) error {
+	interval := f.PollInterval
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var current *followedFile
+	defer func() {
+		if current != nil {
+			current.file.Close()
+		}
+	}()
+
+	// only the file that's there when we start is read from the end, a
+	// rotated in file is all new
+	fromEnd := true
+	for {
+		if current == nil {
+			var err error
+			current, err = openFollowedFile(f.Path, fromEnd)
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("error opening server log %s: %s", f.Path, err.Error())
+			}
+			fromEnd = false
+		}
+
+		if current != nil {
+			err := current.readLines(ctx, lines)
+			if err != nil {
+				return err
+			}
+
+			info, err := os.Stat(f.Path)
+			switch {
+			case os.IsNotExist(err):
+				// mid rotation, the new file will turn up
+			case err != nil:
+				log.Printf("error checking server log %s: %s", f.Path, err.Error())
+			case !os.SameFile(info, current.info):
+				// everything in the old file has been read, move on
+				current.file.Close()
+				current = nil
+				continue
+			case info.Size() < current.offset:
+				// truncated in place
+				_, err = current.file.Seek(0, io.SeekStart)
+				if err != nil {
+					log.Printf("error rewinding server log %s: %s", f.Path, err.Error())
+				}
+				current.reset(0)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type followedFile struct {
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64
+	partial []byte
+}
+
+func openFollowedFile(path string, fromEnd bool) (*followedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f := &followedFile{file: file, info: info}
+	var offset int64
+	if fromEnd {
+		offset, err = file.Seek(0, io.SeekEnd)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	f.reset(offset)
+	return f, nil
+}
+
+func (f *followedFile) reset(offset int64) {
+	f.reader = bufio.NewReader(f.file)
+	f.offset = offset
+	f.partial = nil
+}
+
+// readLines sends every complete line up to the end of the file, holding on
+// to a line that's still being written
+func (f *followedFile) readLines(ctx context.Context, lines chan<- string) error {
+	for {
+		chunk, err := f.reader.ReadBytes('\n')
+		f.offset += int64(len(chunk))
+		f.partial = append(f.partial, chunk...)
+		if err != nil {
+			return nil
+		}
+
+		line := string(trimNewline(f.partial))
+		f.partial = nil
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func trimNewline(line []byte) []byte {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+const (
+	minStreamBackoff = time.Second
+	maxStreamBackoff = time.Minute
+)
+
+// Stream follows a log from somewhere else, e.g. tail -F over ssh. Open is
+// called again with backoff whenever the stream ends.
+type Stream struct {
+	Open func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// Command streams the output of a command that follows the log, e.g.
+// Command("ssh", "minecraft", "tail", "-F", "-n", "0", "logs/latest.log")
+func Command(name string, args ...string) *Stream {
+	return &Stream{
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			cmd := exec.CommandContext(ctx, name, args...)
+			cmd.Stderr = os.Stderr
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				return nil, err
+			}
+			err = cmd.Start()
+			if err != nil {
+				return nil, err
+			}
+			return &commandOutput{ReadCloser: stdout, cmd: cmd}, nil
+		},
+	}
+}
+
+type commandOutput struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandOutput) Close() error {
+	c.ReadCloser.Close()
+	c.cmd.Process.Kill()
+	return c.cmd.Wait()
+}
+
+func (s *Stream) Lines(ctx context.Context, lines chan<- string) error {
+	backoff := minStreamBackoff
+	for {
+		started := time.Now()
+		err := s.stream(ctx, lines)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("error streaming server log: %s", err.Error())
+		}
+
+		// a stream that stayed up for a while was healthy, start over
+		if time.Since(started) > maxStreamBackoff {
+			backoff = minStreamBackoff
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > maxStreamBackoff {
+			backoff = maxStreamBackoff
+		}
+	}
+}
+
+func (s *Stream) stream(ctx context.Context, lines chan<- string) error {
+	reader, err := s.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// unblocks the scanner when ctx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			reader.Close()
+		case <-stop:
+		}
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		select {
+		case lines <- string(trimNewline(scanner.Bytes())):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,124 @@
+package serverlog
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for _, line := range lines {
+		_, err = file.WriteString(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func receiveLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, expected := range want {
+		select {
+		case got := <-lines:
+			if got != expected {
+				t.Fatalf("got line %q, want %q", got, expected)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestFileFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "latest.log")
+	appendLines(t, path, "from before we started\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string)
+	source := &File{Path: path, PollInterval: 10 * time.Millisecond}
+	go source.Lines(ctx, lines)
+
+	// give the source a moment to open the file and skip to the end
+	time.Sleep(50 * time.Millisecond)
+	appendLines(t, path, "first\n", "half a ")
+	time.Sleep(30 * time.Millisecond)
+	appendLines(t, path, "line\n")
+	receiveLines(t, lines, "first", "half a line")
+
+	// the server compresses latest.log away and starts a new one
+	appendLines(t, path, "last line before rotating\n")
+	err := os.Rename(path, filepath.Join(dir, "2026-10-18-1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "after rotating\n")
+	receiveLines(t, lines, "last line before rotating", "after rotating")
+
+	// truncated in place, which is only noticed once the file is shorter
+	// than what was already read
+	err = os.WriteFile(path, []byte("short\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiveLines(t, lines, "short")
+}
+
+func TestStreamReconnects(t *testing.T) {
+	source := &Stream{
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("streamed line\r\n")), nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lines := make(chan string)
+	done := make(chan error)
+	go func() { done <- source.Lines(ctx, lines) }()
+
+	receiveLines(t, lines, "streamed line", "streamed line")
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestTail(t *testing.T) {
+	source := &Stream{
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(strings.Join([]string{
+				"[18:02:13] [Server thread/INFO]: Loading properties",
+				"[18:05:42] [Server thread/INFO]: bsdlp joined the game",
+				"[18:06:01] [Server thread/INFO]: <bsdlp> hello froggies",
+			}, "\n"))), nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := Tail(ctx, source)
+
+	join, ok := (<-events).(*Join)
+	if !ok || join.Player != "bsdlp" {
+		t.Fatalf("got %+v, want bsdlp joining", join)
+	}
+	chat, ok := (<-events).(*Chat)
+	if !ok || chat.Message != "hello froggies" {
+		t.Fatalf("got %+v, want bsdlp's chat", chat)
+	}
+
+	cancel()
+	for range events {
+	}
+}
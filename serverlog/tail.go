@@ -0,0 +1,37 @@
+package serverlog
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Tail parses lines as they're appended to the log at source into events.
+// the channel is closed once ctx is done.
+func Tail(ctx context.Context, source Source) <-chan Event {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		err := source.Lines(ctx, lines)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("error following server log: %s", err.Error())
+		}
+	}()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for line := range lines {
+			event := ParseLine(line, time.Now())
+			if event == nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				// keep draining so the source can see ctx is done
+			}
+		}
+	}()
+	return events
+}
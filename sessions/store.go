@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"github.com/tonkat-su/bot/filestore"
+)
+
+// finished sessions are kept this long
+const retention = 90 * 24 * time.Hour
+
+// Session is one stretch of a player being online
+type Session struct {
+	Player string
+	// PlayerId is the player's uuid, empty until it can be looked up
+	PlayerId string
+	Start    time.Time
+	// End is zero while the player is still online
+	End time.Time
+	// Credited is how far the session's minutes have been added to the
+	// leaderboard
+	Credited time.Time
+}
+
+// Duration is how long the session lasted, or has lasted so far
+func (s *Session) Duration(now time.Time) time.Duration {
+	if !s.End.IsZero() {
+		now = s.End
+	}
+	return now.Sub(s.Start)
+}
+
+// Uncredited is how many minutes of a finished session still have to be
+// added to the leaderboard
+func (s *Session) Uncredited() time.Duration {
+	if s.End.IsZero() {
+		return 0
+	}
+	return s.End.Sub(s.Credited).Round(time.Minute)
+}
+
+// State is every open session keyed by lowercased name, and the sessions
+// that finished recently, oldest first
+type State struct {
+	Open     map[string]*Session
+	Finished []*Session
+	// Checked is when who's online was last checked
+	Checked time.Time `json:",omitempty"`
+}
+
+// FileStore keeps sessions in a json file so open ones survive restarts
+type FileStore struct {
+	file *filestore.File
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{file: filestore.New(path)}
+}
+
+func (store *FileStore) Load(ctx context.Context) (*State, error) {
+	state := &State{}
+	err := store.file.Load(state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Open == nil {
+		state.Open = make(map[string]*Session)
+	}
+	return state, nil
+}
+
+// Save replaces the stored state, dropping finished sessions older than the
+// retention
+func (store *FileStore) Save(ctx context.Context, state *State) error {
+	prune(state)
+	return store.file.Save(state)
+}
+
+// Update loads the stored state, calls fn to change it and saves it if fn
+// doesn't return an error, keeping other processes out in between
+func (store *FileStore) Update(ctx context.Context, fn func(state *State) error) error {
+	state := &State{}
+	return store.file.Update(state, func() error {
+		if state.Open == nil {
+			state.Open = make(map[string]*Session)
+		}
+		err := fn(state)
+		if err != nil {
+			return err
+		}
+		prune(state)
+		return nil
+	})
+}
+
+func prune(state *State) {
+	cutoff := time.Now().Add(-retention)
+	i := 0
+	for i < len(state.Finished) && state.Finished[i].End.Before(cutoff) {
+		i++
+	}
+	state.Finished = state.Finished[i:]
+}
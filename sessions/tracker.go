@@ -0,0 +1,305 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tonkat-su/bot/leaderboard"
+	"github.com/tonkat-su/bot/mclookup"
+	"github.com/tonkat-su/bot/mcquery"
+	"github.com/tonkat-su/bot/mcuser"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+// a step more than this many intervals after the last one can't tell who
+// was on in between, see Step
+const maxMissedSteps = 2
+
+type Config struct {
+	Host string
+	// QueryPort is optional, see mcquery.Players
+	QueryPort int
+	// FloodgatePrefix is in front of bedrock players' names, see
+	// mcquery.IsPlayerName
+	FloodgatePrefix string
+	// Interval is how often open sessions are credited and checked against
+	// who's online
+	Interval time.Duration
+}
+
+// Tracker turns joins and leaves into sessions, crediting the minutes played
+// to the leaderboard as they go
+type Tracker struct {
+	store *FileStore
+	board *leaderboard.Service
+	cfg   *Config
+
+	// swapped out in tests
+	online     func(ctx context.Context) (players []*mcquery.Player, complete bool, err error)
+	lookupUuid func(name string) (string, error)
+
+	state *State
+}
+
+func NewTracker(store *FileStore, board *leaderboard.Service, cfg *Config) *Tracker {
+	t := &Tracker{
+		store:      store,
+		board:      board,
+		cfg:        cfg,
+		lookupUuid: mcuser.GetUuid,
+	}
+	t.online = t.queryOnline
+	return t
+}
+
+func (t *Tracker) queryOnline(ctx context.Context) ([]*mcquery.Player, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	server, pong, err := mclookup.Ping(ctx, t.cfg.Host)
+	if err != nil {
+		return nil, false, err
+	}
+	players, complete := mcquery.Players(ctx, mcquery.QueryHostport(server.Host, t.cfg.QueryPort), pong)
+	return players, complete, nil
+}
+
+// Run follows joins and leaves from events, which is nil if the server log
+// isn't followed, and checks who's online every interval until ctx is done.
+// without the log, sessions start and end when the server's player list
+// changes, which needs query or a server small enough for the ping sample.
+func (t *Tracker) Run(ctx context.Context, events <-chan serverlog.Event) {
+	t.load(ctx)
+
+	ticker := time.NewTicker(t.cfg.Interval)
+	defer ticker.Stop()
+	t.check(ctx, time.Now())
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			t.handle(ctx, event)
+		case <-ticker.C:
+			t.check(ctx, time.Now())
+		case <-ctx.Done():
+			// credit what's been played so far, whoever is still online
+			// starts a new session after a restart
+			t.creditOpen(context.Background(), time.Now())
+			t.save(context.Background())
+			return
+		}
+	}
+}
+
+// load picks up where the last run left off. nothing says what happened
+// while the tracker wasn't running, so open sessions end where they were
+// last credited rather than risk counting time nobody played.
+func (t *Tracker) load(ctx context.Context) {
+	state, err := t.store.Load(ctx)
+	if err != nil {
+		log.Printf("error loading sessions, starting over: %s", err.Error())
+		state = &State{Open: make(map[string]*Session)}
+	}
+	t.state = state
+
+	for key, session := range t.state.Open {
+		t.close(ctx, key, session.Credited)
+	}
+	t.save(ctx)
+}
+
+func (t *Tracker) handle(ctx context.Context, event serverlog.Event) {
+	switch e := event.(type) {
+	case *serverlog.Join:
+		t.open(e.Player, "", e.Time)
+	case *serverlog.Leave:
+		t.close(ctx, strings.ToLower(e.Player), e.Time)
+	case *serverlog.ServerStarting, *serverlog.ServerStopping:
+		// a server that crashed never logged anyone leaving
+		for key := range t.state.Open {
+			t.close(ctx, key, event.At())
+		}
+	default:
+		return
+	}
+	t.save(ctx)
+}
+
+// Step checks who's online once, for deployments that can't keep a tracker
+// running, like a scheduled lambda. it should be called every interval, the
+// state is kept in the store between steps. sessions left open by a step
+// longer ago than that end where they were last credited, like after a
+// restart.
+func (t *Tracker) Step(ctx context.Context, now time.Time) error {
+	return t.store.Update(ctx, func(state *State) error {
+		t.state = state
+		if now.Sub(state.Checked) > maxMissedSteps*t.cfg.Interval {
+			for key, session := range t.state.Open {
+				t.close(ctx, key, session.Credited)
+			}
+		}
+		return t.follow(ctx, now)
+	})
+}
+
+// check credits open sessions up to now, and if the server lists everyone
+// online, starts and ends sessions to match
+func (t *Tracker) check(ctx context.Context, now time.Time) {
+	err := t.follow(ctx, now)
+	if err != nil {
+		// the gap is credited once the server answers, if players are still on
+		log.Printf("error checking who's online for sessions: %s", err.Error())
+		return
+	}
+	t.save(ctx)
+}
+
+// follow is check without saving
+func (t *Tracker) follow(ctx context.Context, now time.Time) error {
+	players, complete, err := t.online(ctx)
+	if err != nil {
+		return err
+	}
+	t.state.Checked = now
+
+	for _, p := range players {
+		// the ping sample knows uuids that couldn't be looked up, like those
+		// of bedrock players
+		if session, ok := t.state.Open[strings.ToLower(p.Name)]; ok && session.PlayerId == "" {
+			session.PlayerId = p.Uuid
+		}
+	}
+
+	if complete {
+		online := make(map[string]bool, len(players))
+		for _, p := range players {
+			if !mcquery.IsPlayerName(p.Name, t.cfg.FloodgatePrefix) {
+				continue
+			}
+			online[strings.ToLower(p.Name)] = true
+			t.open(p.Name, p.Uuid, now)
+		}
+		for key := range t.state.Open {
+			if !online[key] {
+				t.close(ctx, key, now)
+			}
+		}
+	}
+
+	t.creditOpen(ctx, now)
+	t.creditFinished(ctx)
+	return nil
+}
+
+func (t *Tracker) open(player, uuid string, at time.Time) {
+	key := strings.ToLower(player)
+	if _, ok := t.state.Open[key]; ok {
+		return
+	}
+
+	session := &Session{
+		Player:   player,
+		PlayerId: uuid,
+		Start:    at,
+		Credited: at,
+	}
+	t.resolve(session)
+	t.state.Open[key] = session
+}
+
+// resolve looks up the session's uuid if it isn't known yet, it's tried again
+// whenever the session would be credited
+func (t *Tracker) resolve(session *Session) {
+	if session.PlayerId != "" {
+		return
+	}
+	uuid, err := t.lookupUuid(session.Player)
+	if err != nil {
+		log.Printf("error looking up uuid for '%s', their session will be credited once it's found: %s", session.Player, err.Error())
+		return
+	}
+	session.PlayerId = uuid
+}
+
+func (t *Tracker) close(ctx context.Context, key string, at time.Time) {
+	session, ok := t.state.Open[key]
+	if !ok {
+		return
+	}
+	if at.Before(session.Start) {
+		at = session.Start
+	}
+	session.End = at
+	// whatever isn't credited now is retried by creditFinished
+	t.credit(ctx, session, at, true)
+
+	delete(t.state.Open, key)
+	t.state.Finished = append(t.state.Finished, session)
+}
+
+func (t *Tracker) creditOpen(ctx context.Context, now time.Time) {
+	for _, session := range t.state.Open {
+		t.credit(ctx, session, now, false)
+	}
+}
+
+// creditFinished retries the finished sessions that weren't fully credited,
+// because the leaderboard couldn't be saved to or the uuid looked up
+func (t *Tracker) creditFinished(ctx context.Context) {
+	for _, session := range t.state.Finished {
+		if session.Uncredited() > 0 {
+			t.credit(ctx, session, session.End, true)
+		}
+	}
+}
+
+// credit adds the whole minutes played since the session was last credited
+// to the leaderboard, rounding the last of them once the session is over.
+// scores are recorded at the time crediting started from, so a retry after a
+// failed save doesn't count the same minutes twice.
+func (t *Tracker) credit(ctx context.Context, session *Session, upTo time.Time, final bool) {
+	if !upTo.After(session.Credited) {
+		return
+	}
+	t.resolve(session)
+	if session.PlayerId == "" {
+		return
+	}
+
+	elapsed := upTo.Sub(session.Credited)
+	if final {
+		elapsed = elapsed.Round(time.Minute)
+	}
+	minutes := elapsed / time.Minute
+	if minutes == 0 {
+		return
+	}
+
+	err := t.board.RecordScores(ctx, &leaderboard.RecordScoresInput{
+		Scores: []*leaderboard.PlayerScore{
+			{
+				PlayerId: session.PlayerId,
+				Score:    int64(minutes),
+			},
+		},
+		Time: session.Credited,
+		Once: true,
+	})
+	if err != nil {
+		log.Printf("error crediting %s's session: %s", session.Player, err.Error())
+		return
+	}
+	session.Credited = session.Credited.Add(minutes * time.Minute)
+}
+
+func (t *Tracker) save(ctx context.Context) {
+	err := t.store.Save(ctx, t.state)
+	if err != nil {
+		log.Printf("error saving sessions: %s", err.Error())
+	}
+}
@@ -0,0 +1,256 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tonkat-su/bot/leaderboard"
+	"github.com/tonkat-su/bot/mcquery"
+	"github.com/tonkat-su/bot/serverlog"
+)
+
+const (
+	bsdlp = "a7ec7d80-a1f4-4d2c-9b6b-4ffb0e1f2f8d"
+	jcmp  = "3c2a3c71-53f4-4a4c-8fc5-2b2cbd6b6d3e"
+)
+
+// noon yesterday, recent enough that finished sessions are kept
+var start = time.Now().UTC().Truncate(24 * time.Hour).Add(-12 * time.Hour)
+
+type fakeServer struct {
+	players  []*mcquery.Player
+	complete bool
+}
+
+// flakyScores fails to record scores while down is set
+type flakyScores struct {
+	*leaderboard.BoltStore
+	down bool
+}
+
+func (f *flakyScores) RecordScores(ctx context.Context, input *leaderboard.RecordScoresInput) error {
+	if f.down {
+		return errors.New("down")
+	}
+	return f.BoltStore.RecordScores(ctx, input)
+}
+
+func newTestTracker(t *testing.T, server *fakeServer) (*Tracker, *flakyScores) {
+	t.Helper()
+	dir := t.TempDir()
+	scores := &flakyScores{BoltStore: leaderboard.NewBoltStore(filepath.Join(dir, "leaderboard.db"))}
+	tracker := NewTracker(NewFileStore(filepath.Join(dir, "sessions.json")), leaderboard.NewService(scores), &Config{FloodgatePrefix: ".", Interval: 5 * time.Minute})
+	tracker.online = func(ctx context.Context) ([]*mcquery.Player, bool, error) {
+		return server.players, server.complete, nil
+	}
+	tracker.lookupUuid = func(name string) (string, error) {
+		return map[string]string{"bsdlp": bsdlp, "jcmp": jcmp}[name], nil
+	}
+	tracker.load(context.Background())
+	return tracker, scores
+}
+
+// minutesPlayed sums everyone's minutes on the day of start
+func minutesPlayed(t *testing.T, scores *flakyScores) map[string]int64 {
+	t.Helper()
+	day := start.Truncate(24 * time.Hour)
+	histories, err := scores.QueryScores(context.Background(), &leaderboard.QueryScoresInput{
+		Start:  day,
+		End:    day.Add(24 * time.Hour),
+		Period: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	minutes := make(map[string]int64)
+	for _, history := range histories {
+		minutes[history.PlayerId] = history.Total()
+	}
+	return minutes
+}
+
+func TestTrackerEvents(t *testing.T) {
+	ctx := context.Background()
+	tracker, scores := newTestTracker(t, &fakeServer{})
+
+	tracker.handle(ctx, &serverlog.Join{Time: start, Player: "bsdlp"})
+	tracker.check(ctx, start.Add(10*time.Minute+30*time.Second))
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 10 {
+		t.Fatalf("got %d minutes while still online, want 10", got)
+	}
+
+	tracker.handle(ctx, &serverlog.Leave{Time: start.Add(19*time.Minute + 40*time.Second), Player: "bsdlp"})
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 20 {
+		t.Fatalf("got %d minutes after leaving, want 20", got)
+	}
+	if len(tracker.state.Open) != 0 || len(tracker.state.Finished) != 1 {
+		t.Fatalf("got %d open and %d finished sessions, want 0 and 1", len(tracker.state.Open), len(tracker.state.Finished))
+	}
+	if got := tracker.state.Finished[0].Duration(time.Time{}); got != 19*time.Minute+40*time.Second {
+		t.Fatalf("got a %s session, want 19m40s", got)
+	}
+}
+
+func TestTrackerPlayerList(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{
+		players:  []*mcquery.Player{{Name: "bsdlp"}, {Name: "jcmp"}, {Name: "...and 5 more"}},
+		complete: true,
+	}
+	tracker, scores := newTestTracker(t, server)
+
+	tracker.check(ctx, start)
+	if len(tracker.state.Open) != 2 {
+		t.Fatalf("got %d open sessions, want 2", len(tracker.state.Open))
+	}
+
+	server.players = server.players[:1]
+	tracker.check(ctx, start.Add(5*time.Minute))
+
+	// an incomplete list can't end anyone's session
+	server.players, server.complete = nil, false
+	tracker.check(ctx, start.Add(10*time.Minute))
+
+	got := minutesPlayed(t, scores)
+	if got[bsdlp] != 10 || got[jcmp] != 5 {
+		t.Fatalf("got %v, want 10 minutes for bsdlp and 5 for jcmp", got)
+	}
+}
+
+func TestTrackerRetry(t *testing.T) {
+	ctx := context.Background()
+	tracker, scores := newTestTracker(t, &fakeServer{})
+
+	tracker.handle(ctx, &serverlog.Join{Time: start, Player: "bsdlp"})
+	session := *tracker.state.Open["bsdlp"]
+	tracker.creditOpen(ctx, start.Add(5*time.Minute))
+
+	// as if the session was never saved after being credited
+	tracker.state.Open["bsdlp"] = &session
+	tracker.creditOpen(ctx, start.Add(5*time.Minute))
+
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 5 {
+		t.Fatalf("got %d minutes, want 5", got)
+	}
+}
+
+func TestTrackerRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewFileStore(filepath.Join(dir, "sessions.json"))
+	err := store.Save(ctx, &State{Open: map[string]*Session{
+		"bsdlp": {Player: "bsdlp", PlayerId: bsdlp, Start: start, Credited: start.Add(15 * time.Minute)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewTracker(store, leaderboard.NewService(leaderboard.NewBoltStore(filepath.Join(dir, "leaderboard.db"))), &Config{Interval: 5 * time.Minute})
+	tracker.load(ctx)
+
+	state, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Open) != 0 || len(state.Finished) != 1 {
+		t.Fatalf("got %d open and %d finished sessions, want 0 and 1", len(state.Open), len(state.Finished))
+	}
+	if end := state.Finished[0].End; !end.Equal(start.Add(15 * time.Minute)) {
+		t.Fatalf("session ended at %s, want where it was last credited", end)
+	}
+}
+
+func TestTrackerCreditRetry(t *testing.T) {
+	ctx := context.Background()
+	tracker, scores := newTestTracker(t, &fakeServer{})
+
+	tracker.handle(ctx, &serverlog.Join{Time: start, Player: "bsdlp"})
+	scores.down = true
+	tracker.handle(ctx, &serverlog.Leave{Time: start.Add(12 * time.Minute), Player: "bsdlp"})
+	tracker.check(ctx, start.Add(15*time.Minute))
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 0 {
+		t.Fatalf("got %d minutes while the leaderboard is down, want 0", got)
+	}
+
+	scores.down = false
+	tracker.check(ctx, start.Add(20*time.Minute))
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 12 {
+		t.Fatalf("got %d minutes once the leaderboard is back, want 12", got)
+	}
+	tracker.check(ctx, start.Add(25*time.Minute))
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 12 {
+		t.Fatalf("got %d minutes after another check, want 12 still", got)
+	}
+}
+
+func TestTrackerLookupRetry(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	tracker, scores := newTestTracker(t, server)
+	lookups := 0
+	tracker.lookupUuid = func(name string) (string, error) {
+		lookups++
+		if lookups == 1 {
+			return "", errors.New("mojang is down")
+		}
+		return bsdlp, nil
+	}
+
+	tracker.handle(ctx, &serverlog.Join{Time: start, Player: "bsdlp"})
+	tracker.check(ctx, start.Add(10*time.Minute))
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 10 {
+		t.Fatalf("got %d minutes, want the lookup retried and 10 credited", got)
+	}
+
+	// bedrock players can't be looked up, but are in the ping sample
+	tracker.lookupUuid = func(name string) (string, error) {
+		return "", errors.New("not found")
+	}
+	tracker.handle(ctx, &serverlog.Join{Time: start, Player: ".jcmp"})
+	server.players = []*mcquery.Player{{Name: ".jcmp", Uuid: jcmp}}
+	tracker.check(ctx, start.Add(5*time.Minute))
+	if got := minutesPlayed(t, scores)[jcmp]; got != 5 {
+		t.Fatalf("got %d minutes, want 5 credited to the uuid from the ping sample", got)
+	}
+}
+
+func TestTrackerStep(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{players: []*mcquery.Player{{Name: "bsdlp"}}, complete: true}
+	tracker, scores := newTestTracker(t, server)
+	step := func(at time.Duration) {
+		t.Helper()
+		err := tracker.Step(ctx, start.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step(0)
+	step(5 * time.Minute)
+	server.players = nil
+	step(10 * time.Minute)
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 10 {
+		t.Fatalf("got %d minutes after leaving, want 10", got)
+	}
+
+	// the steps in between were missed, so nobody knows if bsdlp stayed on
+	server.players = []*mcquery.Player{{Name: "bsdlp"}}
+	step(20 * time.Minute)
+	step(40 * time.Minute)
+	step(45 * time.Minute)
+	if got := minutesPlayed(t, scores)[bsdlp]; got != 15 {
+		t.Fatalf("got %d minutes, want 15 without the missed steps", got)
+	}
+
+	state, err := tracker.store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Open) != 1 || len(state.Finished) != 2 || !state.Checked.Equal(start.Add(45*time.Minute)) {
+		t.Fatalf("got %d open and %d finished sessions checked at %s, want 1 and 2 checked at the last step", len(state.Open), len(state.Finished), state.Checked)
+	}
+}
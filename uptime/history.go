@@ -0,0 +1,184 @@
+package uptime
+
+import (
+	"context"
+	"time"
+
+	"github.com/tonkat-su/bot/filestore"
+)
+
+// outages and monitoring that ended longer ago than this are forgotten
+const retention = 90 * 24 * time.Hour
+
+// Outage is a stretch of time the server couldn't be reached, End is zero
+// while it's still down
+type Outage struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration is how long the outage lasted, or has lasted so far
+func (o *Outage) Duration(now time.Time) time.Duration {
+	if o.End.IsZero() {
+		return now.Sub(o.Start)
+	}
+	return o.End.Sub(o.Start)
+}
+
+// Span is a stretch of time the monitor was running, End is its last check
+type Span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// History is everything the monitor has recorded
+type History struct {
+	// Monitored are the stretches of time the monitor was running, oldest
+	// first. nothing is known about the gaps between them.
+	Monitored []*Span
+	Outages   []*Outage
+}
+
+// Since is when monitoring first started, zero if it never has
+func (h *History) Since() time.Time {
+	if len(h.Monitored) == 0 {
+		return time.Time{}
+	}
+	return h.Monitored[0].Start
+}
+
+// Ongoing returns the outage the server is still in, if it's down
+func (h *History) Ongoing() *Outage {
+	if len(h.Outages) == 0 {
+		return nil
+	}
+	last := h.Outages[len(h.Outages)-1]
+	if !last.End.IsZero() {
+		return nil
+	}
+	return last
+}
+
+// Availability returns the fraction of the monitored time between from and to
+// that the server was up, along with how much time was monitored. time the
+// monitor wasn't running counts as neither up nor down.
+func (h *History) Availability(from, to time.Time) (float64, time.Duration) {
+	var observed, down time.Duration
+	for _, span := range h.Monitored {
+		start, end := clip(span.Start, span.End, from, to)
+		if !end.After(start) {
+			continue
+		}
+		observed += end.Sub(start)
+
+		for _, outage := range h.Outages {
+			outageEnd := outage.End
+			if outageEnd.IsZero() {
+				outageEnd = to
+			}
+			downStart, downEnd := clip(outage.Start, outageEnd, start, end)
+			if downEnd.After(downStart) {
+				down += downEnd.Sub(downStart)
+			}
+		}
+	}
+	if observed <= 0 {
+		return 1, 0
+	}
+	return 1 - float64(down)/float64(observed), observed
+}
+
+// clip limits start and end to between from and to
+func clip(start, end, from, to time.Time) (time.Time, time.Time) {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	return start, end
+}
+
+// Store persists the monitor's history so it survives restarts
+type Store interface {
+	History(ctx context.Context) (*History, error)
+	// Checked records that the monitor was running at, extending the latest
+	// span if it ended no more than gap before
+	Checked(ctx context.Context, at time.Time, gap time.Duration) error
+	// OutageStarted opens a new outage unless one is already ongoing
+	OutageStarted(ctx context.Context, at time.Time) error
+	// OutageEnded closes the ongoing outage and returns it, or nil if there
+	// wasn't one
+	OutageEnded(ctx context.Context, at time.Time) (*Outage, error)
+}
+
+// FileStore keeps the history in a json file
+type FileStore struct {
+	file *filestore.File
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{file: filestore.New(path)}
+}
+
+func (store *FileStore) History(ctx context.Context) (*History, error) {
+	history := &History{}
+	err := store.file.Load(history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (store *FileStore) Checked(ctx context.Context, at time.Time, gap time.Duration) error {
+	history := &History{}
+	return store.file.Update(history, func() error {
+		if n := len(history.Monitored); n > 0 && at.Sub(history.Monitored[n-1].End) <= gap {
+			history.Monitored[n-1].End = at
+			return nil
+		}
+
+		kept := []*Span{}
+		for _, span := range history.Monitored {
+			if at.Sub(span.End) < retention {
+				kept = append(kept, span)
+			}
+		}
+		history.Monitored = append(kept, &Span{Start: at, End: at})
+		return nil
+	})
+}
+
+func (store *FileStore) OutageStarted(ctx context.Context, at time.Time) error {
+	history := &History{}
+	return store.file.Update(history, func() error {
+		if history.Ongoing() != nil {
+			return nil
+		}
+
+		kept := []*Outage{}
+		for _, outage := range history.Outages {
+			if at.Sub(outage.End) < retention {
+				kept = append(kept, outage)
+			}
+		}
+		history.Outages = append(kept, &Outage{Start: at})
+		return nil
+	})
+}
+
+func (store *FileStore) OutageEnded(ctx context.Context, at time.Time) (*Outage, error) {
+	history := &History{}
+	var ended *Outage
+	err := store.file.Update(history, func() error {
+		ended = history.Ongoing()
+		if ended != nil {
+			ended.End = at
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ended, nil
+}
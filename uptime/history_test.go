@@ -0,0 +1,131 @@
+package uptime
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return start.Add(time.Duration(minutes) * time.Minute)
+}
+
+func TestAvailability(t *testing.T) {
+	tests := []struct {
+		name         string
+		history      *History
+		from, to     int
+		availability float64
+		observed     time.Duration
+	}{
+		{
+			name:         "never monitored",
+			history:      &History{},
+			from:         0,
+			to:           100,
+			availability: 1,
+		},
+		{
+			name:         "up the whole time",
+			history:      &History{Monitored: []*Span{{at(0), at(100)}}},
+			from:         0,
+			to:           100,
+			availability: 1,
+			observed:     100 * time.Minute,
+		},
+		{
+			name: "one outage",
+			history: &History{
+				Monitored: []*Span{{at(0), at(100)}},
+				Outages:   []*Outage{{at(10), at(20)}},
+			},
+			from:         0,
+			to:           100,
+			availability: 0.9,
+			observed:     100 * time.Minute,
+		},
+		{
+			name: "ongoing outage",
+			history: &History{
+				Monitored: []*Span{{at(0), at(100)}},
+				Outages:   []*Outage{{Start: at(75)}},
+			},
+			from:         0,
+			to:           100,
+			availability: 0.75,
+			observed:     100 * time.Minute,
+		},
+		{
+			name: "outage before the period",
+			history: &History{
+				Monitored: []*Span{{at(0), at(100)}},
+				Outages:   []*Outage{{at(10), at(60)}},
+			},
+			from:         50,
+			to:           100,
+			availability: 0.8,
+			observed:     50 * time.Minute,
+		},
+		{
+			name: "the bot wasn't running",
+			history: &History{
+				Monitored: []*Span{{at(0), at(20)}, {at(60), at(100)}},
+				Outages:   []*Outage{{at(10), at(70)}},
+			},
+			from: 0,
+			to:   100,
+			// only 10 minutes on either side of the gap were seen down
+			availability: 40.0 / 60.0,
+			observed:     60 * time.Minute,
+		},
+		{
+			name: "monitoring started during the period",
+			history: &History{
+				Monitored: []*Span{{at(80), at(100)}},
+			},
+			from:         0,
+			to:           100,
+			availability: 1,
+			observed:     20 * time.Minute,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			availability, observed := test.history.Availability(at(test.from), at(test.to))
+			if diff := availability - test.availability; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("got availability %f, want %f", availability, test.availability)
+			}
+			if observed != test.observed {
+				t.Errorf("got %s observed, want %s", observed, test.observed)
+			}
+		})
+	}
+}
+
+func TestChecked(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(filepath.Join(t.TempDir(), "uptime.json"))
+	for _, minute := range []int{0, 1, 2, 4, 30, 31} {
+		err := store.Checked(ctx, at(minute), 2*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := store.History(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Span{{at(0), at(4)}, {at(30), at(31)}}
+	if len(history.Monitored) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(history.Monitored), len(want))
+	}
+	for i, span := range history.Monitored {
+		if !span.Start.Equal(want[i].Start) || !span.End.Equal(want[i].End) {
+			t.Errorf("span %d is %s to %s, want %s to %s", i, span.Start, span.End, want[i].Start, want[i].End)
+		}
+	}
+}
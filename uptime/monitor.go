@@ -0,0 +1,215 @@
+package uptime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tonkat-su/bot/mclookup"
+)
+
+const pingTimeout = 10 * time.Second
+
+type Config struct {
+	Host       string
+	ServerName string
+	Interval   time.Duration
+
+	// how many pings in a row have to fail before the server counts as down,
+	// and succeed before it counts as back up, so one dropped ping isn't an
+	// outage
+	FailureThreshold  int
+	RecoveryThreshold int
+
+	// ChannelId is optional, alerts are only posted if it's set
+	ChannelId string
+	// MentionRoleId is optional, the role is mentioned when the server goes down
+	MentionRoleId string
+}
+
+// Monitor pings the server on a schedule, records outages and alerts when
+// the server goes down or comes back
+type Monitor struct {
+	session *discordgo.Session
+	store   Store
+	cfg     *Config
+
+	// swapped out in tests
+	ping func(ctx context.Context, host string) error
+}
+
+func NewMonitor(session *discordgo.Session, store Store, cfg *Config) *Monitor {
+	return &Monitor{
+		session: session,
+		store:   store,
+		cfg:     cfg,
+		ping:    ping,
+	}
+}
+
+// Run monitors the server until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	t := &tracker{
+		failureThreshold:  m.cfg.FailureThreshold,
+		recoveryThreshold: m.cfg.RecoveryThreshold,
+	}
+	// pick up where we left off if the server was down when we last stopped
+	history, err := m.store.History(ctx)
+	if err != nil {
+		log.Printf("error loading uptime history: %s", err.Error())
+	} else if history.Ongoing() != nil {
+		t.down = true
+	}
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx, t, time.Now())
+		if ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check pings the server once, recording the check and alerting if it
+// tipped the server's state over
+func (m *Monitor) check(ctx context.Context, t *tracker, at time.Time) {
+	pingErr := m.ping(ctx, m.cfg.Host)
+	if ctx.Err() != nil {
+		return
+	}
+	if pingErr != nil {
+		log.Printf("error pinging server '%s' for uptime: %s", m.cfg.Host, pingErr.Error())
+	}
+	// a missed check or two is still monitoring, anything longer is a
+	// gap where the bot wasn't running
+	err := m.store.Checked(ctx, at, 2*m.cfg.Interval+pingTimeout)
+	if err != nil {
+		log.Printf("error recording uptime check: %s", err.Error())
+	}
+
+	if changed, since := t.observe(pingErr == nil, at); changed {
+		if t.down {
+			m.wentDown(ctx, since)
+		} else {
+			m.cameUp(ctx, since)
+		}
+	}
+}
+
+func (m *Monitor) wentDown(ctx context.Context, since time.Time) {
+	err := m.store.OutageStarted(ctx, since)
+	if err != nil {
+		log.Printf("error recording outage: %s", err.Error())
+	}
+
+	message := &discordgo.MessageSend{
+		Content:         fmt.Sprintf("🔴 **%s** is down", m.cfg.ServerName),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+	if m.cfg.MentionRoleId != "" {
+		message.Content = fmt.Sprintf("<@&%s> %s", m.cfg.MentionRoleId, message.Content)
+		message.AllowedMentions.Roles = []string{m.cfg.MentionRoleId}
+	}
+	m.alert(message)
+}
+
+func (m *Monitor) cameUp(ctx context.Context, since time.Time) {
+	outage, err := m.store.OutageEnded(ctx, since)
+	if err != nil {
+		log.Printf("error recording end of outage: %s", err.Error())
+	}
+
+	content := fmt.Sprintf("🟢 **%s** is back up", m.cfg.ServerName)
+	if outage != nil {
+		content += " after " + FormatDuration(outage.Duration(since))
+	}
+	m.alert(&discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+}
+
+func (m *Monitor) alert(message *discordgo.MessageSend) {
+	if m.cfg.ChannelId == "" {
+		return
+	}
+	_, err := m.session.ChannelMessageSendComplex(m.cfg.ChannelId, message)
+	if err != nil {
+		log.Printf("error posting uptime alert: %s", err.Error())
+	}
+}
+
+// tracker decides when the server changes state, ignoring runs of pings
+// shorter than the thresholds
+type tracker struct {
+	failureThreshold  int
+	recoveryThreshold int
+
+	down bool
+	// pings in a row that disagree with the current state, and when the first
+	// of them was
+	streak      int
+	streakStart time.Time
+}
+
+// observe records a ping and reports whether it tipped the state over,
+// along with when the new state began
+func (t *tracker) observe(up bool, at time.Time) (bool, time.Time) {
+	if up != t.down {
+		t.streak = 0
+		return false, time.Time{}
+	}
+
+	if t.streak == 0 {
+		t.streakStart = at
+	}
+	t.streak++
+
+	threshold := t.failureThreshold
+	if t.down {
+		threshold = t.recoveryThreshold
+	}
+	if t.streak < threshold {
+		return false, time.Time{}
+	}
+
+	t.down = !t.down
+	t.streak = 0
+	return true, t.streakStart
+}
+
+func ping(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	_, _, err := mclookup.Ping(ctx, host)
+	return err
+}
+
+// FormatDuration rounds d to the minute for people to read, e.g. "2h5m"
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
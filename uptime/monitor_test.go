@@ -0,0 +1,130 @@
+package uptime
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackerObserve(t *testing.T) {
+	type ping struct {
+		up bool
+		// changed and since are what observe should report
+		changed bool
+		since   int
+	}
+	tests := []struct {
+		name  string
+		down  bool
+		pings []ping
+	}{
+		{
+			name: "one dropped ping isn't an outage",
+			pings: []ping{
+				{up: true},
+				{up: false},
+				{up: true},
+				{up: false},
+				{up: false},
+			},
+		},
+		{
+			name: "goes down from the first failure",
+			pings: []ping{
+				{up: true},
+				{up: false},
+				{up: false},
+				{up: false, changed: true, since: 1},
+				{up: false},
+			},
+		},
+		{
+			name: "a success resets the failures",
+			pings: []ping{
+				{up: false},
+				{up: false},
+				{up: true},
+				{up: false},
+				{up: false},
+				{up: false, changed: true, since: 3},
+			},
+		},
+		{
+			name: "comes back up after the recovery threshold",
+			down: true,
+			pings: []ping{
+				{up: true},
+				{up: false},
+				{up: true},
+				{up: true, changed: true, since: 2},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &tracker{failureThreshold: 3, recoveryThreshold: 2, down: test.down}
+			for i, p := range test.pings {
+				changed, since := tr.observe(p.up, at(i))
+				if changed != p.changed {
+					t.Fatalf("ping %d: got changed %t, want %t", i, changed, p.changed)
+				}
+				if changed && !since.Equal(at(p.since)) {
+					t.Fatalf("ping %d: got since %s, want %s", i, since, at(p.since))
+				}
+			}
+		})
+	}
+}
+
+func TestMonitorCheck(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(filepath.Join(t.TempDir(), "uptime.json"))
+	m := NewMonitor(nil, store, &Config{Interval: time.Minute, FailureThreshold: 2, RecoveryThreshold: 1})
+	tr := &tracker{failureThreshold: 2, recoveryThreshold: 1}
+
+	// the check is recorded fine, which mustn't hide the failed ping
+	m.ping = func(ctx context.Context, host string) error {
+		return errors.New("connection refused")
+	}
+	m.check(ctx, tr, at(0))
+	m.check(ctx, tr, at(1))
+	if !tr.down {
+		t.Fatal("want the server down after two failed pings")
+	}
+	history, err := store.History(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ongoing := history.Ongoing(); ongoing == nil || !ongoing.Start.Equal(at(0)) {
+		t.Fatalf("got ongoing outage %v, want one from the first failed ping", ongoing)
+	}
+
+	m.ping = func(ctx context.Context, host string) error {
+		return nil
+	}
+	m.check(ctx, tr, at(2))
+	history, err = store.History(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.down || history.Ongoing() != nil || len(history.Outages) != 1 {
+		t.Fatalf("got down %t with %d outages, want the outage ended", tr.down, len(history.Outages))
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		20 * time.Second:                "less than a minute",
+		5 * time.Minute:                 "5m",
+		2*time.Hour + 5*time.Minute:     "2h5m",
+		3*24*time.Hour + 4*time.Hour:    "3d4h",
+		59*time.Minute + 40*time.Second: "1h0m",
+	}
+	for d, want := range tests {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
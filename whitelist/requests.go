@@ -0,0 +1,189 @@
+package whitelist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tonkat-su/bot/filestore"
+)
+
+var (
+	ErrNotFound       = errors.New("whitelist: request not found")
+	ErrAlreadyPending = errors.New("whitelist: a request for that player is already pending")
+	ErrNotPending     = errors.New("whitelist: request isn't pending")
+)
+
+// a claim older than this was left by a decision that never finished
+const claimTimeout = 5 * time.Minute
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	// StatusDeciding is while a moderator's decision is being carried out
+	StatusDeciding Status = "deciding"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+)
+
+// Request is a member asking for a minecraft account to be whitelisted
+type Request struct {
+	Id            string
+	DiscordUserId string
+	MinecraftName string
+	MinecraftUuid string
+	RequestedAt   time.Time
+
+	// the approval card posted to the moderator channel
+	CardChannelId string
+	CardMessageId string
+
+	Status    Status
+	ClaimedAt time.Time
+	DecidedBy string
+	DecidedAt time.Time
+}
+
+func NewRequest(discordUserId, minecraftName, minecraftUuid string) (*Request, error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{
+		Id:            hex.EncodeToString(id),
+		DiscordUserId: discordUserId,
+		MinecraftName: minecraftName,
+		MinecraftUuid: minecraftUuid,
+		RequestedAt:   time.Now(),
+		Status:        StatusPending,
+	}, nil
+}
+
+// open is whether the request is still waiting on a decision
+func (r *Request) open() bool {
+	return r.Status == StatusPending || r.Status == StatusDeciding
+}
+
+// Store persists requests so pending ones survive restarts
+type Store interface {
+	Get(ctx context.Context, id string) (*Request, error)
+	// Pending lists the requests still waiting on a decision
+	Pending(ctx context.Context) ([]*Request, error)
+	// Create fails with ErrAlreadyPending if the player already has a pending request
+	Create(ctx context.Context, request *Request) error
+	// Claim moves a pending request to deciding so that only one decision is
+	// carried out. it fails with ErrNotPending, returning the request as it
+	// is, if it's already claimed or decided.
+	Claim(ctx context.Context, id string) (*Request, error)
+	Put(ctx context.Context, request *Request) error
+	// Delete removes a request, decided requests are deleted rather than kept
+	Delete(ctx context.Context, id string) error
+}
+
+// FileStore keeps requests in a json file
+type FileStore struct {
+	file *filestore.File
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{file: filestore.New(path)}
+}
+
+func (store *FileStore) Get(ctx context.Context, id string) (*Request, error) {
+	var requests map[string]*Request
+	err := store.file.Load(&requests)
+	if err != nil {
+		return nil, err
+	}
+	request, ok := requests[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return request, nil
+}
+
+func (store *FileStore) Pending(ctx context.Context) ([]*Request, error) {
+	var requests map[string]*Request
+	err := store.file.Load(&requests)
+	if err != nil {
+		return nil, err
+	}
+	pending := []*Request{}
+	for _, request := range requests {
+		if request.open() {
+			pending = append(pending, request)
+		}
+	}
+	return pending, nil
+}
+
+// Create also drops any decided requests left in the file
+func (store *FileStore) Create(ctx context.Context, request *Request) error {
+	var requests map[string]*Request
+	return store.file.Update(&requests, func() error {
+		for id, existing := range requests {
+			if !existing.open() {
+				delete(requests, id)
+				continue
+			}
+			if strings.EqualFold(existing.MinecraftName, request.MinecraftName) {
+				return ErrAlreadyPending
+			}
+		}
+		if requests == nil {
+			requests = make(map[string]*Request)
+		}
+		requests[request.Id] = request
+		return nil
+	})
+}
+
+func (store *FileStore) Claim(ctx context.Context, id string) (*Request, error) {
+	var requests map[string]*Request
+	var claimed *Request
+	err := store.file.Update(&requests, func() error {
+		request, ok := requests[id]
+		if !ok {
+			return ErrNotFound
+		}
+		claimed = request
+		abandoned := request.Status == StatusDeciding && time.Since(request.ClaimedAt) > claimTimeout
+		if request.Status != StatusPending && !abandoned {
+			return ErrNotPending
+		}
+		request.Status = StatusDeciding
+		request.ClaimedAt = time.Now()
+		return nil
+	})
+	if errors.Is(err, ErrNotPending) {
+		return claimed, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (store *FileStore) Put(ctx context.Context, request *Request) error {
+	var requests map[string]*Request
+	return store.file.Update(&requests, func() error {
+		if requests == nil {
+			requests = make(map[string]*Request)
+		}
+		requests[request.Id] = request
+		return nil
+	})
+}
+
+func (store *FileStore) Delete(ctx context.Context, id string) error {
+	var requests map[string]*Request
+	return store.file.Update(&requests, func() error {
+		delete(requests, id)
+		return nil
+	})
+}
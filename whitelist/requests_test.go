@@ -0,0 +1,129 @@
+package whitelist
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*FileStore, *Request) {
+	t.Helper()
+	store := NewFileStore(filepath.Join(t.TempDir(), "whitelist_requests.json"))
+	request, err := NewRequest("1234", "bsdlp", "a7ec7d80-a1f4-4d2c-9b6b-4ffb0e1f2f8d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Create(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, request
+}
+
+func TestClaimOnce(t *testing.T) {
+	ctx := context.Background()
+	store, request := newTestStore(t)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Claim(ctx, request.Id)
+			if errors.Is(err, ErrNotPending) {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			claimed++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatalf("claimed %d times, want once", claimed)
+	}
+
+	// still open while it's being decided
+	duplicate, err := NewRequest("5678", "BSDLP", request.MinecraftUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Create(ctx, duplicate)
+	if !errors.Is(err, ErrAlreadyPending) {
+		t.Fatalf("got %v, want ErrAlreadyPending", err)
+	}
+}
+
+func TestClaimDecided(t *testing.T) {
+	ctx := context.Background()
+	store, request := newTestStore(t)
+
+	request.Status = StatusDenied
+	err := store.Put(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Claim(ctx, request.Id)
+	if !errors.Is(err, ErrNotPending) || got.Status != StatusDenied {
+		t.Fatalf("got %v, %v, want the denied request and ErrNotPending", got, err)
+	}
+}
+
+func TestClaimAbandoned(t *testing.T) {
+	ctx := context.Background()
+	store, request := newTestStore(t)
+
+	request.Status = StatusDeciding
+	request.ClaimedAt = time.Now().Add(-claimTimeout - time.Minute)
+	err := store.Put(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Claim(ctx, request.Id)
+	if err != nil {
+		t.Fatalf("got %v, want an abandoned claim to be taken over", err)
+	}
+}
+
+func TestCreatePrunesDecided(t *testing.T) {
+	ctx := context.Background()
+	store, request := newTestStore(t)
+
+	request.Status = StatusApproved
+	err := store.Put(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same player can ask again once decided
+	again, err := NewRequest("1234", "bsdlp", request.MinecraftUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Create(ctx, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Get(ctx, request.Id)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want the decided request pruned", err)
+	}
+	pending, err := store.Pending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Id != again.Id {
+		t.Fatalf("got %d pending, want only the new request", len(pending))
+	}
+}